@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/jeremyjsx/entries/internal/handlers"
+)
+
+// adminRoutes are the operator endpoints. They share the public listener
+// behind the admin scope unless ADMIN_PORT moves them to a listener of their
+// own.
+type adminRoutes struct {
+	posts    *handlers.PostsHandler
+	logLevel *slog.LevelVar
+	logger   *slog.Logger
+	pprof    bool
+}
+
+// register mounts the admin endpoints on mux, each wrapped by protect. route
+// maps API patterns to their mounted path; pprof stays at /debug/pprof/.
+func (a adminRoutes) register(mux *http.ServeMux, route func(string) string, protect func(http.Handler) http.Handler) {
+	mux.Handle(route("POST /posts/{slug}/reprocess-images"), protect(a.posts.ReprocessImages()))
+	mux.Handle(route("GET /export"), protect(a.posts.Export()))
+	mux.Handle(route("POST /import"), protect(a.posts.Import()))
+	mux.Handle(route("PUT /admin/log-level"), protect(handlers.LogLevel(a.logLevel, a.logger)))
+	if a.pprof {
+		registerPprof(mux, protect)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jeremyjsx/entries/internal/config"
+	"github.com/jeremyjsx/entries/internal/handlers"
+	"github.com/jeremyjsx/entries/internal/middleware"
+	"github.com/jeremyjsx/entries/internal/posts"
+)
+
+func TestAdminListener(t *testing.T) {
+	var level slog.LevelVar
+	svc := posts.NewService(nil, nil, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+	admin := adminRoutes{
+		posts:    handlers.NewPostsHandler(svc, slog.Default(), handlers.PostsHandlerConfig{}),
+		logLevel: &level,
+		logger:   slog.Default(),
+		pprof:    true,
+	}
+	cfg := &config.Config{HTTPKeepAlive: true}
+
+	publicMux := http.NewServeMux()
+	publicMux.HandleFunc("GET /posts", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	public := serve(t, newServer("", handlers.WithFallbacks(publicMux), cfg))
+
+	adminMux := http.NewServeMux()
+	keys := middleware.APIKeys{"secret": middleware.AllScopes}
+	admin.register(adminMux, func(p string) string { return p }, middleware.RequireScope(keys, middleware.ScopeAdmin))
+	adminURL := serve(t, newServer("", handlers.WithFallbacks(adminMux), cfg))
+
+	do := func(method, url, key string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, url, strings.NewReader(`{"level":"debug"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "" {
+			req.Header.Set(middleware.APIKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, url, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := do(http.MethodGet, public+"/posts", ""); code != http.StatusOK {
+		t.Errorf("public GET /posts: status %d", code)
+	}
+	if code := do(http.MethodPut, public+"/admin/log-level", "secret"); code != http.StatusNotFound {
+		t.Errorf("public PUT /admin/log-level: status %d, want 404", code)
+	}
+	if code := do(http.MethodGet, public+"/debug/pprof/", "secret"); code != http.StatusNotFound {
+		t.Errorf("public GET /debug/pprof/: status %d, want 404", code)
+	}
+	if code := do(http.MethodGet, adminURL+"/posts", "secret"); code != http.StatusNotFound {
+		t.Errorf("admin GET /posts: status %d, want 404", code)
+	}
+	if code := do(http.MethodPut, adminURL+"/admin/log-level", ""); code != http.StatusUnauthorized {
+		t.Errorf("admin PUT /admin/log-level without key: status %d, want 401", code)
+	}
+	if code := do(http.MethodPut, adminURL+"/admin/log-level", "secret"); code != http.StatusOK {
+		t.Errorf("admin PUT /admin/log-level: status %d", code)
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("level = %v, want DEBUG", level.Level())
+	}
+	if code := do(http.MethodGet, adminURL+"/debug/pprof/", "secret"); code != http.StatusOK {
+		t.Errorf("admin GET /debug/pprof/: status %d", code)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/jeremyjsx/entries/internal/storage"
+)
+
+// serveFiles serves images stored by the filesystem backend, the objects under
+// posts/{slug}/images/. Content objects and directory listings are not served:
+// drafts would otherwise be readable without a key.
+func serveFiles(root string) http.Handler {
+	files := http.StripPrefix(storage.FilesPath, http.FileServer(http.Dir(root)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") || !isImageKey(strings.TrimPrefix(r.URL.Path, storage.FilesPath)) {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// isImageKey reports whether key, once cleaned, has the form
+// posts/{slug}/images/{name}.
+func isImageKey(key string) bool {
+	parts := strings.Split(strings.TrimPrefix(path.Clean("/"+key), "/"), "/")
+	return len(parts) == 4 && parts[0] == "posts" && parts[1] != "" && parts[2] == "images" && parts[3] != ""
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeFiles(t *testing.T) {
+	root := t.TempDir()
+	for name, data := range map[string]string{
+		"posts/draft.md":           "# Secret draft",
+		"posts/draft/images/a.png": "png",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := serveFiles(root)
+
+	for _, tt := range []struct {
+		path string
+		want int
+	}{
+		{"/files/posts/draft/images/a.png", http.StatusOK},
+		{"/files/posts/draft.md", http.StatusNotFound},
+		{"/files/posts/draft/images/../../draft.md", http.StatusNotFound},
+		{"/files/posts/draft/images/", http.StatusNotFound},
+		{"/files/posts/draft/images/missing.png", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = tt.path
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s: status %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+}
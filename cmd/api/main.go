@@ -3,36 +3,46 @@ package main
 import (
 	"context"
 	"database/sql"
+	"html/template"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/jeremyjsx/entries/internal/config"
 	"github.com/jeremyjsx/entries/internal/events"
 	"github.com/jeremyjsx/entries/internal/handlers"
 	"github.com/jeremyjsx/entries/internal/middleware"
+	"github.com/jeremyjsx/entries/internal/outbox"
 	"github.com/jeremyjsx/entries/internal/posts"
 	"github.com/jeremyjsx/entries/internal/storage"
 	_ "github.com/lib/pq"
 )
 
 func main() {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	// logLevel is shared with PUT /admin/log-level, so changes apply at once.
+	var logLevel slog.LevelVar
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: &logLevel}))
 
 	cfg := config.Load()
 	if cfg.DatabaseURL == "" {
-		logger.Error("DATABASE_URL is required")
+		logger.Error("DATABASE_URL or DB_HOST, DB_USER and DB_NAME are required")
 		os.Exit(1)
 	}
-	if cfg.S3Bucket == "" {
-		logger.Error("S3_BUCKET is required")
+	addr, err := config.ListenAddr(cfg.BindAddress, cfg.Port)
+	if err != nil {
+		logger.Error("invalid listen address", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.HealthDegradedCode != http.StatusOK && cfg.HealthDegradedCode != http.StatusServiceUnavailable {
+		logger.Error("HEALTH_DEGRADED_CODE must be 200 or 503", "value", cfg.HealthDegradedCode)
 		os.Exit(1)
 	}
 
@@ -43,28 +53,52 @@ func main() {
 	}
 	defer db.Close()
 
-	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(),
-		awsconfig.WithRegion(cfg.AWSRegion),
-	)
+	storageCfg := storage.Config{
+		Backend:    cfg.StorageBackend,
+		S3Bucket:   cfg.S3Bucket,
+		AWSRegion:  cfg.AWSRegion,
+		S3Endpoint: cfg.S3Endpoint,
+		S3Options: storage.S3Options{
+			PublicRead:         cfg.S3PublicRead,
+			PublicReadContent:  cfg.S3PublicReadContent,
+			MultipartThreshold: cfg.S3MultipartThreshold,
+			MaxConcurrency:     cfg.S3MaxConcurrency,
+		},
+		Dir: cfg.StorageDir,
+	}
+	store, err := storage.New(context.Background(), storageCfg)
 	if err != nil {
-		logger.Error("failed to load AWS config", "error", err)
+		logger.Error("failed to configure storage", "backend", cfg.StorageBackend, "error", err)
 		os.Exit(1)
 	}
-	s3Client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
-		if cfg.S3Endpoint != "" {
-			o.BaseEndpoint = aws.String(cfg.S3Endpoint)
-			o.UsePathStyle = true
+	logger.Info("storage configured", "backend", cfg.StorageBackend)
+	if cfg.S3SelfTest {
+		if err := storage.SelfTest(context.Background(), store); err != nil {
+			logger.Error("storage self-test failed", "backend", cfg.StorageBackend, "error", err)
+			os.Exit(1)
 		}
-	})
-	store := storage.NewS3Storage(s3Client, cfg.S3Bucket)
-	s3PublicBaseURL := ""
-	if cfg.S3Endpoint != "" {
-		s3PublicBaseURL = strings.TrimSuffix(cfg.S3Endpoint, "/") + "/" + cfg.S3Bucket
+		logger.Info("storage self-test passed", "backend", cfg.StorageBackend)
+	}
+
+	var imageURLSigner storage.URLSigner
+	if cfg.S3SignImageURLs {
+		signer, ok := store.(storage.URLSigner)
+		if !ok {
+			logger.Error("S3_SIGN_IMAGE_URLS requires the s3 storage backend", "backend", cfg.StorageBackend)
+			os.Exit(1)
+		}
+		imageURLSigner = signer
 	}
 
 	var publisher events.Publisher = events.NoopPublisher{}
 	if cfg.RabbitMQURL != "" {
-		rmq, err := events.NewRabbitMQPublisher(cfg.RabbitMQURL)
+		rmq, err := events.NewRabbitMQPublisherWithConfig(cfg.RabbitMQURL, events.RabbitMQConfig{
+			Confirm:        cfg.RabbitMQConfirms,
+			ConfirmTimeout: cfg.RabbitMQConfirmTimeout,
+			Mandatory:      cfg.RabbitMQMandatory,
+			FailUnroutable: cfg.RabbitMQFailUnroutable,
+			Logger:         logger,
+		})
 		if err != nil {
 			logger.Error("failed to connect to RabbitMQ", "error", err)
 			os.Exit(1)
@@ -80,44 +114,253 @@ func main() {
 		logger.Info("event publisher disabled", "broker", "none")
 	}
 
-	repo := posts.NewPostgresRepository(db)
+	// Cache hits skip the slow-query timer; only real database calls are timed.
+	repo := posts.NewCachedRepository(
+		posts.NewSlowQueryRepository(posts.NewPostgresRepository(db), cfg.SlowQueryThreshold, logger),
+		cfg.PostCacheSize,
+		cfg.PostCacheTTL,
+	)
+	outboxStore := outbox.NewPostgresStore(db)
+	relay := outbox.NewRelay(outboxStore, publisher, logger, outbox.RelayConfig{Retention: cfg.OutboxRetention})
+	views := posts.NewViewCounter(repo, logger, 0)
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		views.Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		relay.Run(backgroundCtx)
+	}()
+	imageEmbedMode := posts.ImageEmbedMode(cfg.ImageEmbedMode)
+	if !imageEmbedMode.Valid() {
+		logger.Error("invalid IMAGE_EMBED_MODE; expected upload, reject or ignore", "mode", cfg.ImageEmbedMode)
+		os.Exit(1)
+	}
+	pageOutOfRange := posts.PageOutOfRangeMode(cfg.PageOutOfRangeMode)
+	if !pageOutOfRange.Valid() {
+		logger.Error("invalid PAGE_OUT_OF_RANGE_MODE; expected empty, clamp or error", "mode", cfg.PageOutOfRangeMode)
+		os.Exit(1)
+	}
+	contentMissing := posts.ContentMissingMode(cfg.ContentMissingMode)
+	if !contentMissing.Valid() {
+		logger.Error("invalid CONTENT_MISSING_MODE; expected error or placeholder", "mode", cfg.ContentMissingMode)
+		os.Exit(1)
+	}
+	var imageFetcher *posts.ImageFetcher
+	if cfg.ImageRehostExternal {
+		if cfg.ImageRehostMaxBytes <= 0 {
+			logger.Error("IMAGE_REHOST_MAX_BYTES must be positive", "value", cfg.ImageRehostMaxBytes)
+			os.Exit(1)
+		}
+		imageFetcher = posts.NewImageFetcher(cfg.ImageRehostMaxBytes, 0)
+	}
+	var postURL func(slug string) string
+	if cfg.SiteURL != "" {
+		postURL = func(slug string) string {
+			return cfg.SiteURL + cfg.BasePath + "/posts/" + url.PathEscape(slug) + "/preview"
+		}
+	}
 	svc := posts.NewService(repo, store, publisher, logger, posts.ServiceConfig{
-		S3Bucket:        cfg.S3Bucket,
-		AWSRegion:       cfg.AWSRegion,
-		S3PublicBaseURL: s3PublicBaseURL,
+		S3Bucket:           cfg.S3Bucket,
+		AWSRegion:          cfg.AWSRegion,
+		S3PublicBaseURL:    storageCfg.PublicBaseURL(),
+		MaxImageBytes:      cfg.MaxImageBytes,
+		MaxImageDimension:  cfg.MaxImageDimension,
+		ImageURLSigner:     imageURLSigner,
+		SignedURLTTL:       cfg.S3SignedURLTTL,
+		ContentCache:       posts.NewContentCache(cfg.ContentCacheBytes),
+		Views:              views,
+		Outbox:             outboxStore,
+		StrictHeadings:     cfg.StrictHeadings,
+		ImageEmbedMode:     imageEmbedMode,
+		ImageFetcher:       imageFetcher,
+		ConsistencyWindow:  cfg.ConsistencyWindow,
+		PageOutOfRange:     pageOutOfRange,
+		ContentMissing:     contentMissing,
+		ContentPlaceholder: cfg.ContentPlaceholder,
+		PostURL:            postURL,
+		RequireEvent:       cfg.PublishEventRequired,
+	})
+	apiKeys, err := middleware.ParseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		logger.Error("invalid API_KEYS", "error", err)
+		os.Exit(1)
+	}
+	if cfg.APIKey != "" {
+		apiKeys[cfg.APIKey] = middleware.AllScopes
+	}
+	var reservedSlugs []string
+	if cfg.ReservedSlugs != "" {
+		reservedSlugs = []string{}
+		for _, slug := range strings.Split(cfg.ReservedSlugs, ",") {
+			if slug = strings.ToLower(strings.TrimSpace(slug)); slug != "" {
+				reservedSlugs = append(reservedSlugs, slug)
+			}
+		}
+	}
+	slugNormalize := handlers.SlugNormalizeMode(cfg.SlugNormalizeMode)
+	if !slugNormalize.Valid() {
+		logger.Error("invalid SLUG_NORMALIZE_MODE; expected off, redirect or lookup", "mode", cfg.SlugNormalizeMode)
+		os.Exit(1)
+	}
+	var previewTemplate *template.Template
+	if cfg.PreviewTemplatePath != "" {
+		previewTemplate, err = template.ParseFiles(cfg.PreviewTemplatePath)
+		if err != nil {
+			logger.Error("invalid PREVIEW_TEMPLATE", "error", err)
+			os.Exit(1)
+		}
+	}
+	var siteURL *url.URL
+	if cfg.SiteURL != "" {
+		siteURL, err = url.Parse(cfg.SiteURL)
+		if err != nil || (siteURL.Scheme != "http" && siteURL.Scheme != "https") || siteURL.Host == "" {
+			logger.Error("invalid SITE_URL; expected an absolute http(s) URL", "site_url", cfg.SiteURL)
+			os.Exit(1)
+		}
+	}
+	postsHandler := handlers.NewPostsHandler(svc, logger, handlers.PostsHandlerConfig{
+		APIKeys:             apiKeys,
+		ProtectDraftContent: cfg.DraftContentRequiresKey,
+		MaxTags:             cfg.MaxTagsPerPost,
+		MaxTagLength:        cfg.MaxTagLength,
+		BasePath:            cfg.BasePath,
+		ReservedSlugs:       reservedSlugs,
+		PreviewTemplate:     previewTemplate,
+		SiteURL:             siteURL,
+		AnonymousMaxPerPage: cfg.AnonymousMaxPerPage,
+		PreviewCSP:          cfg.PreviewCSP,
+		PreviewMaxAge:       cfg.PreviewMaxAge,
+		SlugNormalize:       slugNormalize,
+		RequireJSON:         cfg.RequireJSONContentType,
+		UploadReadTimeout:   cfg.UploadReadTimeout,
 	})
-	postsHandler := handlers.NewPostsHandler(svc, logger)
+	if len(apiKeys) == 0 {
+		if !cfg.AuthDisabled {
+			logger.Error("API_KEY or API_KEYS is required; set AUTH_DISABLED=true to serve write and admin endpoints without authentication")
+			os.Exit(1)
+		}
+		logger.Warn("AUTH_DISABLED is set; write and admin endpoints are unauthenticated")
+		if cfg.DraftContentRequiresKey {
+			logger.Warn("DRAFT_CONTENT_REQUIRES_KEY has no effect without API keys")
+		}
+	}
+	requireWrite := requireScope(apiKeys, middleware.ScopeWrite)
+	requireAdmin := requireScope(apiKeys, middleware.ScopeAdmin)
+	requireRead := requireScope(apiKeys, middleware.ScopeRead)
+	identifyKey := middleware.IdentifyKey(apiKeys)
 
+	// API routes live under BASE_PATH; health and /files stay at fixed paths
+	// for probes and for image URLs already stored in content.
+	route := func(pattern string) string { return handlers.Route(cfg.BasePath, pattern) }
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /health", handlers.Health(&handlers.HealthDeps{
-		DB: db, Storage: store, RabbitMQURL: cfg.RabbitMQURL,
+	mux.HandleFunc("GET "+cfg.HealthPath, handlers.Health(&handlers.HealthDeps{
+		DB:                 db,
+		Storage:            store,
+		RabbitMQURL:        cfg.RabbitMQURL,
+		DegradedStatusCode: cfg.HealthDegradedCode,
 	}))
-	mux.HandleFunc("GET /posts", postsHandler.List())
-	mux.HandleFunc("POST /posts", postsHandler.Create())
-	mux.HandleFunc("GET /posts/{slug}/content", postsHandler.GetContent())
-	mux.HandleFunc("GET /posts/{slug}", postsHandler.GetBySlug())
-	mux.HandleFunc("PUT /posts/{slug}", postsHandler.Update())
-	mux.HandleFunc("DELETE /posts/{slug}", postsHandler.Delete())
-	mux.HandleFunc("PATCH /posts/{slug}/publish", postsHandler.Publish())
-
-	handler := middleware.Recovery(logger)(
-		middleware.RequestID(middleware.Logging(logger)(mux)),
-	)
-	server := &http.Server{
-		Addr:         ":" + cfg.Port,
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	mux.Handle(route("GET /posts"), identifyKey(postsHandler.List()))
+	mux.Handle(route("POST /posts"), requireWrite(postsHandler.Create()))
+	mux.Handle(route("POST /posts/exists"), requireRead(postsHandler.Exists()))
+	mux.HandleFunc(route("GET /posts/{slug}/content"), postsHandler.GetContent())
+	mux.HandleFunc(route("HEAD /posts/{slug}/content"), postsHandler.GetContent())
+	mux.HandleFunc(route("GET /posts/{slug}/preview"), postsHandler.Preview())
+	mux.Handle(route("PUT /posts/{slug}/content"), requireWrite(postsHandler.UpdateContent()))
+	mux.HandleFunc(route("GET /posts/{slug}"), postsHandler.GetBySlug())
+	mux.Handle(route("GET "+handlers.ByIDPattern), handlers.ByID(postsHandler.GetByID()))
+	mux.Handle(route("PUT /posts/{slug}"), requireWrite(postsHandler.Update()))
+	mux.Handle(route("PUT "+handlers.ByIDPattern), requireWrite(handlers.ByID(postsHandler.UpdateByID())))
+	mux.Handle(route("DELETE /posts/{slug}"), requireWrite(postsHandler.Delete()))
+	mux.Handle(route("PATCH /posts/{slug}/publish"), requireWrite(postsHandler.Publish()))
+	mux.HandleFunc(route("GET /tags"), postsHandler.Tags())
+	if fsStore, ok := store.(*storage.FilesystemStorage); ok {
+		mux.Handle("GET "+storage.FilesPath, serveFiles(fsStore.Root()))
+	}
+	admin := adminRoutes{posts: postsHandler, logLevel: &logLevel, logger: logger, pprof: cfg.EnablePprof}
+	var adminServer *http.Server
+	if cfg.AdminPort == "" {
+		admin.register(mux, route, requireAdmin)
+		if cfg.EnablePprof && len(apiKeys) == 0 {
+			logger.Warn("ENABLE_PPROF is set without API keys; /debug/pprof/ is open to anyone")
+		}
+	} else {
+		adminAddr, err := config.ListenAddr(cfg.BindAddress, cfg.AdminPort)
+		if err != nil {
+			logger.Error("invalid admin listen address", "error", err)
+			os.Exit(1)
+		}
+		// The admin listener is meant to be reachable only from the operator
+		// network, so it takes ADMIN_API_KEY alone.
+		adminKeys := middleware.APIKeys{}
+		if cfg.AdminAPIKey != "" {
+			adminKeys[cfg.AdminAPIKey] = middleware.AllScopes
+		} else if cfg.AuthDisabled {
+			logger.Warn("ADMIN_API_KEY not set; the admin listener is unauthenticated", "addr", adminAddr)
+		} else {
+			logger.Error("ADMIN_API_KEY is required with ADMIN_PORT; set AUTH_DISABLED=true to leave the admin listener unauthenticated")
+			os.Exit(1)
+		}
+		adminMux := http.NewServeMux()
+		admin.register(adminMux, func(pattern string) string { return pattern }, requireScope(adminKeys, middleware.ScopeAdmin))
+		adminServer = newServer(adminAddr, middleware.RequestID(middleware.Recovery(logger)(
+			middleware.Logging(logger)(handlers.WithFallbacks(adminMux)),
+		)), cfg)
 	}
 
+	var maintenance atomic.Bool
+	maintenance.Store(cfg.Maintenance)
+	if cfg.Maintenance {
+		logger.Warn("maintenance mode on; writes answered 503")
+	}
+	toggle := make(chan os.Signal, 1)
+	signal.Notify(toggle, syscall.SIGUSR1)
+	go func() {
+		for range toggle {
+			on := !maintenance.Load()
+			maintenance.Store(on)
+			logger.Warn("maintenance mode toggled", "on", on)
+		}
+	}()
+
+	// RequestID is outermost so recovered panics are logged and answered with
+	// the same request ID.
+	handler := middleware.RequestID(middleware.Recovery(logger)(
+		middleware.Logging(logger)(
+			middleware.CORS(middleware.ParseOrigins(cfg.CORSAllowedOrigins), cfg.CORSMaxAge)(
+				middleware.SecurityHeaders(middleware.SecurityHeadersConfig{
+					Server:       cfg.ServerHeader,
+					NoSniff:      cfg.NoSniff,
+					FrameOptions: cfg.FrameOptions,
+				})(
+					middleware.MaxInFlight(cfg.MaxInFlight, cfg.HealthPath)(
+						middleware.Maintenance(&maintenance, cfg.MaintenanceRetryAfter, cfg.HealthPath)(handlers.WithFallbacks(mux)),
+					),
+				),
+			),
+		),
+	))
+	server := newServer(addr, handler, cfg)
+
 	go func() {
-		logger.Info("server started", "port", cfg.Port)
+		logger.Info("server started", "addr", addr)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Error("server error", "error", err)
 			os.Exit(1)
 		}
 	}()
+	if adminServer != nil {
+		go func() {
+			logger.Info("admin server started", "addr", adminServer.Addr)
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("admin server error", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -125,10 +368,17 @@ func main() {
 	logger.Info("shutting down server...")
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			logger.Error("admin server forced to shutdown", "error", err)
+		}
+	}
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Error("server forced to shutdown", "error", err)
 		os.Exit(1)
 	}
+	stopBackground()
+	background.Wait()
 	logger.Info("server stopped")
 }
 
@@ -143,3 +393,12 @@ func openDB(databaseURL string) (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+// requireScope is middleware.RequireScope, except that an empty key set lets
+// every request through. main only gets there with AUTH_DISABLED.
+func requireScope(keys middleware.APIKeys, scope middleware.Scope) func(http.Handler) http.Handler {
+	if len(keys) == 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return middleware.RequireScope(keys, scope)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+)
+
+// registerPprof mounts the net/http/pprof handlers under /debug/pprof/, each
+// wrapped by protect. They reveal memory contents and can burn CPU on demand,
+// so they are only registered when ENABLE_PPROF is set.
+func registerPprof(mux *http.ServeMux, protect func(http.Handler) http.Handler) {
+	mux.Handle("GET /debug/pprof/", protect(http.HandlerFunc(pprof.Index)))
+	mux.Handle("GET /debug/pprof/cmdline", protect(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("GET /debug/pprof/profile", protect(extendWriteDeadline(pprof.Profile, 30*time.Second)))
+	mux.Handle("GET /debug/pprof/symbol", protect(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("POST /debug/pprof/symbol", protect(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("GET /debug/pprof/trace", protect(extendWriteDeadline(pprof.Trace, time.Second)))
+}
+
+// extendWriteDeadline moves the write deadline past the server's write timeout
+// by the duration the profile runs for: the seconds query parameter, or def as
+// pprof itself defaults to.
+func extendWriteDeadline(next http.HandlerFunc, def time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := def
+		if sec, err := strconv.ParseFloat(r.URL.Query().Get("seconds"), 64); err == nil && sec > 0 {
+			d = time.Duration(sec * float64(time.Second))
+		}
+		if srv, ok := r.Context().Value(http.ServerContextKey).(*http.Server); ok && srv.WriteTimeout > 0 {
+			_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(srv.WriteTimeout + d))
+		}
+		next(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRegisterPprof(t *testing.T) {
+	noop := func(next http.Handler) http.Handler { return next }
+	for _, enabled := range []bool{false, true} {
+		mux := http.NewServeMux()
+		if enabled {
+			registerPprof(mux, noop)
+		}
+		for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			want := http.StatusNotFound
+			if enabled {
+				want = http.StatusOK
+			}
+			if rec.Code != want {
+				t.Errorf("enabled=%v GET %s: status %d, want %d", enabled, path, rec.Code, want)
+			}
+		}
+	}
+
+	mux := http.NewServeMux()
+	registerPprof(mux, func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusUnauthorized) })
+	})
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("protected index: status %d, want 401", rec.Code)
+	}
+}
+
+func TestRegisterPprof_OutlastsWriteTimeout(t *testing.T) {
+	mux := http.NewServeMux()
+	registerPprof(mux, func(next http.Handler) http.Handler { return next })
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Config.WriteTimeout = 200 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/debug/pprof/trace?seconds=0.5")
+	if err != nil {
+		t.Fatalf("GET trace: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read trace: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(body) == 0 {
+		t.Errorf("trace: status %d, %d bytes", resp.StatusCode, len(body))
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jeremyjsx/entries/internal/config"
+)
+
+// newServer builds the API server from the connection tuning options.
+func newServer(addr string, handler http.Handler, cfg *config.Config) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      15 * time.Second,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	if cfg.HTTP2Cleartext {
+		// Prior-knowledge HTTP/2 without TLS, for proxies that terminate TLS
+		// and speak h2c upstream. HTTP/1.1 keeps working alongside it.
+		// http.Protocols is why go.mod requires Go 1.24.
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+	server.SetKeepAlivesEnabled(cfg.HTTPKeepAlive)
+	return server
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremyjsx/entries/internal/config"
+)
+
+// serve starts srv on a loopback port and returns its base URL.
+func serve(t *testing.T, srv *http.Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return "http://" + ln.Addr().String()
+}
+
+func TestNewServer(t *testing.T) {
+	proto := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+
+	t.Run("options applied", func(t *testing.T) {
+		cfg := &config.Config{MaxHeaderBytes: 4096, ReadHeaderTimeout: 2 * time.Second, HTTPIdleTimeout: 5 * time.Second, HTTPKeepAlive: true}
+		srv := newServer("127.0.0.1:8080", proto, cfg)
+		if srv.Addr != "127.0.0.1:8080" || srv.MaxHeaderBytes != 4096 || srv.IdleTimeout != 5*time.Second {
+			t.Errorf("server %+v", srv)
+		}
+		if srv.ReadHeaderTimeout != 2*time.Second || srv.ReadTimeout != 15*time.Second || srv.WriteTimeout != 15*time.Second {
+			t.Errorf("timeouts: header %v, read %v, write %v", srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout)
+		}
+		if srv.Protocols != nil {
+			t.Errorf("h2c should be off by default, got %v", srv.Protocols)
+		}
+	})
+
+	t.Run("keep-alive disabled", func(t *testing.T) {
+		srv := newServer("", proto, &config.Config{HTTPKeepAlive: false})
+		resp, err := http.Get(serve(t, srv))
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		resp.Body.Close()
+		if !resp.Close {
+			t.Error("expected the server to close the connection")
+		}
+	})
+
+	t.Run("h2c", func(t *testing.T) {
+		srv := newServer("", proto, &config.Config{HTTPKeepAlive: true, HTTP2Cleartext: true})
+		if !srv.Protocols.UnencryptedHTTP2() || !srv.Protocols.HTTP1() {
+			t.Fatalf("protocols %v", srv.Protocols)
+		}
+		url := serve(t, srv)
+
+		var protocols http.Protocols
+		protocols.SetUnencryptedHTTP2(true)
+		client := &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("h2c GET: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Errorf("proto = %s, want HTTP/2", resp.Proto)
+		}
+
+		resp, err = http.Get(url)
+		if err != nil {
+			t.Fatalf("HTTP/1.1 GET: %v", err)
+		}
+		resp.Body.Close()
+		if resp.ProtoMajor != 1 {
+			t.Errorf("proto = %s, want HTTP/1.1", resp.Proto)
+		}
+	})
+	t.Run("slow headers", func(t *testing.T) {
+		url := serve(t, newServer("", proto, &config.Config{ReadHeaderTimeout: 100 * time.Millisecond}))
+		conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+		// Start a request but never finish its headers.
+		if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n")); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := io.ReadAll(conn); err != nil {
+			t.Fatalf("expected the server to close the connection, got %v", err)
+		}
+	})
+}
@@ -1,17 +1,29 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
+	"net/mail"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/jeremyjsx/entries/internal/config"
 	"github.com/jeremyjsx/entries/internal/events"
+	"github.com/jeremyjsx/entries/internal/worker"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// maxRequeueDelay caps how long a throttled delivery is held before it is
+// requeued, whatever Retry-After the downstream sent.
+const maxRequeueDelay = time.Minute
+
 func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
 
@@ -21,6 +33,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	action, closeAction, err := newAction(cfg, logger)
+	if err != nil {
+		logger.Error("failed to configure worker action", "error", err)
+		os.Exit(1)
+	}
+	defer closeAction()
+	// Delivery is at-least-once, so skip events this worker already handled.
+	action = worker.Dedupe(action, worker.NewSeenSet(0))
+
 	conn, err := amqp.Dial(cfg.RabbitMQURL)
 	if err != nil {
 		logger.Error("failed to connect to RabbitMQ", "error", err)
@@ -57,14 +78,14 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger.Info("newsletter worker started", "queue", q.Name)
+	logger.Info("newsletter worker started", "queue", q.Name, "action", cfg.WorkerAction)
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	for {
 		select {
-		case <-quit:
+		case <-ctx.Done():
 			logger.Info("worker shutting down")
 			return
 		case d, ok := <-deliveries:
@@ -72,12 +93,76 @@ func main() {
 				logger.Warn("delivery channel closed")
 				return
 			}
-			handlePostPublished(logger, d)
+			handlePostPublished(ctx, logger, action, d)
 		}
 	}
 }
 
-func handlePostPublished(logger *slog.Logger, d amqp.Delivery) {
+func newAction(cfg *config.Config, logger *slog.Logger) (worker.Action, func(), error) {
+	switch cfg.WorkerAction {
+	case worker.ActionLog:
+		return worker.LogAction{Logger: logger}, func() {}, nil
+	case worker.ActionHTTP:
+		if cfg.WorkerForwardURL == "" {
+			return nil, nil, fmt.Errorf("WORKER_FORWARD_URL is required for the http action")
+		}
+		return worker.NewHTTPForwardAction(cfg.WorkerForwardURL), func() {}, nil
+	case worker.ActionRepublish:
+		if cfg.WorkerRepublishRoutingKey == events.RoutingKey {
+			return nil, nil, fmt.Errorf("republish routing key must differ from %q", events.RoutingKey)
+		}
+		pub, err := events.NewRabbitMQPublisherWithConfig(cfg.RabbitMQURL, events.RabbitMQConfig{
+			RoutingKey:     cfg.WorkerRepublishRoutingKey,
+			Confirm:        cfg.RabbitMQConfirms,
+			ConfirmTimeout: cfg.RabbitMQConfirmTimeout,
+			Mandatory:      cfg.RabbitMQMandatory,
+			FailUnroutable: cfg.RabbitMQFailUnroutable,
+			Logger:         logger,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return worker.RepublishAction{Publisher: pub}, func() { _ = pub.Close() }, nil
+	case worker.ActionEmail:
+		action, err := newEmailAction(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return action, func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown WORKER_ACTION %q", cfg.WorkerAction)
+	}
+}
+
+// newEmailAction builds the email action from the SMTP_* variables. Links
+// point at each post's preview page under SITE_URL.
+func newEmailAction(cfg *config.Config) (*worker.EmailAction, error) {
+	switch {
+	case cfg.SMTPHost == "":
+		return nil, fmt.Errorf("SMTP_HOST is required for the email action")
+	case cfg.SiteURL == "":
+		return nil, fmt.Errorf("SITE_URL is required for the email action")
+	}
+	from, err := mail.ParseAddress(cfg.SMTPFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_FROM %q: %w", cfg.SMTPFrom, err)
+	}
+	to, err := mail.ParseAddressList(cfg.SMTPTo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_TO %q: %w", cfg.SMTPTo, err)
+	}
+	sender := &worker.SMTPSender{
+		Addr:     net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+	}
+	postURL := func(slug string) string {
+		return cfg.SiteURL + cfg.BasePath + "/posts/" + url.PathEscape(slug) + "/preview"
+	}
+	return worker.NewEmailAction(sender, from, to, postURL), nil
+}
+
+func handlePostPublished(ctx context.Context, logger *slog.Logger, action worker.Action, d amqp.Delivery) {
 	var e events.PostPublished
 	if err := json.Unmarshal(d.Body, &e); err != nil {
 		logger.Error("invalid event body", "error", err)
@@ -89,11 +174,51 @@ func handlePostPublished(logger *slog.Logger, d amqp.Delivery) {
 		_ = d.Ack(false)
 		return
 	}
-	logger.Info("post published event received",
-		"post_id", e.Payload.PostID,
-		"slug", e.Payload.Slug,
-		"title", e.Payload.Title,
-	)
+	if e.SchemaVersion > events.PostPublishedSchemaVersion {
+		logger.Warn("event schema newer than worker; handling known fields",
+			"event_id", e.ID,
+			"schema_version", e.SchemaVersion,
+		)
+	}
+	if err := action.Handle(ctx, e); err != nil {
+		var transient *worker.TransientError
+		if errors.As(err, &transient) {
+			delay := min(transient.RetryAfter, maxRequeueDelay)
+			logger.Warn("event action failed transiently; requeueing",
+				"event_id", e.ID,
+				"slug", e.Payload.Slug,
+				"retry_after", delay,
+				"error", err,
+			)
+			// Holding the delivery before requeueing keeps the consumer from
+			// hammering a downstream that asked us to slow down or is down.
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+			_ = d.Nack(false, true)
+			return
+		}
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			// Shutdown or a timeout cut the action short; it never got a
+			// verdict, so leave the event for the next consumer.
+			logger.Warn("event action interrupted; requeueing",
+				"event_id", e.ID,
+				"slug", e.Payload.Slug,
+				"error", err,
+			)
+			_ = d.Nack(false, true)
+			return
+		}
+		logger.Error("event action failed",
+			"event_id", e.ID,
+			"post_id", e.Payload.PostID,
+			"slug", e.Payload.Slug,
+			"error", err,
+		)
+		_ = d.Nack(false, false)
+		return
+	}
 
 	if err := d.Ack(false); err != nil {
 		logger.Error("failed to ack", "error", err)
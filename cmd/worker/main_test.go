@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/events"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type fakeAcknowledger struct {
+	acked, requeued, dropped int
+}
+
+func (a *fakeAcknowledger) Ack(uint64, bool) error {
+	a.acked++
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(_ uint64, _ bool, requeue bool) error {
+	if requeue {
+		a.requeued++
+	} else {
+		a.dropped++
+	}
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(_ uint64, requeue bool) error {
+	return a.Nack(0, false, requeue)
+}
+
+type funcAction func(ctx context.Context, e events.PostPublished) error
+
+func (f funcAction) Handle(ctx context.Context, e events.PostPublished) error { return f(ctx, e) }
+
+func TestHandlePostPublished_Interrupted(t *testing.T) {
+	body, err := json.Marshal(events.NewPostPublished(events.PostPublishedPayload{PostID: uuid.New(), Slug: "p"}, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tc := range []struct {
+		name      string
+		err       error
+		wantAcked bool
+		requeue   bool
+	}{
+		{"success", nil, true, false},
+		{"canceled", fmt.Errorf("forward: %w", context.Canceled), false, true},
+		{"deadline", fmt.Errorf("forward: %w", context.DeadlineExceeded), false, true},
+		{"failed", errors.New("bad request"), false, false},
+	} {
+		ack := &fakeAcknowledger{}
+		action := funcAction(func(context.Context, events.PostPublished) error { return tc.err })
+		handlePostPublished(context.Background(), logger, action, amqp.Delivery{Acknowledger: ack, Body: body})
+		if got := ack.acked == 1; got != tc.wantAcked {
+			t.Errorf("%s: acked %d", tc.name, ack.acked)
+		}
+		if !tc.wantAcked {
+			if tc.requeue && ack.requeued != 1 {
+				t.Errorf("%s: requeued %d, dropped %d, want requeue", tc.name, ack.requeued, ack.dropped)
+			}
+			if !tc.requeue && ack.dropped != 1 {
+				t.Errorf("%s: requeued %d, dropped %d, want drop", tc.name, ack.requeued, ack.dropped)
+			}
+		}
+	}
+}
@@ -1,19 +1,126 @@
 package config
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port        string
-	DatabaseURL string
-	S3Bucket    string
-	AWSRegion   string
-	S3Endpoint  string
-	RabbitMQURL string
+	Port string
+	// AdminPort, when set, moves the admin endpoints to a listener of their own.
+	AdminPort         string
+	AdminAPIKey       string
+	BindAddress       string
+	DatabaseURL       string
+	S3Bucket          string
+	AWSRegion         string
+	S3Endpoint        string
+	RabbitMQURL       string
+	APIKey            string
+	APIKeys           string
+	AuthDisabled      bool
+	MaxImageBytes     int64
+	MaxImageDimension int
+	// ImageEmbedMode is upload, reject or ignore; see posts.ImageEmbedMode.
+	ImageEmbedMode string
+	// ImageRehostExternal copies external http(s) images into storage.
+	ImageRehostExternal bool
+	ImageRehostMaxBytes int64
+
+	// RabbitMQConfirms waits for the broker to accept each published event.
+	RabbitMQConfirms       bool
+	RabbitMQConfirmTimeout time.Duration
+	// RabbitMQMandatory has unroutable events returned and logged;
+	// RabbitMQFailUnroutable also fails their publish.
+	RabbitMQMandatory      bool
+	RabbitMQFailUnroutable bool
+	// PublishEventRequired fails a publish whose event cannot be sent.
+	PublishEventRequired bool
+
+	HealthDegradedCode int
+	MaxInFlight        int
+	// EnablePprof mounts /debug/pprof/ behind the admin scope.
+	EnablePprof bool
+	// Maintenance starts the API with writes answered 503; SIGUSR1 toggles it.
+	Maintenance           bool
+	MaintenanceRetryAfter time.Duration
+	BasePath              string
+	HealthPath            string
+	CORSAllowedOrigins    string
+	CORSMaxAge            time.Duration
+	ServerHeader          string
+	NoSniff               bool
+	FrameOptions          string
+	PreviewCSP            string
+	PreviewMaxAge         time.Duration
+	// SlugNormalizeMode is off, redirect or lookup; see handlers.SlugNormalizeMode.
+	SlugNormalizeMode string
+	// RequireJSONContentType answers 415 to JSON bodies sent as another type.
+	RequireJSONContentType bool
+
+	MaxHeaderBytes int
+	// ReadHeaderTimeout bounds reading request headers, so slow clients
+	// cannot hold connections open by trickling them (slow-loris).
+	ReadHeaderTimeout time.Duration
+	// UploadReadTimeout replaces the read deadline on content and import
+	// uploads; see handlers.PostsHandlerConfig.
+	UploadReadTimeout time.Duration
+	HTTPKeepAlive     bool
+	HTTPIdleTimeout   time.Duration
+	HTTP2Cleartext    bool
+
+	DraftContentRequiresKey bool
+	AnonymousMaxPerPage     int
+	MaxTagsPerPost          int
+	MaxTagLength            int
+	StrictHeadings          bool
+	ReservedSlugs           string
+	PreviewTemplatePath     string
+	SiteURL                 string
+	PostCacheSize           int
+	PostCacheTTL            time.Duration
+	SlowQueryThreshold      time.Duration
+	OutboxRetention         time.Duration
+	ContentCacheBytes       int64
+	ConsistencyWindow       time.Duration
+	// PageOutOfRangeMode is empty, clamp or error; see posts.PageOutOfRangeMode.
+	PageOutOfRangeMode string
+	// ContentMissingMode is error or placeholder; see posts.ContentMissingMode.
+	ContentMissingMode string
+	ContentPlaceholder string
+
+	StorageBackend       string
+	StorageDir           string
+	S3PublicRead         bool
+	S3PublicReadContent  bool
+	S3MultipartThreshold int64
+	S3MaxConcurrency     int64
+	S3SignImageURLs      bool
+	S3SignedURLTTL       time.Duration
+	S3SelfTest           bool
+
+	WorkerAction              string
+	WorkerForwardURL          string
+	WorkerRepublishRoutingKey string
+
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// SMTPTo is a comma-separated list of notification recipients.
+	SMTPTo string
 }
 
 func Load() *Config {
@@ -22,12 +129,91 @@ func Load() *Config {
 	}
 
 	return &Config{
-		Port:        getEnv("PORT", "8080"),
-		DatabaseURL: getEnv("DATABASE_URL", ""),
-		S3Bucket:    getEnv("S3_BUCKET", ""),
-		AWSRegion:   getEnv("AWS_REGION", "us-east-1"),
-		S3Endpoint:  getEnv("S3_ENDPOINT", ""),
-		RabbitMQURL: getEnv("RABBITMQ_URL", ""),
+		Port:                getEnv("PORT", "8080"),
+		AdminPort:           getEnv("ADMIN_PORT", ""),
+		AdminAPIKey:         getEnv("ADMIN_API_KEY", ""),
+		BindAddress:         getEnv("BIND_ADDRESS", ""),
+		DatabaseURL:         databaseURL(),
+		S3Bucket:            getEnv("S3_BUCKET", ""),
+		AWSRegion:           getEnv("AWS_REGION", "us-east-1"),
+		S3Endpoint:          getEnv("S3_ENDPOINT", ""),
+		RabbitMQURL:         getEnv("RABBITMQ_URL", ""),
+		APIKey:              getEnv("API_KEY", ""),
+		APIKeys:             getEnv("API_KEYS", ""),
+		AuthDisabled:        getEnvBool("AUTH_DISABLED", false),
+		MaxImageBytes:       getEnvInt64("MAX_IMAGE_BYTES", 0),
+		MaxImageDimension:   int(getEnvInt64("MAX_IMAGE_DIMENSION", 10000)),
+		ImageEmbedMode:      strings.ToLower(getEnv("IMAGE_EMBED_MODE", "upload")),
+		ImageRehostExternal: getEnvBool("IMAGE_REHOST_EXTERNAL", false),
+		ImageRehostMaxBytes: getEnvInt64("IMAGE_REHOST_MAX_BYTES", 5<<20),
+
+		RabbitMQConfirms:       getEnvBool("RABBITMQ_PUBLISHER_CONFIRMS", false),
+		RabbitMQConfirmTimeout: getEnvDuration("RABBITMQ_CONFIRM_TIMEOUT", 5*time.Second),
+		RabbitMQMandatory:      getEnvBool("RABBITMQ_MANDATORY", false),
+		RabbitMQFailUnroutable: getEnvBool("RABBITMQ_FAIL_UNROUTABLE", false),
+		PublishEventRequired:   getEnvBool("PUBLISH_EVENT_REQUIRED", false),
+		HealthDegradedCode:     int(getEnvInt64("HEALTH_DEGRADED_CODE", 200)),
+		MaxInFlight:            int(getEnvInt64("MAX_IN_FLIGHT", 0)),
+		EnablePprof:            getEnvBool("ENABLE_PPROF", false),
+		Maintenance:            getEnvBool("MAINTENANCE_MODE", false),
+		MaintenanceRetryAfter:  getEnvDuration("MAINTENANCE_RETRY_AFTER", 60*time.Second),
+		BasePath:               normalizePath(getEnv("BASE_PATH", "")),
+		HealthPath:             cmp.Or(normalizePath(getEnv("HEALTH_PATH", "/health")), "/health"),
+		CORSAllowedOrigins:     getEnv("CORS_ALLOWED_ORIGINS", ""),
+		CORSMaxAge:             getEnvDuration("CORS_MAX_AGE", 600*time.Second),
+		ServerHeader:           getEnv("SERVER_HEADER", ""),
+		NoSniff:                getEnvBool("SECURITY_NOSNIFF", true),
+		FrameOptions:           optionalHeader("FRAME_OPTIONS", "DENY"),
+		PreviewCSP:             optionalHeader("PREVIEW_CSP", defaultPreviewCSP),
+		PreviewMaxAge:          getEnvDurationAllowZero("PREVIEW_MAX_AGE", 5*time.Minute),
+		SlugNormalizeMode:      strings.ToLower(getEnv("SLUG_NORMALIZE_MODE", "off")),
+		RequireJSONContentType: getEnvBool("REQUIRE_JSON_CONTENT_TYPE", true),
+
+		MaxHeaderBytes:    int(getEnvInt64("MAX_HEADER_BYTES", 1<<20)),
+		ReadHeaderTimeout: getEnvDuration("READ_HEADER_TIMEOUT", 5*time.Second),
+		UploadReadTimeout: getEnvDuration("UPLOAD_READ_TIMEOUT", 0),
+		HTTPKeepAlive:     getEnvBool("HTTP_KEEP_ALIVE", true),
+		HTTPIdleTimeout:   getEnvDuration("HTTP_IDLE_TIMEOUT", 60*time.Second),
+		HTTP2Cleartext:    getEnvBool("HTTP2_CLEARTEXT", false),
+
+		DraftContentRequiresKey: getEnvBool("DRAFT_CONTENT_REQUIRES_KEY", false),
+		AnonymousMaxPerPage:     int(getEnvInt64("ANONYMOUS_MAX_PER_PAGE", 0)),
+		MaxTagsPerPost:          int(getEnvInt64("MAX_TAGS_PER_POST", 10)),
+		MaxTagLength:            int(getEnvInt64("MAX_TAG_LENGTH", 32)),
+		StrictHeadings:          getEnvBool("STRICT_HEADINGS", false),
+		ReservedSlugs:           getEnv("RESERVED_SLUGS", ""),
+		PreviewTemplatePath:     getEnv("PREVIEW_TEMPLATE", ""),
+		SiteURL:                 strings.TrimSuffix(getEnv("SITE_URL", ""), "/"),
+		PostCacheSize:           int(getEnvInt64("POST_CACHE_SIZE", 0)),
+		PostCacheTTL:            getEnvDuration("POST_CACHE_TTL", 30*time.Second),
+		SlowQueryThreshold:      getEnvDuration("SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+		OutboxRetention:         getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		ContentCacheBytes:       getEnvInt64("CONTENT_CACHE_BYTES", 0),
+		ConsistencyWindow:       getEnvDuration("CONTENT_CONSISTENCY_WINDOW", 0),
+		PageOutOfRangeMode:      strings.ToLower(getEnv("PAGE_OUT_OF_RANGE_MODE", "empty")),
+		ContentMissingMode:      strings.ToLower(getEnv("CONTENT_MISSING_MODE", "error")),
+		ContentPlaceholder:      getEnv("CONTENT_PLACEHOLDER", ""),
+
+		StorageBackend:       getEnv("STORAGE_BACKEND", "s3"),
+		StorageDir:           getEnv("STORAGE_DIR", "./data"),
+		S3PublicRead:         getEnvBool("S3_PUBLIC_READ", false),
+		S3PublicReadContent:  getEnvBool("S3_PUBLIC_READ_CONTENT", false),
+		S3MultipartThreshold: getEnvInt64("S3_MULTIPART_THRESHOLD", 16<<20),
+		S3MaxConcurrency:     getEnvInt64("S3_MAX_CONCURRENCY", 64),
+		S3SignImageURLs:      getEnvBool("S3_SIGN_IMAGE_URLS", false),
+		S3SignedURLTTL:       getEnvDuration("S3_SIGNED_URL_TTL", 15*time.Minute),
+		S3SelfTest:           getEnvBool("S3_SELFTEST", false),
+
+		WorkerAction:              getEnv("WORKER_ACTION", "log"),
+		WorkerForwardURL:          getEnv("WORKER_FORWARD_URL", ""),
+		WorkerRepublishRoutingKey: getEnv("WORKER_REPUBLISH_ROUTING_KEY", "post.published.processed"),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+		SMTPTo:       getEnv("SMTP_TO", ""),
 	}
 }
 
@@ -37,3 +223,168 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// defaultPreviewCSP lets the preview page show images from anywhere and
+// nothing else; the default template needs no scripts or stylesheets.
+const defaultPreviewCSP = "default-src 'none'; img-src http: https: data:; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// optionalHeader reads a header value that defaults to fallback and is turned
+// off with "off".
+func optionalHeader(key, fallback string) string {
+	value := getEnv(key, fallback)
+	if strings.EqualFold(value, "off") {
+		return ""
+	}
+	return value
+}
+
+// databaseURL returns DATABASE_URL, or a DSN assembled from the DB_* variables
+// when it is unset. An invalid assembly is logged and yields "".
+func databaseURL() string {
+	if dsn := getEnv("DATABASE_URL", ""); dsn != "" {
+		return dsn
+	}
+	if os.Getenv("DB_HOST") == "" {
+		return ""
+	}
+	dsn, err := BuildDSN(
+		getEnv("DB_HOST", ""),
+		getEnv("DB_PORT", "5432"),
+		getEnv("DB_USER", ""),
+		getEnv("DB_PASSWORD", ""),
+		getEnv("DB_NAME", ""),
+		getEnv("DB_SSLMODE", "require"),
+	)
+	if err != nil {
+		slog.Default().Warn("cannot build database URL from DB_* variables", "error", err)
+		return ""
+	}
+	return dsn
+}
+
+// ListenAddr joins the bind host and port into a server address. An empty
+// host listens on all interfaces; IPv6 hosts are bracketed.
+func ListenAddr(host, port string) (string, error) {
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return "", fmt.Errorf("invalid PORT %q", port)
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host != "" && net.ParseIP(host) == nil && !validHostname(host) {
+		return "", fmt.Errorf("invalid BIND_ADDRESS %q", host)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func validHostname(host string) bool {
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, c := range label {
+			if !(c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// BuildDSN assembles a postgres:// URL, escaping the user, password and
+// database name so characters such as '@', ':' or '/' survive.
+func BuildDSN(host, port, user, password, name, sslmode string) (string, error) {
+	switch {
+	case host == "":
+		return "", errors.New("DB_HOST is required")
+	case user == "":
+		return "", errors.New("DB_USER is required")
+	case name == "":
+		return "", errors.New("DB_NAME is required")
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid DB_PORT %q", port)
+	}
+	if !slices.Contains(sslModes, sslmode) {
+		return "", fmt.Errorf("invalid DB_SSLMODE %q", sslmode)
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.User(user),
+		Host:     net.JoinHostPort(host, port),
+		Path:     "/" + name,
+		RawQuery: url.Values{"sslmode": {sslmode}}.Encode(),
+	}
+	if password != "" {
+		u.User = url.UserPassword(user, password)
+	}
+	dsn := u.String()
+	if _, err := url.Parse(dsn); err != nil {
+		return "", fmt.Errorf("assembled DSN is invalid: %w", err)
+	}
+	return dsn, nil
+}
+
+// normalizePath gives p a leading slash and strips trailing ones, so "api/v1/"
+// becomes "/api/v1". Empty and "/" both mean the root and return "".
+func normalizePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+func getEnvInt64(key string, fallback int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		slog.Default().Warn("invalid integer env var, using default", "key", key, "value", value)
+		return fallback
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Default().Warn("invalid boolean env var, using default", "key", key, "value", value)
+		return fallback
+	}
+	return b
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Default().Warn("invalid duration env var, using default", "key", key, "value", value)
+		return fallback
+	}
+	return d
+}
+
+// getEnvDurationAllowZero is getEnvDuration for settings where 0 turns the
+// feature off.
+func getEnvDurationAllowZero(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		slog.Default().Warn("invalid duration env var, using default", "key", key, "value", value)
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestBuildDSN(t *testing.T) {
+	dsn, err := BuildDSN("db.internal", "5433", "entries", "p@ss:w/rd?#%", "entries", "disable")
+	if err != nil {
+		t.Fatalf("BuildDSN: %v", err)
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("parse %q: %v", dsn, err)
+	}
+	if pw, _ := u.User.Password(); pw != "p@ss:w/rd?#%" {
+		t.Errorf("password round-trips as %q", pw)
+	}
+	if u.User.Username() != "entries" || u.Hostname() != "db.internal" || u.Port() != "5433" || u.Path != "/entries" {
+		t.Errorf("parsed %+v", u)
+	}
+	if got := u.Query().Get("sslmode"); got != "disable" {
+		t.Errorf("sslmode %q", got)
+	}
+
+	t.Run("no password", func(t *testing.T) {
+		dsn, err := BuildDSN("localhost", "5432", "entries", "", "entries", "require")
+		if err != nil {
+			t.Fatalf("BuildDSN: %v", err)
+		}
+		if want := "postgres://entries@localhost:5432/entries?sslmode=require"; dsn != want {
+			t.Errorf("dsn %q, want %q", dsn, want)
+		}
+	})
+
+	invalid := []struct {
+		name                                    string
+		host, port, user, password, db, sslmode string
+	}{
+		{"missing host", "", "5432", "u", "", "d", "require"},
+		{"missing user", "h", "5432", "", "", "d", "require"},
+		{"missing name", "h", "5432", "u", "", "", "require"},
+		{"bad port", "h", "postgres", "u", "", "d", "require"},
+		{"bad sslmode", "h", "5432", "u", "", "d", "sometimes"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := BuildDSN(tt.host, tt.port, tt.user, tt.password, tt.db, tt.sslmode); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestLoad_DatabaseURLFromParts(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("DB_HOST", "db")
+	t.Setenv("DB_USER", "entries")
+	t.Setenv("DB_PASSWORD", "s3cr3t!@")
+	t.Setenv("DB_NAME", "entries")
+	t.Setenv("DB_SSLMODE", "disable")
+
+	if got, want := Load().DatabaseURL, "postgres://entries:s3cr3t%21%40@db:5432/entries?sslmode=disable"; got != want {
+		t.Errorf("DatabaseURL %q, want %q", got, want)
+	}
+
+	t.Setenv("DATABASE_URL", "postgres://explicit")
+	if got := Load().DatabaseURL; got != "postgres://explicit" {
+		t.Errorf("DATABASE_URL should win, got %q", got)
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		host, port string
+		want       string
+		wantErr    bool
+	}{
+		{"", "8080", ":8080", false},
+		{"127.0.0.1", "8080", "127.0.0.1:8080", false},
+		{"::1", "9000", "[::1]:9000", false},
+		{"[::1]", "9000", "[::1]:9000", false},
+		{"localhost", "80", "localhost:80", false},
+		{"api.internal", "8080", "api.internal:8080", false},
+		{"", "http", "", true},
+		{"", "70000", "", true},
+		{"127.0.0.1:80", "8080", "", true},
+		{"bad host", "8080", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ListenAddr(tt.host, tt.port)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ListenAddr(%q, %q) = %q, %v; want %q, error %v", tt.host, tt.port, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLoad_PreviewMaxAge(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"":    5 * time.Minute,
+		"0":   0,
+		"90s": 90 * time.Second,
+		"-1s": 5 * time.Minute,
+	} {
+		t.Setenv("PREVIEW_MAX_AGE", value)
+		if got := Load().PreviewMaxAge; got != want {
+			t.Errorf("PREVIEW_MAX_AGE=%q: got %v, want %v", value, got, want)
+		}
+	}
+}
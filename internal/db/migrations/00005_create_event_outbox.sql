@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE event_outbox (
+    id         UUID PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    payload    JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at    TIMESTAMPTZ
+);
+
+CREATE INDEX idx_event_outbox_unsent ON event_outbox (created_at) WHERE sent_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS event_outbox;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN format TEXT NOT NULL DEFAULT 'markdown';
+ALTER TABLE posts ADD CONSTRAINT posts_format_check CHECK (format IN ('markdown', 'asciidoc', 'rst'));
+
+-- +goose Down
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_format_check;
+ALTER TABLE posts DROP COLUMN IF EXISTS format;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX idx_posts_tags ON posts USING GIN (tags);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_posts_tags;
+ALTER TABLE posts DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN canonical_url TEXT;
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN IF EXISTS canonical_url;
@@ -0,0 +1,7 @@
+-- +goose Up
+DROP INDEX IF EXISTS idx_posts_published_views;
+CREATE INDEX idx_posts_published_views ON posts (views DESC, created_at DESC, id DESC) WHERE status = 'published';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_posts_published_views;
+CREATE INDEX idx_posts_published_views ON posts (views DESC, created_at DESC) WHERE status = 'published';
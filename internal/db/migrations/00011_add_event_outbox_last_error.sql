@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE event_outbox ADD COLUMN last_error TEXT;
+CREATE INDEX idx_event_outbox_sent ON event_outbox (sent_at) WHERE sent_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_event_outbox_sent;
+ALTER TABLE event_outbox DROP COLUMN IF EXISTS last_error;
@@ -5,17 +5,34 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-type Post struct {
+type EventOutbox struct {
 	ID        uuid.UUID
-	Title     string
-	Slug      string
-	S3Key     string
-	Status    string
+	EventType string
+	Payload   json.RawMessage
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	SentAt    sql.NullTime
+	LastError sql.NullString
+}
+
+type Post struct {
+	ID               uuid.UUID
+	Title            string
+	Slug             string
+	S3Key            string
+	Status           string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	ContentSha256    string
+	Views            int64
+	Format           string
+	Tags             []string
+	CanonicalUrl     sql.NullString
+	RawContentSha256 string
 }
@@ -8,33 +8,41 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const countPosts = `-- name: CountPosts :one
 SELECT COUNT(*) FROM posts
-WHERE ($1::text IS NULL OR status = $1)
+WHERE ($1::text[] IS NULL OR status = ANY($1::text[]))
 `
 
-func (q *Queries) CountPosts(ctx context.Context, status sql.NullString) (int64, error) {
-	row := q.db.QueryRowContext(ctx, countPosts, status)
+func (q *Queries) CountPosts(ctx context.Context, statuses []string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPosts, pq.Array(statuses))
 	var count int64
 	err := row.Scan(&count)
 	return count, err
 }
 
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (title, slug, s3_key, status)
-VALUES ($1, $2, $3, $4)
-RETURNING id, title, slug, s3_key, status, created_at, updated_at
+INSERT INTO posts (title, slug, s3_key, status, content_sha256, format, tags, canonical_url, raw_content_sha256, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE($10::timestamptz, NOW()))
+RETURNING id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256
 `
 
 type CreatePostParams struct {
-	Title  string
-	Slug   string
-	S3Key  string
-	Status string
+	Title            string
+	Slug             string
+	S3Key            string
+	Status           string
+	ContentSha256    string
+	Format           string
+	Tags             []string
+	CanonicalUrl     sql.NullString
+	RawContentSha256 string
+	CreatedAt        sql.NullTime
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -43,6 +51,12 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Slug,
 		arg.S3Key,
 		arg.Status,
+		arg.ContentSha256,
+		arg.Format,
+		pq.Array(arg.Tags),
+		arg.CanonicalUrl,
+		arg.RawContentSha256,
+		arg.CreatedAt,
 	)
 	var i Post
 	err := row.Scan(
@@ -53,6 +67,12 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ContentSha256,
+		&i.Views,
+		&i.Format,
+		pq.Array(&i.Tags),
+		&i.CanonicalUrl,
+		&i.RawContentSha256,
 	)
 	return i, err
 }
@@ -66,8 +86,52 @@ func (q *Queries) DeletePostBySlug(ctx context.Context, slug string) error {
 	return err
 }
 
+const getNextPublishedSlug = `-- name: GetNextPublishedSlug :one
+SELECT slug FROM posts
+WHERE status = 'published' AND (created_at, id) > ($1::timestamptz, $2::uuid)
+ORDER BY created_at, id
+LIMIT 1
+`
+
+type GetNextPublishedSlugParams struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) GetNextPublishedSlug(ctx context.Context, arg GetNextPublishedSlugParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, getNextPublishedSlug, arg.CreatedAt, arg.ID)
+	var slug string
+	err := row.Scan(&slug)
+	return slug, err
+}
+
+const getPostByID = `-- name: GetPostByID :one
+SELECT id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256 FROM posts WHERE id = $1
+`
+
+func (q *Queries) GetPostByID(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByID, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Slug,
+		&i.S3Key,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ContentSha256,
+		&i.Views,
+		&i.Format,
+		pq.Array(&i.Tags),
+		&i.CanonicalUrl,
+		&i.RawContentSha256,
+	)
+	return i, err
+}
+
 const getPostBySlug = `-- name: GetPostBySlug :one
-SELECT id, title, slug, s3_key, status, created_at, updated_at FROM posts WHERE slug = $1
+SELECT id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256 FROM posts WHERE slug = $1
 `
 
 func (q *Queries) GetPostBySlug(ctx context.Context, slug string) (Post, error) {
@@ -81,25 +145,141 @@ func (q *Queries) GetPostBySlug(ctx context.Context, slug string) (Post, error)
 		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ContentSha256,
+		&i.Views,
+		&i.Format,
+		pq.Array(&i.Tags),
+		&i.CanonicalUrl,
+		&i.RawContentSha256,
 	)
 	return i, err
 }
 
+const getPreviousPublishedSlug = `-- name: GetPreviousPublishedSlug :one
+SELECT slug FROM posts
+WHERE status = 'published' AND (created_at, id) < ($1::timestamptz, $2::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT 1
+`
+
+type GetPreviousPublishedSlugParams struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) GetPreviousPublishedSlug(ctx context.Context, arg GetPreviousPublishedSlugParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, getPreviousPublishedSlug, arg.CreatedAt, arg.ID)
+	var slug string
+	err := row.Scan(&slug)
+	return slug, err
+}
+
+const incrementPostViews = `-- name: IncrementPostViews :exec
+UPDATE posts SET views = views + $1
+WHERE id = $2
+`
+
+type IncrementPostViewsParams struct {
+	Delta int64
+	ID    uuid.UUID
+}
+
+func (q *Queries) IncrementPostViews(ctx context.Context, arg IncrementPostViewsParams) error {
+	_, err := q.db.ExecContext(ctx, incrementPostViews, arg.Delta, arg.ID)
+	return err
+}
+
+const listExistingSlugs = `-- name: ListExistingSlugs :many
+SELECT slug FROM posts WHERE slug = ANY($1::text[])
+`
+
+func (q *Queries) ListExistingSlugs(ctx context.Context, slugs []string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listExistingSlugs, pq.Array(slugs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		items = append(items, slug)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listPosts = `-- name: ListPosts :many
-SELECT id, title, slug, s3_key, status, created_at, updated_at FROM posts
-WHERE ($3::text IS NULL OR status = $3)
-ORDER BY created_at DESC
+SELECT id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256 FROM posts
+WHERE ($3::text[] IS NULL OR status = ANY($3::text[]))
+ORDER BY created_at DESC, id DESC
 LIMIT $1 OFFSET $2
 `
 
 type ListPostsParams struct {
+	Limit    int32
+	Offset   int32
+	Statuses []string
+}
+
+func (q *Queries) ListPosts(ctx context.Context, arg ListPostsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, listPosts, arg.Limit, arg.Offset, pq.Array(arg.Statuses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Slug,
+			&i.S3Key,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ContentSha256,
+			&i.Views,
+			&i.Format,
+			pq.Array(&i.Tags),
+			&i.CanonicalUrl,
+			&i.RawContentSha256,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPostsByViews = `-- name: ListPostsByViews :many
+SELECT id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256 FROM posts
+WHERE status = 'published'
+ORDER BY views DESC, created_at DESC, id DESC
+LIMIT $1 OFFSET $2
+`
+
+type ListPostsByViewsParams struct {
 	Limit  int32
 	Offset int32
-	Status sql.NullString
 }
 
-func (q *Queries) ListPosts(ctx context.Context, arg ListPostsParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, listPosts, arg.Limit, arg.Offset, arg.Status)
+func (q *Queries) ListPostsByViews(ctx context.Context, arg ListPostsByViewsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, listPostsByViews, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +295,12 @@ func (q *Queries) ListPosts(ctx context.Context, arg ListPostsParams) ([]Post, e
 			&i.Status,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.ContentSha256,
+			&i.Views,
+			&i.Format,
+			pq.Array(&i.Tags),
+			&i.CanonicalUrl,
+			&i.RawContentSha256,
 		); err != nil {
 			return nil, err
 		}
@@ -129,10 +315,45 @@ func (q *Queries) ListPosts(ctx context.Context, arg ListPostsParams) ([]Post, e
 	return items, nil
 }
 
+const listTagCounts = `-- name: ListTagCounts :many
+SELECT tag::text AS tag, COUNT(*) AS count FROM posts, unnest(tags) AS tag
+WHERE ($1::text IS NULL OR status = $1)
+GROUP BY tag
+ORDER BY count DESC, tag
+`
+
+type ListTagCountsRow struct {
+	Tag   string
+	Count int64
+}
+
+func (q *Queries) ListTagCounts(ctx context.Context, status sql.NullString) ([]ListTagCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTagCounts, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTagCountsRow
+	for rows.Next() {
+		var i ListTagCountsRow
+		if err := rows.Scan(&i.Tag, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const publishPost = `-- name: PublishPost :one
 UPDATE posts SET status = 'published', updated_at = NOW()
 WHERE slug = $1 AND status = 'draft'
-RETURNING id, title, slug, s3_key, status, created_at, updated_at
+RETURNING id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256
 `
 
 func (q *Queries) PublishPost(ctx context.Context, slug string) (Post, error) {
@@ -146,21 +367,42 @@ func (q *Queries) PublishPost(ctx context.Context, slug string) (Post, error) {
 		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ContentSha256,
+		&i.Views,
+		&i.Format,
+		pq.Array(&i.Tags),
+		&i.CanonicalUrl,
+		&i.RawContentSha256,
 	)
 	return i, err
 }
 
+const revertPublishPost = `-- name: RevertPublishPost :exec
+UPDATE posts SET status = 'draft', updated_at = NOW()
+WHERE id = $1 AND status = 'published'
+`
+
+func (q *Queries) RevertPublishPost(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revertPublishPost, id)
+	return err
+}
+
 const updatePost = `-- name: UpdatePost :one
-UPDATE posts SET title = $2, slug = $3, s3_key = $4, updated_at = NOW()
-WHERE id = $1
-RETURNING id, title, slug, s3_key, status, created_at, updated_at
+UPDATE posts SET title = $2, slug = $3, s3_key = $4, content_sha256 = $5, tags = $6, canonical_url = $7, raw_content_sha256 = $8, updated_at = NOW()
+WHERE id = $1 AND ($9::text IS NULL OR content_sha256 = $9)
+RETURNING id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256
 `
 
 type UpdatePostParams struct {
-	ID    uuid.UUID
-	Title string
-	Slug  string
-	S3Key string
+	ID                    uuid.UUID
+	Title                 string
+	Slug                  string
+	S3Key                 string
+	ContentSha256         string
+	Tags                  []string
+	CanonicalUrl          sql.NullString
+	RawContentSha256      string
+	ExpectedContentSha256 sql.NullString
 }
 
 func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) (Post, error) {
@@ -169,6 +411,11 @@ func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) (Post, e
 		arg.Title,
 		arg.Slug,
 		arg.S3Key,
+		arg.ContentSha256,
+		pq.Array(arg.Tags),
+		arg.CanonicalUrl,
+		arg.RawContentSha256,
+		arg.ExpectedContentSha256,
 	)
 	var i Post
 	err := row.Scan(
@@ -179,6 +426,12 @@ func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) (Post, e
 		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ContentSha256,
+		&i.Views,
+		&i.Format,
+		pq.Array(&i.Tags),
+		&i.CanonicalUrl,
+		&i.RawContentSha256,
 	)
 	return i, err
 }
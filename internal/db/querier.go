@@ -7,15 +7,32 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type Querier interface {
-	CountPosts(ctx context.Context, status sql.NullString) (int64, error)
+	CountPosts(ctx context.Context, statuses []string) (int64, error)
 	CreatePost(ctx context.Context, arg CreatePostParams) (Post, error)
 	DeletePostBySlug(ctx context.Context, slug string) error
+	DeleteSentOutboxEvents(ctx context.Context, sentBefore time.Time) (int64, error)
+	DeleteUnsentOutboxEvent(ctx context.Context, id uuid.UUID) error
+	GetNextPublishedSlug(ctx context.Context, arg GetNextPublishedSlugParams) (string, error)
+	GetPostByID(ctx context.Context, id uuid.UUID) (Post, error)
 	GetPostBySlug(ctx context.Context, slug string) (Post, error)
+	GetPreviousPublishedSlug(ctx context.Context, arg GetPreviousPublishedSlugParams) (string, error)
+	IncrementPostViews(ctx context.Context, arg IncrementPostViewsParams) error
+	InsertOutboxEvent(ctx context.Context, arg InsertOutboxEventParams) error
+	ListExistingSlugs(ctx context.Context, slugs []string) ([]string, error)
 	ListPosts(ctx context.Context, arg ListPostsParams) ([]Post, error)
+	ListPostsByViews(ctx context.Context, arg ListPostsByViewsParams) ([]Post, error)
+	ListTagCounts(ctx context.Context, status sql.NullString) ([]ListTagCountsRow, error)
+	ListUnsentOutboxEvents(ctx context.Context, arg ListUnsentOutboxEventsParams) ([]EventOutbox, error)
+	MarkOutboxEventDead(ctx context.Context, arg MarkOutboxEventDeadParams) error
+	MarkOutboxEventSent(ctx context.Context, id uuid.UUID) error
 	PublishPost(ctx context.Context, slug string) (Post, error)
+	RevertPublishPost(ctx context.Context, id uuid.UUID) error
 	UpdatePost(ctx context.Context, arg UpdatePostParams) (Post, error)
 }
 
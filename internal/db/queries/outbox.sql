@@ -0,0 +1,22 @@
+-- name: InsertOutboxEvent :exec
+INSERT INTO event_outbox (id, event_type, payload)
+VALUES ($1, $2, $3);
+
+-- name: ListUnsentOutboxEvents :many
+SELECT id, event_type, payload, created_at, sent_at, last_error FROM event_outbox
+WHERE sent_at IS NULL AND created_at <= sqlc.arg('created_before')
+ORDER BY created_at
+LIMIT sqlc.arg('batch_size');
+
+-- name: MarkOutboxEventSent :exec
+UPDATE event_outbox SET sent_at = NOW() WHERE id = $1;
+
+-- name: MarkOutboxEventDead :exec
+UPDATE event_outbox SET sent_at = NOW(), last_error = $2 WHERE id = $1;
+
+-- name: DeleteUnsentOutboxEvent :exec
+DELETE FROM event_outbox WHERE id = $1 AND sent_at IS NULL;
+
+-- name: DeleteSentOutboxEvents :execrows
+DELETE FROM event_outbox
+WHERE sent_at < sqlc.arg('sent_before')::timestamptz AND last_error IS NULL;
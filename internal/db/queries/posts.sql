@@ -1,30 +1,68 @@
 -- name: CreatePost :one
-INSERT INTO posts (title, slug, s3_key, status)
-VALUES ($1, $2, $3, $4)
-RETURNING id, title, slug, s3_key, status, created_at, updated_at;
+INSERT INTO posts (title, slug, s3_key, status, content_sha256, format, tags, canonical_url, raw_content_sha256, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE(sqlc.narg('created_at')::timestamptz, NOW()))
+RETURNING id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256;
+
+-- name: GetPostByID :one
+SELECT id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256 FROM posts WHERE id = $1;
 
 -- name: GetPostBySlug :one
-SELECT id, title, slug, s3_key, status, created_at, updated_at FROM posts WHERE slug = $1;
+SELECT id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256 FROM posts WHERE slug = $1;
+
+-- name: GetPreviousPublishedSlug :one
+SELECT slug FROM posts
+WHERE status = 'published' AND (created_at, id) < (sqlc.arg('created_at')::timestamptz, sqlc.arg('id')::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT 1;
+
+-- name: GetNextPublishedSlug :one
+SELECT slug FROM posts
+WHERE status = 'published' AND (created_at, id) > (sqlc.arg('created_at')::timestamptz, sqlc.arg('id')::uuid)
+ORDER BY created_at, id
+LIMIT 1;
+
+-- name: ListExistingSlugs :many
+SELECT slug FROM posts WHERE slug = ANY(sqlc.arg('slugs')::text[]);
 
 -- name: ListPosts :many
-SELECT id, title, slug, s3_key, status, created_at, updated_at FROM posts
-WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
-ORDER BY created_at DESC
+SELECT id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256 FROM posts
+WHERE (sqlc.narg('statuses')::text[] IS NULL OR status = ANY(sqlc.narg('statuses')::text[]))
+ORDER BY created_at DESC, id DESC
+LIMIT $1 OFFSET $2;
+
+-- name: ListPostsByViews :many
+SELECT id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256 FROM posts
+WHERE status = 'published'
+ORDER BY views DESC, created_at DESC, id DESC
 LIMIT $1 OFFSET $2;
 
 -- name: CountPosts :one
 SELECT COUNT(*) FROM posts
-WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'));
+WHERE (sqlc.narg('statuses')::text[] IS NULL OR status = ANY(sqlc.narg('statuses')::text[]));
+
+-- name: ListTagCounts :many
+SELECT tag::text AS tag, COUNT(*) AS count FROM posts, unnest(tags) AS tag
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+GROUP BY tag
+ORDER BY count DESC, tag;
 
 -- name: UpdatePost :one
-UPDATE posts SET title = $2, slug = $3, s3_key = $4, updated_at = NOW()
-WHERE id = $1
-RETURNING id, title, slug, s3_key, status, created_at, updated_at;
+UPDATE posts SET title = $2, slug = $3, s3_key = $4, content_sha256 = $5, tags = $6, canonical_url = $7, raw_content_sha256 = $8, updated_at = NOW()
+WHERE id = $1 AND (sqlc.narg('expected_content_sha256')::text IS NULL OR content_sha256 = sqlc.narg('expected_content_sha256'))
+RETURNING id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256;
 
 -- name: DeletePostBySlug :exec
 DELETE FROM posts WHERE slug = $1;
 
+-- name: IncrementPostViews :exec
+UPDATE posts SET views = views + sqlc.arg('delta')
+WHERE id = sqlc.arg('id');
+
 -- name: PublishPost :one
 UPDATE posts SET status = 'published', updated_at = NOW()
 WHERE slug = $1 AND status = 'draft'
-RETURNING id, title, slug, s3_key, status, created_at, updated_at;
+RETURNING id, title, slug, s3_key, status, created_at, updated_at, content_sha256, views, format, tags, canonical_url, raw_content_sha256;
+
+-- name: RevertPublishPost :exec
+UPDATE posts SET status = 'draft', updated_at = NOW()
+WHERE id = $1 AND status = 'published';
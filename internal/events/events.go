@@ -1,6 +1,7 @@
 package events
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,26 +9,50 @@ import (
 
 const TypePostPublished = "post.published"
 
+// PostPublishedSchemaVersion is the envelope version written by this build.
+// Messages without a version predate versioning and are treated as 1.
+const PostPublishedSchemaVersion = 1
+
 type PostPublishedPayload struct {
 	PostID uuid.UUID `json:"post_id"`
 	Slug   string    `json:"slug"`
 	Title  string    `json:"title"`
+	// URL is the post's public address: its canonical URL override, or its
+	// page under the configured site URL. Empty when neither is known.
+	URL string `json:"url,omitempty"`
+	// Excerpt is the first paragraph of markdown content as plain text.
+	// Empty for other formats or when the content could not be read.
+	Excerpt string `json:"excerpt,omitempty"`
 }
 
 type PostPublished struct {
-	Type      string               `json:"type"`
-	Timestamp time.Time            `json:"timestamp"`
-	Payload   PostPublishedPayload `json:"payload"`
+	// ID is unique per event, so consumers can drop redelivered copies.
+	ID            uuid.UUID            `json:"id"`
+	SchemaVersion int                  `json:"schema_version"`
+	Type          string               `json:"type"`
+	Timestamp     time.Time            `json:"timestamp"`
+	Payload       PostPublishedPayload `json:"payload"`
+}
+
+func (e *PostPublished) UnmarshalJSON(data []byte) error {
+	type envelope PostPublished
+	var v envelope
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.SchemaVersion == 0 {
+		v.SchemaVersion = 1
+	}
+	*e = PostPublished(v)
+	return nil
 }
 
-func NewPostPublished(postID uuid.UUID, slug, title string) PostPublished {
+func NewPostPublished(payload PostPublishedPayload, at time.Time) PostPublished {
 	return PostPublished{
-		Type:      TypePostPublished,
-		Timestamp: time.Now().UTC(),
-		Payload: PostPublishedPayload{
-			PostID: postID,
-			Slug:   slug,
-			Title:  title,
-		},
+		ID:            uuid.New(),
+		SchemaVersion: PostPublishedSchemaVersion,
+		Type:          TypePostPublished,
+		Timestamp:     at.UTC(),
+		Payload:       payload,
 	}
 }
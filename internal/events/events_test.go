@@ -0,0 +1,69 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPostPublished_RoundTrip(t *testing.T) {
+	want := NewPostPublished(PostPublishedPayload{
+		PostID:  uuid.New(),
+		Slug:    "hello",
+		Title:   "Hello",
+		URL:     "https://example.com/posts/hello/preview",
+		Excerpt: "First paragraph.",
+	}, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if want.ID == uuid.Nil || want.SchemaVersion != PostPublishedSchemaVersion {
+		t.Fatalf("constructor: id=%v version=%d", want.ID, want.SchemaVersion)
+	}
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var got PostPublished
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.ID != want.ID || got.SchemaVersion != want.SchemaVersion || got.Payload != want.Payload || !got.Timestamp.Equal(want.Timestamp) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestPostPublished_OmitsEmptyEnrichment(t *testing.T) {
+	data, err := json.Marshal(NewPostPublished(PostPublishedPayload{PostID: uuid.New(), Slug: "hello", Title: "Hello"}, time.Now()))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var raw struct {
+		Payload map[string]any `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	for _, field := range []string{"url", "excerpt"} {
+		if _, ok := raw.Payload[field]; ok {
+			t.Errorf("payload has %q, want it omitted when empty", field)
+		}
+	}
+}
+
+func TestPostPublished_UnmarshalLegacy(t *testing.T) {
+	legacy := `{"type":"post.published","timestamp":"2024-05-01T10:00:00Z","payload":{"post_id":"6f1c2d3e-0000-4000-8000-000000000001","slug":"old","title":"Old"}}`
+	var e PostPublished
+	if err := json.Unmarshal([]byte(legacy), &e); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if e.SchemaVersion != 1 {
+		t.Errorf("SchemaVersion = %d, want 1", e.SchemaVersion)
+	}
+	if e.ID != uuid.Nil {
+		t.Errorf("ID = %v, want nil for legacy message", e.ID)
+	}
+	if e.Type != TypePostPublished || e.Payload.Slug != "old" {
+		t.Errorf("got %+v", e)
+	}
+}
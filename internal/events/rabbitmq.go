@@ -3,8 +3,11 @@ package events
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -15,14 +18,104 @@ const (
 	QueueName    = "newsletter.post_published"
 )
 
+const defaultConfirmTimeout = 5 * time.Second
+
+// ErrPublishNacked is returned in confirm mode when the broker refuses a
+// message, or the channel closes before the broker answers.
+var ErrPublishNacked = errors.New("broker did not accept message")
+
+// ErrUnroutable is returned when FailUnroutable is set and no queue is bound
+// to receive a message.
+var ErrUnroutable = errors.New("no queue bound for message")
+
+// RabbitMQConfig holds the optional publisher settings.
+type RabbitMQConfig struct {
+	// RoutingKey defaults to RoutingKey.
+	RoutingKey string
+	// Confirm puts the channel in confirm mode, so PublishPostPublished only
+	// returns nil once the broker has accepted the message. Off, a publish
+	// succeeds as soon as the message is handed to the channel.
+	Confirm bool
+	// ConfirmTimeout bounds the wait for the broker's answer in confirm
+	// mode. Defaults to 5s.
+	ConfirmTimeout time.Duration
+	// Mandatory publishes with the mandatory flag, so the broker hands back
+	// messages no queue is bound to receive instead of dropping them. Returned
+	// messages are logged.
+	Mandatory bool
+	// FailUnroutable makes PublishPostPublished fail with ErrUnroutable for a
+	// returned message. It implies Mandatory and needs Confirm, since only
+	// the broker's ack tells that no return is coming.
+	FailUnroutable bool
+	// Logger receives returned messages. Defaults to slog.Default.
+	Logger *slog.Logger
+}
+
+// confirmation is the broker's pending answer to one message.
+type confirmation interface {
+	WaitContext(ctx context.Context) (bool, error)
+}
+
+// publishChannel is the part of an AMQP channel the publisher uses.
+type publishChannel interface {
+	// publish returns a nil confirmation when the channel is not in confirm
+	// mode.
+	publish(ctx context.Context, routingKey string, mandatory bool, msg amqp.Publishing) (confirmation, error)
+	Close() error
+}
+
+type amqpChannel struct {
+	*amqp.Channel
+}
+
+func (c amqpChannel) publish(ctx context.Context, routingKey string, mandatory bool, msg amqp.Publishing) (confirmation, error) {
+	dc, err := c.PublishWithDeferredConfirmWithContext(ctx, ExchangeName, routingKey, mandatory, false, msg)
+	if err != nil || dc == nil {
+		return nil, err
+	}
+	return dc, nil
+}
+
 type RabbitMQPublisher struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
+	conn           *amqp.Connection
+	channel        publishChannel
+	routingKey     string
+	confirmTimeout time.Duration
+	mandatory      bool
+	failUnroutable bool
+	// returns is nil unless mandatory is set.
+	returns *returnWatcher
 	mu      sync.Mutex
 	once    sync.Once
 }
 
 func NewRabbitMQPublisher(url string) (*RabbitMQPublisher, error) {
+	return NewRabbitMQPublisherWithConfig(url, RabbitMQConfig{})
+}
+
+// NewRabbitMQPublisherWithRoutingKey publishes to ExchangeName under routingKey
+// instead of the default post.published key.
+func NewRabbitMQPublisherWithRoutingKey(url, routingKey string) (*RabbitMQPublisher, error) {
+	return NewRabbitMQPublisherWithConfig(url, RabbitMQConfig{RoutingKey: routingKey})
+}
+
+func NewRabbitMQPublisherWithConfig(url string, cfg RabbitMQConfig) (*RabbitMQPublisher, error) {
+	routingKey := cfg.RoutingKey
+	if routingKey == "" {
+		routingKey = RoutingKey
+	}
+	confirmTimeout := cfg.ConfirmTimeout
+	if confirmTimeout <= 0 {
+		confirmTimeout = defaultConfirmTimeout
+	}
+	if cfg.FailUnroutable && !cfg.Confirm {
+		return nil, errors.New("failing unroutable publishes needs publisher confirms")
+	}
+	mandatory := cfg.Mandatory || cfg.FailUnroutable
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
 	conn, err := amqp.Dial(url)
 	if err != nil {
 		return nil, fmt.Errorf("dial rabbitmq: %w", err)
@@ -37,7 +130,27 @@ func NewRabbitMQPublisher(url string) (*RabbitMQPublisher, error) {
 		_ = conn.Close()
 		return nil, fmt.Errorf("declare exchange: %w", err)
 	}
-	return &RabbitMQPublisher{conn: conn, channel: ch}, nil
+	if cfg.Confirm {
+		if err := ch.Confirm(false); err != nil {
+			_ = ch.Close()
+			_ = conn.Close()
+			return nil, fmt.Errorf("enable publisher confirms: %w", err)
+		}
+	}
+	p := &RabbitMQPublisher{
+		conn:           conn,
+		channel:        amqpChannel{ch},
+		routingKey:     routingKey,
+		confirmTimeout: confirmTimeout,
+		mandatory:      mandatory,
+		failUnroutable: cfg.FailUnroutable,
+	}
+	if mandatory {
+		// Unbuffered, so a return is taken before the broker's ack for the
+		// same message is delivered; see returnWatcher.wasReturned.
+		p.returns = newReturnWatcher(ch.NotifyReturn(make(chan amqp.Return)), cfg.FailUnroutable, logger)
+	}
+	return p, nil
 }
 
 func (p *RabbitMQPublisher) PublishPostPublished(ctx context.Context, e PostPublished) error {
@@ -46,18 +159,36 @@ func (p *RabbitMQPublisher) PublishPostPublished(ctx context.Context, e PostPubl
 		return fmt.Errorf("marshal event: %w", err)
 	}
 	p.mu.Lock()
-	defer p.mu.Unlock()
 	if p.channel == nil {
+		p.mu.Unlock()
 		return fmt.Errorf("publisher closed")
 	}
-	err = p.channel.PublishWithContext(ctx, ExchangeName, RoutingKey, false, false, amqp.Publishing{
+	conf, err := p.channel.publish(ctx, p.routingKey, p.mandatory, amqp.Publishing{
 		ContentType:  "application/json",
+		MessageId:    e.ID.String(),
 		Body:         body,
 		DeliveryMode: amqp.Persistent,
 	})
+	p.mu.Unlock()
 	if err != nil {
 		return fmt.Errorf("publish: %w", err)
 	}
+	if conf == nil {
+		return nil
+	}
+	// The wait runs unlocked so concurrent publishes share the round trip.
+	waitCtx, cancel := context.WithTimeout(ctx, p.confirmTimeout)
+	defer cancel()
+	acked, err := conf.WaitContext(waitCtx)
+	if err != nil {
+		return fmt.Errorf("wait for publish confirm: %w", err)
+	}
+	if !acked {
+		return fmt.Errorf("publish: %w", ErrPublishNacked)
+	}
+	if p.failUnroutable && p.returns.wasReturned(e.ID.String()) {
+		return fmt.Errorf("publish: %w", ErrUnroutable)
+	}
 	return nil
 }
 
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type fakeConfirmation struct {
+	acked bool
+	// wait blocks WaitContext until the context ends when set.
+	wait bool
+}
+
+func (c fakeConfirmation) WaitContext(ctx context.Context) (bool, error) {
+	if c.wait {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	return c.acked, nil
+}
+
+type fakeChannel struct {
+	conf      confirmation
+	published []amqp.Publishing
+	// returns, when set, receives every mandatory message back, as if no
+	// queue were bound.
+	returns chan amqp.Return
+}
+
+func (c *fakeChannel) publish(_ context.Context, routingKey string, mandatory bool, msg amqp.Publishing) (confirmation, error) {
+	c.published = append(c.published, msg)
+	if mandatory && c.returns != nil {
+		c.returns <- amqp.Return{
+			ReplyCode:  amqp.NoRoute,
+			ReplyText:  "NO_ROUTE",
+			Exchange:   ExchangeName,
+			RoutingKey: routingKey,
+			MessageId:  msg.MessageId,
+		}
+	}
+	return c.conf, nil
+}
+
+func (c *fakeChannel) Close() error { return nil }
+
+func TestRabbitMQPublisher_Confirms(t *testing.T) {
+	evt := NewPostPublished(PostPublishedPayload{PostID: uuid.New(), Slug: "hello", Title: "Hello"}, time.Now())
+	for _, tc := range []struct {
+		name    string
+		conf    confirmation
+		wantErr error
+	}{
+		{"confirms off", nil, nil},
+		{"ack", fakeConfirmation{acked: true}, nil},
+		{"nack", fakeConfirmation{acked: false}, ErrPublishNacked},
+		{"no answer", fakeConfirmation{wait: true}, context.DeadlineExceeded},
+	} {
+		ch := &fakeChannel{conf: tc.conf}
+		p := &RabbitMQPublisher{channel: ch, routingKey: RoutingKey, confirmTimeout: 10 * time.Millisecond}
+		err := p.PublishPostPublished(context.Background(), evt)
+		if tc.wantErr == nil && err != nil {
+			t.Errorf("%s: PublishPostPublished: %v", tc.name, err)
+		}
+		if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.wantErr)
+		}
+		if len(ch.published) != 1 {
+			t.Errorf("%s: published %d messages, want 1", tc.name, len(ch.published))
+		}
+	}
+}
+
+func TestRabbitMQPublisher_Unroutable(t *testing.T) {
+	for _, failUnroutable := range []bool{false, true} {
+		returns := make(chan amqp.Return)
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&logs, nil))
+		ch := &fakeChannel{conf: fakeConfirmation{acked: true}, returns: returns}
+		p := &RabbitMQPublisher{
+			channel:        ch,
+			routingKey:     RoutingKey,
+			confirmTimeout: time.Second,
+			mandatory:      true,
+			failUnroutable: failUnroutable,
+			returns:        newReturnWatcher(returns, failUnroutable, logger),
+		}
+		evt := NewPostPublished(PostPublishedPayload{PostID: uuid.New(), Slug: "hello", Title: "Hello"}, time.Now())
+		err := p.PublishPostPublished(context.Background(), evt)
+		if failUnroutable && !errors.Is(err, ErrUnroutable) {
+			t.Errorf("failUnroutable: err = %v, want ErrUnroutable", err)
+		}
+		if !failUnroutable && err != nil {
+			t.Errorf("PublishPostPublished: %v", err)
+		}
+		close(returns)
+		<-p.returns.done
+		if !strings.Contains(logs.String(), "returned unroutable") || !strings.Contains(logs.String(), evt.ID.String()) {
+			t.Errorf("failUnroutable=%v: log = %q, want the returned message logged", failUnroutable, logs.String())
+		}
+	}
+}
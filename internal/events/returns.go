@@ -0,0 +1,77 @@
+package events
+
+import (
+	"log/slog"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// returnWatcher logs messages the broker hands back as unroutable and, when
+// tracking, remembers their message IDs until asked about them.
+type returnWatcher struct {
+	returns <-chan amqp.Return
+	track   bool
+	logger  *slog.Logger
+	queries chan returnQuery
+	// done is closed once returns is closed, with the channel.
+	done chan struct{}
+}
+
+type returnQuery struct {
+	id    string
+	reply chan bool
+}
+
+func newReturnWatcher(returns <-chan amqp.Return, track bool, logger *slog.Logger) *returnWatcher {
+	w := &returnWatcher{
+		returns: returns,
+		track:   track,
+		logger:  logger,
+		queries: make(chan returnQuery),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *returnWatcher) run() {
+	defer close(w.done)
+	returned := make(map[string]struct{})
+	for {
+		select {
+		case ret, ok := <-w.returns:
+			if !ok {
+				return
+			}
+			w.logger.Warn("event returned unroutable; no queue is bound for it",
+				"exchange", ret.Exchange,
+				"routing_key", ret.RoutingKey,
+				"message_id", ret.MessageId,
+				"reply_code", ret.ReplyCode,
+				"reply_text", ret.ReplyText,
+			)
+			if w.track {
+				returned[ret.MessageId] = struct{}{}
+			}
+		case q := <-w.queries:
+			_, ok := returned[q.id]
+			delete(returned, q.id)
+			q.reply <- ok
+		}
+	}
+}
+
+// wasReturned reports whether the message with id came back unroutable. It
+// must be called after the broker acks the message. The broker sends a return
+// before the ack, and the client delivers the return on the unbuffered
+// channel before it processes the ack, so run has recorded it by the time it
+// serves the query.
+func (w *returnWatcher) wasReturned(id string) bool {
+	reply := make(chan bool, 1)
+	select {
+	case w.queries <- returnQuery{id: id, reply: reply}:
+		return <-reply
+	case <-w.done:
+		return false
+	}
+}
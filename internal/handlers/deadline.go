@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// uploadWriteWindow is how long after the read deadline an upload's response
+// may take to write.
+const uploadWriteWindow = 15 * time.Second
+
+// extendUploadDeadline gives the request body until h.uploadReadTimeout from
+// now to arrive, past the server's ReadTimeout, so large uploads over slow
+// links are not cut off while other routes keep the short default. The write
+// deadline moves too: the server's WriteTimeout counts from the end of the
+// headers and would otherwise expire before a slow upload is answered. A zero
+// timeout leaves the server's deadlines in place.
+func (h *PostsHandler) extendUploadDeadline(w http.ResponseWriter, r *http.Request) {
+	if h.uploadReadTimeout <= 0 {
+		return
+	}
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(h.uploadReadTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		h.logger.Warn("extend upload read deadline failed", "path", r.URL.Path, "error", err)
+		return
+	}
+	if err := rc.SetWriteDeadline(deadline.Add(uploadWriteWindow)); err != nil {
+		h.logger.Warn("extend upload write deadline failed", "path", r.URL.Path, "error", err)
+	}
+}
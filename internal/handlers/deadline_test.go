@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestExtendUploadDeadline(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		timeout time.Duration
+		wantOK  bool
+	}{
+		{"server deadline", 0, false},
+		{"extended", 5 * time.Second, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &PostsHandler{logger: slog.Default(), uploadReadTimeout: tt.timeout}
+			srv := &http.Server{
+				ReadTimeout:  200 * time.Millisecond,
+				WriteTimeout: 200 * time.Millisecond,
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					h.extendUploadDeadline(w, r)
+					if _, err := io.ReadAll(r.Body); err != nil {
+						return
+					}
+					w.WriteHeader(http.StatusNoContent)
+				}),
+			}
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen: %v", err)
+			}
+			go srv.Serve(ln)
+			t.Cleanup(func() { srv.Close() })
+
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			// Send the headers, then trickle the body in past the server's
+			// ReadTimeout.
+			if _, err := io.WriteString(conn, "PUT / HTTP/1.1\r\nHost: x\r\nContent-Length: 4\r\n\r\nab"); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			time.Sleep(400 * time.Millisecond)
+			_, _ = io.WriteString(conn, "cd")
+
+			_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			ok := err == nil && resp.StatusCode == http.StatusNoContent
+			if ok != tt.wantOK {
+				t.Errorf("upload succeeded = %v, want %v (err %v)", ok, tt.wantOK, err)
+			}
+		})
+	}
+}
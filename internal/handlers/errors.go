@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/jeremyjsx/entries/internal/middleware"
+	"github.com/jeremyjsx/entries/internal/posts"
 )
 
 type APIError struct {
@@ -15,12 +20,23 @@ type APIError struct {
 	Details   map[string]string `json:"details,omitempty"`
 }
 
+// encodeFailedBody is sent when a response cannot be encoded.
+const encodeFailedBody = `{"error":{"code":"INTERNAL_ERROR","message":"internal server error"}}` + "\n"
+
+// writeJSON encodes data before writing anything, so an encoding failure
+// becomes a 500 rather than a truncated body under the intended status.
 func writeJSON(w http.ResponseWriter, status int, data any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(data); err != nil {
 		slog.Default().Error("writeJSON encode failed", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = io.WriteString(w, encodeFailedBody)
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
 }
 
 func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string, details map[string]string) {
@@ -33,3 +49,26 @@ func writeError(w http.ResponseWriter, r *http.Request, status int, code, messag
 		},
 	})
 }
+
+// internalError logs err together with the request ID and responds with a
+// generic INTERNAL_ERROR carrying the same ID, so a reported request_id leads
+// straight to the underlying error in the logs.
+func (h *PostsHandler) internalError(w http.ResponseWriter, r *http.Request, msg string, err error, args ...any) {
+	args = append(args, "error", err, "request_id", middleware.GetRequestID(r.Context()))
+	h.logger.Error(msg, args...)
+	writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error", nil)
+}
+
+// contentDetails turns content rejected by the service, a *posts.HeadingsError
+// or posts.ErrEmbeddedImages, into VALIDATION_ERROR details and reports
+// whether err was one.
+func contentDetails(err error) (map[string]string, bool) {
+	var herr *posts.HeadingsError
+	switch {
+	case errors.As(err, &herr):
+		return map[string]string{"content": strings.Join(herr.Problems, "; ")}, true
+	case errors.Is(err, posts.ErrEmbeddedImages):
+		return map[string]string{"content": "data-URL images are not allowed"}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jeremyjsx/entries/internal/middleware"
+)
+
+// WithFallbacks serves requests through mux, replacing ServeMux's plain-text
+// 404 and 405 responses with the JSON error envelope. 405 responses keep the
+// Allow header listing the methods registered for the path. The matched
+// pattern is recorded for middleware.Logging.
+func WithFallbacks(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		if pattern != "" {
+			middleware.SetRoute(r, pattern)
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Unmatched requests get a NotFound, MethodNotAllowed or redirect
+		// handler; run it against a recorder to learn which.
+		rec := &statusRecorder{header: http.Header{}, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		switch rec.status {
+		case http.StatusNotFound:
+			writeError(w, r, http.StatusNotFound, "NOT_FOUND", "resource not found", nil)
+		case http.StatusMethodNotAllowed:
+			w.Header().Set("Allow", rec.header.Get("Allow"))
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "method not allowed", nil)
+		default:
+			h.ServeHTTP(w, r)
+		}
+	})
+}
+
+type statusRecorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) Header() http.Header { return rec.header }
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+}
+
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return len(p), nil
+}
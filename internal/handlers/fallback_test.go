@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeremyjsx/entries/internal/middleware"
+)
+
+func decodeAPIError(t *testing.T, rec *httptest.ResponseRecorder) APIError {
+	t.Helper()
+	var body struct {
+		Error APIError `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error envelope: %v", err)
+	}
+	return body.Error
+}
+
+func TestWithFallbacks_UnknownPath(t *testing.T) {
+	h, _, _ := testHandler(t)
+	handler := middleware.RequestID(WithFallbacks(testMux(h)))
+
+	req := httptest.NewRequest(http.MethodGet, "/nope", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	apiErr := decodeAPIError(t, rec)
+	if apiErr.Code != "NOT_FOUND" || apiErr.RequestID == "" || apiErr.RequestID != rec.Header().Get("X-Request-ID") {
+		t.Errorf("got %+v", apiErr)
+	}
+}
+
+func TestWithFallbacks_WrongMethod(t *testing.T) {
+	h, _, _ := testHandler(t)
+	handler := middleware.RequestID(WithFallbacks(testMux(h)))
+
+	req := httptest.NewRequest(http.MethodPatch, "/posts", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+	apiErr := decodeAPIError(t, rec)
+	if apiErr.Code != "METHOD_NOT_ALLOWED" || apiErr.RequestID == "" {
+		t.Errorf("got %+v", apiErr)
+	}
+}
+
+func TestWithFallbacks_AllowHeader(t *testing.T) {
+	tests := []struct {
+		method, path string
+		wantAllow    string
+	}{
+		{http.MethodDelete, "/posts", "GET, HEAD, POST"},
+		{http.MethodPost, "/posts/hello", "DELETE, GET, HEAD, PUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			h, _, _ := testHandler(t)
+			handler := middleware.RequestID(WithFallbacks(testMux(h)))
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("expected 405, got %d", rec.Code)
+			}
+			if allow := rec.Header().Get("Allow"); allow != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", allow, tt.wantAllow)
+			}
+			apiErr := decodeAPIError(t, rec)
+			if apiErr.Code != "METHOD_NOT_ALLOWED" || apiErr.RequestID != rec.Header().Get("X-Request-ID") {
+				t.Errorf("got %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestWithFallbacks_LogsRoutePattern(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /posts/{slug}", func(w http.ResponseWriter, r *http.Request) {})
+	h := middleware.Logging(logger)(WithFallbacks(mux))
+
+	for _, tt := range []struct{ path, route string }{
+		{"/posts/abc", "GET /posts/{slug}"},
+		{"/posts/def", "GET /posts/{slug}"},
+		{"/nowhere", ""},
+	} {
+		buf.Reset()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+		var entry struct {
+			Route string `json:"route"`
+			Path  string `json:"path"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("decode log line %q: %v", buf.String(), err)
+		}
+		if entry.Route != tt.route || entry.Path != tt.path {
+			t.Errorf("%s: logged route %q path %q, want route %q", tt.path, entry.Route, entry.Path, tt.route)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"context"
-	"database/sql"
 	"net/http"
 	"time"
 
@@ -10,10 +9,26 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// Health statuses. Degraded means the API can serve requests but an optional
+// dependency (the event broker) is down; unhealthy means a required one is.
+const (
+	healthStatusHealthy   = "healthy"
+	healthStatusDegraded  = "degraded"
+	healthStatusUnhealthy = "unhealthy"
+)
+
 type HealthDeps struct {
-	DB          *sql.DB
+	DB          Pinger
 	Storage     storage.Storage
 	RabbitMQURL string
+	// DegradedStatusCode is the HTTP code for a degraded status. Defaults to
+	// 200 so orchestrators keep routing traffic; set 503 to fail probes.
+	DegradedStatusCode int
+}
+
+// Pinger is the database check used by Health; *sql.DB satisfies it.
+type Pinger interface {
+	PingContext(ctx context.Context) error
 }
 
 type healthResponse struct {
@@ -27,18 +42,18 @@ func Health(deps *HealthDeps) http.HandlerFunc {
 		defer cancel()
 
 		checks := map[string]string{}
-		status := "healthy"
+		status := healthStatusHealthy
 
 		if err := deps.DB.PingContext(ctx); err != nil {
 			checks["db"] = "unhealthy"
-			status = "unhealthy"
+			status = healthStatusUnhealthy
 		} else {
 			checks["db"] = "ok"
 		}
 
 		if _, err := deps.Storage.Exists(ctx, "__health__"); err != nil {
 			checks["s3"] = "unhealthy"
-			status = "unhealthy"
+			status = healthStatusUnhealthy
 		} else {
 			checks["s3"] = "ok"
 		}
@@ -47,7 +62,9 @@ func Health(deps *HealthDeps) http.HandlerFunc {
 			conn, err := amqp.Dial(deps.RabbitMQURL)
 			if err != nil {
 				checks["rabbitmq"] = "unhealthy"
-				status = "degraded"
+				if status == healthStatusHealthy {
+					status = healthStatusDegraded
+				}
 			} else {
 				_ = conn.Close()
 				checks["rabbitmq"] = "ok"
@@ -57,8 +74,13 @@ func Health(deps *HealthDeps) http.HandlerFunc {
 		}
 
 		code := http.StatusOK
-		if status == "unhealthy" {
+		switch status {
+		case healthStatusUnhealthy:
 			code = http.StatusServiceUnavailable
+		case healthStatusDegraded:
+			if deps.DegradedStatusCode != 0 {
+				code = deps.DegradedStatusCode
+			}
 		}
 		writeJSON(w, code, healthResponse{Status: status, Checks: checks})
 	}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// logLevels are the names accepted by LogLevel.
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// LogLevel sets level from a {"level": "debug|info|warn|error"} body. Loggers
+// built on level pick the change up with their next record.
+func LogLevel(level *slog.LevelVar, logger *slog.Logger) http.HandlerFunc {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req logLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "invalid JSON body", nil)
+			return
+		}
+		name := strings.ToLower(strings.TrimSpace(req.Level))
+		l, ok := logLevels[name]
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", map[string]string{
+				"level": "must be debug, info, warn or error",
+			})
+			return
+		}
+		prev := level.Level()
+		level.Set(l)
+		logger.Warn("log level changed", "from", prev.String(), "to", l.String())
+		writeJSON(w, http.StatusOK, logLevelRequest{Level: name})
+	}
+}
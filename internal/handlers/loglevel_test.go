@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogLevel(t *testing.T) {
+	var level slog.LevelVar
+	h := LogLevel(&level, slog.Default())
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(`{"level":"debug"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("level = %v, want DEBUG", level.Level())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(`{"level":"verbose"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if e := decodeAPIError(t, rec); e.Code != "VALIDATION_ERROR" || e.Details["level"] == "" {
+		t.Errorf("error = %+v, want VALIDATION_ERROR on level", e)
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("level = %v after invalid request, want DEBUG", level.Level())
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// checkJSONBody answers 415 and reports false when h requires JSON bodies
+// and r's Content-Type is not application/json. Parameters such as charset
+// are allowed.
+func (h *PostsHandler) checkJSONBody(w http.ResponseWriter, r *http.Request) bool {
+	if !h.requireJSON {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeError(w, r, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "Content-Type must be application/json", nil)
+		return false
+	}
+	return true
+}
+
+// negotiateContentType chooses between native and text/plain using the
+// request's Accept header. Ties and a missing header favor native. The
+// second result is false when Accept rules out both.
+func negotiateContentType(accept, native string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return native, true
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range []string{native, "text/plain"} {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// acceptQuality returns the q-value the most specific matching media range
+// in accept assigns to mediaType, or 0 when no range matches.
+func acceptQuality(accept, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		rng := strings.ToLower(strings.TrimSpace(fields[0]))
+		var s int
+		switch rng {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity, q = s, 1
+		for _, param := range fields[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+	}
+	return q
+}
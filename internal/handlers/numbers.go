@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jeremyjsx/entries/internal/posts"
+)
+
+// wantStringNumbers reports whether the client asked, with
+// ?string_numbers=true, for int64 fields as JSON strings. JavaScript numbers
+// lose precision past 2^53.
+func wantStringNumbers(r *http.Request) bool {
+	ok, _ := strconv.ParseBool(r.URL.Query().Get("string_numbers"))
+	return ok
+}
+
+// The types below shadow the int64 fields of the shapes they embed with
+// copies tagged ",string"; encoding/json prefers the shallower field.
+
+type postStringNumbers struct {
+	*posts.Post
+	Views int64 `json:"views,string"`
+}
+
+type updateResultStringNumbers struct {
+	*posts.UpdateResult
+	Views int64 `json:"views,string"`
+}
+
+type postNavStringNumbers struct {
+	*posts.PostNav
+	Views int64 `json:"views,string"`
+}
+
+type listResultStringNumbers struct {
+	*posts.ListResult
+	Posts []postStringNumbers `json:"data"`
+	Total int64               `json:"total,string"`
+}
+
+type listResultV1StringNumbers struct {
+	listResultV1
+	Total int64 `json:"total,string"`
+}
+
+type tagCountStringNumbers struct {
+	posts.TagCount
+	Count int64 `json:"count,string"`
+}
+
+// shapeStringNumbers converts responses to a shape whose int64 fields encode
+// as strings. Other values are returned unchanged.
+func shapeStringNumbers(data any) any {
+	switch v := data.(type) {
+	case *posts.Post:
+		return postStringNumbers{Post: v, Views: v.Views}
+	case *posts.UpdateResult:
+		return updateResultStringNumbers{UpdateResult: v, Views: v.Views}
+	case *posts.PostNav:
+		return postNavStringNumbers{PostNav: v, Views: v.Views}
+	case *posts.ListResult:
+		out := listResultStringNumbers{ListResult: v, Posts: make([]postStringNumbers, len(v.Posts)), Total: v.Total}
+		for i, p := range v.Posts {
+			out.Posts[i] = postStringNumbers{Post: p, Views: p.Views}
+		}
+		return out
+	case listResultV1:
+		return listResultV1StringNumbers{listResultV1: v, Total: v.Total}
+	case []posts.TagCount:
+		out := make([]tagCountStringNumbers, len(v))
+		for i, tc := range v {
+			out[i] = tagCountStringNumbers{TagCount: tc, Count: tc.Count}
+		}
+		return out
+	}
+	return data
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/posts"
+)
+
+func TestPostsHandler_List_StringNumbers(t *testing.T) {
+	const huge = int64(1) << 60
+	tests := []struct {
+		name       string
+		query      string
+		accept     string
+		wantTotal  any
+		wantViews  any
+		checkViews bool
+	}{
+		{"numbers by default", "", "", float64(huge), float64(42), true},
+		{"strings on request", "&string_numbers=true", "", "1152921504606846976", "42", true},
+		{"strings in v1", "&string_numbers=true", vendorMediaType + "1+json", "1152921504606846976", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, _ := testHandler(t)
+			repo.list = func(context.Context, posts.ListParams) ([]*posts.Post, error) {
+				return []*posts.Post{{ID: uuid.New(), Slug: "a", Views: 42}}, nil
+			}
+			repo.count = func(context.Context, []posts.Status) (int64, error) { return huge, nil }
+
+			req := httptest.NewRequest(http.MethodGet, "/posts?page=1"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+			var resp struct {
+				Data  []map[string]any `json:"data"`
+				Total any              `json:"total"`
+				Page  any              `json:"page"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Total != tt.wantTotal {
+				t.Errorf("total = %#v, want %#v", resp.Total, tt.wantTotal)
+			}
+			if resp.Page != float64(1) {
+				t.Errorf("page = %#v, want a number", resp.Page)
+			}
+			if len(resp.Data) != 1 || resp.Data[0]["slug"] != "a" {
+				t.Fatalf("data = %v", resp.Data)
+			}
+			if views, ok := resp.Data[0]["views"]; ok != tt.checkViews || views != tt.wantViews {
+				t.Errorf("views = %#v, want %#v", views, tt.wantViews)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_Tags_StringNumbers(t *testing.T) {
+	h, repo, _ := testHandler(t)
+	repo.listTags = func(context.Context, *posts.Status) ([]posts.TagCount, error) {
+		return []posts.TagCount{{Tag: "go", Count: 3}}, nil
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/tags?string_numbers=true", nil)
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if got, want := rec.Body.String(), `{"data":[{"tag":"go","count":"3"}]}`+"\n"; got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
@@ -1,27 +1,145 @@
 package handlers
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"html/template"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"path"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/middleware"
 	"github.com/jeremyjsx/entries/internal/posts"
 )
 
 var slugRegex = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
 
+// minCreatedAt is the earliest created_at override accepted.
+var minCreatedAt = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	maxContentSize    = 10 << 20
+	maxImportSize     = 32 << 20
+	maxImportFileSize = 5 << 20
+	maxExistsSlugs    = 1000
+	// maxCreatedAtSkew allows created_at overrides slightly in the future,
+	// for clocks that disagree.
+	maxCreatedAtSkew = 24 * time.Hour
+
+	defaultMaxTags      = 10
+	defaultMaxTagLength = 32
+)
+
+type PostsHandlerConfig struct {
+	// APIKeys are the keys checked by handlers that gate access themselves.
+	APIKeys middleware.APIKeys
+	// ProtectDraftContent requires a read-scoped key to read draft content.
+	ProtectDraftContent bool
+	// MaxTags caps the tags on a post. Defaults to 10.
+	MaxTags int
+	// MaxTagLength caps each tag's length. Defaults to 32.
+	MaxTagLength int
+	// BasePath prefixes Location headers.
+	BasePath string
+	// ReservedSlugs defaults to DefaultReservedSlugs when nil.
+	ReservedSlugs []string
+	// SiteURL makes preview metadata URLs absolute. Nil leaves them relative.
+	SiteURL *url.URL
+	// PreviewTemplate defaults to DefaultPreviewTemplate.
+	PreviewTemplate *template.Template
+	// PreviewCSP is the preview pages' Content-Security-Policy, if any.
+	PreviewCSP string
+	// PreviewMaxAge is the preview max-age of published posts. Zero sends none.
+	PreviewMaxAge time.Duration
+	// AnonymousMaxPerPage caps per_page without an API key. Zero means no cap.
+	AnonymousMaxPerPage int
+	// SlugNormalize defaults to SlugNormalizeOff.
+	SlugNormalize SlugNormalizeMode
+	// RequireJSON answers 415 to JSON endpoints sent another Content-Type.
+	RequireJSON bool
+	// UploadReadTimeout overrides ReadTimeout for content uploads when set.
+	UploadReadTimeout time.Duration
+}
+
+// DefaultReservedSlugs covers names that collide with routes or are kept for
+// future system pages.
+var DefaultReservedSlugs = []string{
+	"admin", "api", "export", "feed", "feed.xml", "files", "health", "id",
+	"import", "posts", "sitemap", "sitemap.xml", "tags",
+}
+
 type PostsHandler struct {
-	svc    *posts.Service
-	logger *slog.Logger
+	svc                 *posts.Service
+	logger              *slog.Logger
+	apiKeys             middleware.APIKeys
+	protectDraftContent bool
+	maxTags             int
+	maxTagLength        int
+	basePath            string
+	reservedSlugs       map[string]struct{}
+	previewTemplate     *template.Template
+	previewCSP          string
+	previewMaxAge       time.Duration
+	anonymousMaxPerPage int
+	siteURL             *url.URL
+	slugNormalize       SlugNormalizeMode
+	requireJSON         bool
+	uploadReadTimeout   time.Duration
 }
 
-func NewPostsHandler(svc *posts.Service, logger *slog.Logger) *PostsHandler {
+func NewPostsHandler(svc *posts.Service, logger *slog.Logger, cfg PostsHandlerConfig) *PostsHandler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	maxTags := cfg.MaxTags
+	if maxTags <= 0 {
+		maxTags = defaultMaxTags
+	}
+	maxTagLength := cfg.MaxTagLength
+	if maxTagLength <= 0 {
+		maxTagLength = defaultMaxTagLength
+	}
+	reserved := cfg.ReservedSlugs
+	if reserved == nil {
+		reserved = DefaultReservedSlugs
+	}
+	reservedSlugs := make(map[string]struct{}, len(reserved))
+	for _, slug := range reserved {
+		reservedSlugs[slug] = struct{}{}
+	}
+	previewTemplate := cfg.PreviewTemplate
+	if previewTemplate == nil {
+		previewTemplate = DefaultPreviewTemplate
+	}
 	return &PostsHandler{
-		svc:    svc,
-		logger: logger,
+		svc:                 svc,
+		logger:              logger,
+		apiKeys:             cfg.APIKeys,
+		protectDraftContent: cfg.ProtectDraftContent,
+		maxTags:             maxTags,
+		maxTagLength:        maxTagLength,
+		basePath:            cfg.BasePath,
+		reservedSlugs:       reservedSlugs,
+		previewTemplate:     previewTemplate,
+		siteURL:             cfg.SiteURL,
+		previewCSP:          cfg.PreviewCSP,
+		previewMaxAge:       cfg.PreviewMaxAge,
+		anonymousMaxPerPage: cfg.AnonymousMaxPerPage,
+		slugNormalize:       cfg.SlugNormalize,
+		requireJSON:         cfg.RequireJSON,
+		uploadReadTimeout:   cfg.UploadReadTimeout,
 	}
 }
 
@@ -29,87 +147,246 @@ type PostRequest struct {
 	Title   string `json:"title"`
 	Slug    string `json:"slug"`
 	Content string `json:"content"`
+	// Format defaults to markdown when empty.
+	Format posts.Format `json:"format"`
+	Tags   []string     `json:"tags"`
+	// CanonicalURL points at the original of a republished post.
+	CanonicalURL *string `json:"canonical_url"`
+	// CreatedAt is an RFC 3339 time backdating the post, for imports of
+	// historical posts. Setting it needs the admin scope.
+	CreatedAt *string `json:"created_at"`
 }
 
 type UpdatePostRequest struct {
 	Title   *string `json:"title"`
 	Slug    *string `json:"slug"`
 	Content *string `json:"content"`
+	// Tags replaces the post's tags when present; [] clears them.
+	Tags []string `json:"tags"`
+	// CanonicalURL replaces the canonical URL when present; "" clears it.
+	CanonicalURL *string `json:"canonical_url"`
+}
+
+// ExistsRequest lists the slugs POST /posts/exists looks up.
+type ExistsRequest struct {
+	Slugs []string `json:"slugs"`
+}
+
+// PublishPostRequest optionally carries final edits to apply before publishing.
+type PublishPostRequest struct {
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
 }
 
 func (h *PostsHandler) Create() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.checkJSONBody(w, r) {
+			return
+		}
+		h.extendUploadDeadline(w, r)
 		var req PostRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "invalid JSON body", nil)
 			return
 		}
 
-		if errs := validatePostRequest(req.Title, req.Slug, req.Content); len(errs) > 0 {
+		errs := h.validatePostRequest(req.Title, req.Slug, req.Content)
+		if req.Format != "" && !req.Format.Valid() {
+			errs["format"] = "must be one of markdown, asciidoc, rst"
+		}
+		h.validateTags(req.Tags, errs)
+		validateCanonicalURL(req.CanonicalURL, errs)
+		createdAt := parseCreatedAt(req.CreatedAt, time.Now(), errs)
+		if len(errs) > 0 {
 			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", errs)
 			return
 		}
+		if req.CanonicalURL != nil && *req.CanonicalURL == "" {
+			req.CanonicalURL = nil
+		}
+		if createdAt != nil {
+			if _, ok := middleware.CheckScope(r, h.apiKeys, middleware.ScopeAdmin); !ok {
+				writeError(w, r, http.StatusForbidden, "FORBIDDEN", "created_at needs an API key with the admin scope", nil)
+				return
+			}
+		}
 
-		post, err := h.svc.CreatePost(r.Context(), req.Title, req.Slug, req.Content)
+		post, err := h.svc.CreatePost(r.Context(), posts.CreatePostInput{
+			Title:        req.Title,
+			Slug:         req.Slug,
+			Content:      req.Content,
+			Format:       req.Format,
+			Tags:         req.Tags,
+			CanonicalURL: req.CanonicalURL,
+			CreatedAt:    createdAt,
+		})
 		if err != nil {
 			if errors.Is(err, posts.ErrSlugExists) {
 				writeError(w, r, http.StatusConflict, "CONFLICT", "slug already exists", nil)
 				return
 			}
-			h.logger.Error("create post failed", "error", err)
-			writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error", nil)
+			if details, ok := contentDetails(err); ok {
+				writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", details)
+				return
+			}
+			h.internalError(w, r, "create post failed", err)
 			return
 		}
 
-		writeJSON(w, http.StatusCreated, post)
+		w.Header().Set("Location", h.basePath+"/posts/"+post.Slug)
+		writeVersioned(w, r, http.StatusCreated, post)
 	}
 }
 
 func (h *PostsHandler) GetBySlug() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		slug := r.PathValue("slug")
+		slug, ok := h.requestSlug(w, r, "")
+		if !ok {
+			return
+		}
 		if slug == "" {
 			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "slug is required", nil)
 			return
 		}
 
-		post, err := h.svc.GetPostBySlug(r.Context(), slug)
+		nav, _ := strconv.ParseBool(r.URL.Query().Get("nav"))
+		var (
+			post any
+			err  error
+		)
+		if nav {
+			post, err = h.svc.GetPostWithNav(r.Context(), slug)
+		} else {
+			post, err = h.svc.GetPostBySlug(r.Context(), slug)
+		}
 		if err != nil {
 			if errors.Is(err, posts.ErrNotFound) {
 				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post not found", nil)
 				return
 			}
-			h.logger.Error("get post failed", "slug", slug, "error", err)
-			writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error", nil)
+			h.internalError(w, r, "get post failed", err, "slug", slug)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, post)
+		writeVersioned(w, r, http.StatusOK, post)
+	}
+}
+
+// GetByID looks a post up by its stable ID, which survives slug renames.
+func (h *PostsHandler) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "id must be a UUID", nil)
+			return
+		}
+
+		post, err := h.svc.GetPostByID(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, posts.ErrNotFound) {
+				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post not found", nil)
+				return
+			}
+			h.internalError(w, r, "get post by id failed", err, "id", id)
+			return
+		}
+
+		writeVersioned(w, r, http.StatusOK, post)
 	}
 }
 
 func (h *PostsHandler) GetContent() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		slug := r.PathValue("slug")
+		slug, ok := h.requestSlug(w, r, "/content")
+		if !ok {
+			return
+		}
 		if slug == "" {
 			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "slug is required", nil)
 			return
 		}
 
-		content, err := h.svc.GetPostContent(r.Context(), slug)
+		if h.protectDraftContent {
+			presented, ok := middleware.CheckScope(r, h.apiKeys, middleware.ScopeRead)
+			if presented && !ok {
+				writeError(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "missing or invalid API key", nil)
+				return
+			}
+			// Without a key, drafts are reported missing rather than forbidden
+			// so their existence is not revealed.
+			if !ok {
+				post, err := h.svc.GetPostBySlug(r.Context(), slug)
+				if err != nil && !errors.Is(err, posts.ErrNotFound) {
+					h.internalError(w, r, "get post failed", err, "slug", slug)
+					return
+				}
+				if err != nil || post.Status != posts.Published {
+					writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post or content not found", nil)
+					return
+				}
+			}
+		}
+
+		// HEAD gets the same headers from the content's metadata, without
+		// downloading the body.
+		head := r.Method == http.MethodHead
+		var info *posts.ContentInfo
+		var body []byte
+		var err error
+		if head {
+			info, err = h.svc.GetPostContentInfo(r.Context(), slug)
+		} else {
+			var content *posts.PostContent
+			if content, err = h.svc.GetPostContent(r.Context(), slug); err == nil {
+				info = &posts.ContentInfo{Post: content.Post, ETag: content.ETag, Size: int64(len(content.Body)), Placeholder: content.Placeholder}
+				body = content.Body
+			}
+		}
 		if err != nil {
 			if errors.Is(err, posts.ErrNotFound) {
 				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post or content not found", nil)
 				return
 			}
-			h.logger.Error("get post content failed", "slug", slug, "error", err)
-			writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error", nil)
+			h.internalError(w, r, "get post content failed", err, "slug", slug)
+			return
+		}
+
+		contentType, ok := negotiateContentType(r.Header.Get("Accept"), info.Post.Format.ContentType())
+		w.Header().Set("Vary", "Accept")
+		if !ok {
+			writeError(w, r, http.StatusNotAcceptable, "NOT_ACCEPTABLE", "content is available as "+info.Post.Format.ContentType()+" or text/plain", nil)
 			return
 		}
 
-		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		size := info.Size
+		if withFrontmatter, _ := strconv.ParseBool(r.URL.Query().Get("frontmatter")); withFrontmatter {
+			// The ETag describes the stored body alone, so it is not sent for
+			// this representation.
+			frontmatter := posts.RenderFrontmatter(info.Post)
+			body = append([]byte(frontmatter), body...)
+			if size >= 0 {
+				size += int64(len(frontmatter))
+			}
+		} else {
+			w.Header().Set("ETag", info.ETag)
+		}
+		w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+		if size >= 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+		if !info.Post.UpdatedAt.IsZero() {
+			w.Header().Set("Last-Modified", info.Post.UpdatedAt.UTC().Format(http.TimeFormat))
+		}
+		if info.Placeholder {
+			// Keep caches from holding on to the stand-in once the object is
+			// restored.
+			w.Header().Set("Cache-Control", "no-store")
+		}
 		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write(content); err != nil {
+		if head {
+			return
+		}
+		if _, err := w.Write(body); err != nil {
 			h.logger.Error("write content failed", "slug", slug, "error", err)
 		}
 	}
@@ -117,33 +394,140 @@ func (h *PostsHandler) GetContent() http.HandlerFunc {
 
 func (h *PostsHandler) List() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		page, err := strconv.Atoi(r.URL.Query().Get("page"))
-		if err != nil {
-			page = 0
+		errs := make(map[string]string)
+		page := queryInt(r.URL.Query(), "page", errs)
+		perPage := queryInt(r.URL.Query(), "per_page", errs)
+		if len(errs) > 0 {
+			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", errs)
+			return
 		}
-		perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
-		if err != nil {
-			perPage = 0
+		if limit := h.anonymousMaxPerPage; limit > 0 && !middleware.Authenticated(r.Context()) {
+			if perPage == 0 || perPage > posts.MaxPerPage {
+				perPage = posts.DefaultPerPage
+			}
+			perPage = min(perPage, limit)
 		}
 
-		var status *posts.Status
+		// status takes one status or a comma-separated list of them.
+		var statuses []posts.Status
 		if s := r.URL.Query().Get("status"); s != "" {
-			st := posts.Status(s)
-			if st != posts.Draft && st != posts.Published {
-				writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "invalid status", nil)
+			for _, v := range strings.Split(s, ",") {
+				st := posts.Status(strings.TrimSpace(v))
+				if st != posts.Draft && st != posts.Published {
+					writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "invalid status",
+						map[string]string{"status": "unknown status " + strconv.Quote(string(st))})
+					return
+				}
+				if !slices.Contains(statuses, st) {
+					statuses = append(statuses, st)
+				}
+			}
+		}
+
+		sort := posts.SortNewest
+		if s := r.URL.Query().Get("sort"); s != "" {
+			sort = posts.Sort(s)
+			if sort != posts.SortNewest && sort != posts.SortViews {
+				writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "invalid sort", nil)
 				return
 			}
-			status = &st
 		}
 
-		result, err := h.svc.ListPosts(r.Context(), page, perPage, status)
+		result, err := h.svc.ListPosts(r.Context(), page, perPage, statuses, sort)
+		if err != nil {
+			var outOfRange *posts.PageOutOfRangeError
+			if errors.As(err, &outOfRange) {
+				writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "page out of range",
+					map[string]string{"page": "max " + strconv.Itoa(outOfRange.LastPage)})
+				return
+			}
+			h.internalError(w, r, "list posts failed", err)
+			return
+		}
+
+		writeVersioned(w, r, http.StatusOK, result)
+	}
+}
+
+// queryInt parses the non-negative integer query parameter name, recording a
+// problem in errs when it is present but invalid. An absent parameter is 0,
+// which leaves the choice of default to the service.
+func queryInt(q url.Values, name string, errs map[string]string) int {
+	v := q.Get(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		errs[name] = "must be a non-negative integer"
+		return 0
+	}
+	return n
+}
+
+// Tags lists tags with their post counts. Only published posts are counted
+// unless status is "draft" or "all".
+func (h *PostsHandler) Tags() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		published := posts.Published
+		status := &published
+		switch s := r.URL.Query().Get("status"); s {
+		case "", string(posts.Published):
+		case string(posts.Draft):
+			draft := posts.Draft
+			status = &draft
+		case "all":
+			status = nil
+		default:
+			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "invalid status", nil)
+			return
+		}
+
+		tags, err := h.svc.ListTags(r.Context(), status)
+		if err != nil {
+			h.internalError(w, r, "list tags failed", err)
+			return
+		}
+		if tags == nil {
+			tags = []posts.TagCount{}
+		}
+		var data any = tags
+		if wantStringNumbers(r) {
+			data = shapeStringNumbers(tags)
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"data": data})
+	}
+}
+
+// Exists reports which of up to maxExistsSlugs slugs are taken, as a map of
+// slug to bool, drafts included.
+func (h *PostsHandler) Exists() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.checkJSONBody(w, r) {
+			return
+		}
+		var req ExistsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "invalid JSON body", nil)
+			return
+		}
+		if len(req.Slugs) == 0 {
+			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", map[string]string{"slugs": "required"})
+			return
+		}
+		if len(req.Slugs) > maxExistsSlugs {
+			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", map[string]string{"slugs": "max " + strconv.Itoa(maxExistsSlugs) + " slugs"})
+			return
+		}
+
+		exists, err := h.svc.SlugsExist(r.Context(), req.Slugs)
 		if err != nil {
-			h.logger.Error("list posts failed", "error", err)
-			writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error", nil)
+			h.internalError(w, r, "check slugs failed", err)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, result)
+		writeJSON(w, http.StatusOK, map[string]any{"data": exists})
 	}
 }
 
@@ -155,42 +539,197 @@ func (h *PostsHandler) Update() http.HandlerFunc {
 			return
 		}
 
-		var req UpdatePostRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "invalid JSON body", nil)
+		h.update(w, r, func(ctx context.Context, in posts.UpdatePostInput) (*posts.UpdateResult, error) {
+			return h.svc.UpdatePost(ctx, slug, in)
+		}, "slug", slug)
+	}
+}
+
+// UpdateByID applies the same partial update as Update to the post with the
+// given ID, so a rename elsewhere cannot redirect the write.
+func (h *PostsHandler) UpdateByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "id must be a UUID", nil)
 			return
 		}
 
-		if req.Title == nil && req.Slug == nil && req.Content == nil {
-			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "at least one field (title, slug, content) is required", map[string]string{"_": "provide title, slug and/or content"})
+		h.update(w, r, func(ctx context.Context, in posts.UpdatePostInput) (*posts.UpdateResult, error) {
+			return h.svc.UpdatePostByID(ctx, id, in)
+		}, "id", id)
+	}
+}
+
+// update decodes and validates an UpdatePostRequest, hands it to apply and
+// writes the result. logArgs identify the post in error logs.
+func (h *PostsHandler) update(w http.ResponseWriter, r *http.Request, apply func(context.Context, posts.UpdatePostInput) (*posts.UpdateResult, error), logArgs ...any) {
+	if !h.checkJSONBody(w, r) {
+		return
+	}
+	h.extendUploadDeadline(w, r)
+	var req UpdatePostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "invalid JSON body", nil)
+		return
+	}
+
+	if req.Title == nil && req.Slug == nil && req.Content == nil && req.Tags == nil && req.CanonicalURL == nil {
+		writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "at least one field (title, slug, content, tags, canonical_url) is required", map[string]string{"_": "provide title, slug, content, tags and/or canonical_url"})
+		return
+	}
+
+	errs := h.validateUpdateRequest(req)
+	h.validateTags(req.Tags, errs)
+	validateCanonicalURL(req.CanonicalURL, errs)
+	if len(errs) > 0 {
+		writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", errs)
+		return
+	}
+
+	result, err := apply(r.Context(), posts.UpdatePostInput{
+		Title:        req.Title,
+		Slug:         req.Slug,
+		Content:      req.Content,
+		Tags:         req.Tags,
+		CanonicalURL: req.CanonicalURL,
+	})
+	if err != nil {
+		if errors.Is(err, posts.ErrNotFound) {
+			writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post not found", nil)
+			return
+		}
+		if errors.Is(err, posts.ErrSlugExists) {
+			writeError(w, r, http.StatusConflict, "CONFLICT", "slug already exists", nil)
+			return
+		}
+		if details, ok := contentDetails(err); ok {
+			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", details)
 			return
 		}
+		h.internalError(w, r, "update post failed", err, logArgs...)
+		return
+	}
+
+	writeVersioned(w, r, http.StatusOK, result)
+}
 
-		if errs := validateUpdateRequest(req); len(errs) > 0 {
+// Delete removes a post with its content and images. With ?dry_run=true it
+// only reports the objects that would be removed.
+func (h *PostsHandler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		if slug == "" {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "slug is required", nil)
+			return
+		}
+
+		dryRun := false
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(v); err != nil {
+				writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "invalid dry_run", nil)
+				return
+			}
+		}
+		if dryRun {
+			plan, err := h.svc.PlanDeletePost(r.Context(), slug)
+			if err != nil {
+				if errors.Is(err, posts.ErrNotFound) {
+					writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post not found", nil)
+					return
+				}
+				h.internalError(w, r, "plan post deletion failed", err, "slug", slug)
+				return
+			}
+			writeJSON(w, http.StatusOK, plan)
+			return
+		}
+
+		if err := h.svc.DeletePost(r.Context(), slug); err != nil {
+			if errors.Is(err, posts.ErrNotFound) {
+				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post not found", nil)
+				return
+			}
+			h.internalError(w, r, "delete post failed", err, "slug", slug)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *PostsHandler) Publish() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		if slug == "" {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "slug is required", nil)
+			return
+		}
+
+		// The body is optional; an empty one needs no Content-Type.
+		if r.ContentLength != 0 && !h.checkJSONBody(w, r) {
+			return
+		}
+		h.extendUploadDeadline(w, r)
+		var req PublishPostRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "invalid JSON body", nil)
+			return
+		}
+		if errs := h.validateUpdateRequest(UpdatePostRequest{Title: req.Title, Content: req.Content}); len(errs) > 0 {
 			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", errs)
 			return
 		}
 
-		post, err := h.svc.UpdatePost(r.Context(), slug, req.Title, req.Slug, req.Content)
+		var (
+			post *posts.Post
+			err  error
+		)
+		if req.Title != nil || req.Content != nil {
+			post, err = h.svc.PublishPostWithUpdate(r.Context(), slug, req.Title, req.Content)
+		} else {
+			post, err = h.svc.PublishPost(r.Context(), slug)
+		}
 		if err != nil {
+			// Retrying the publish alone is enough once the edits are in.
+			var saved string
+			if errors.Is(err, posts.ErrEditsSaved) {
+				saved = "; edits were saved"
+			}
 			if errors.Is(err, posts.ErrNotFound) {
-				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post not found", nil)
+				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post not found or already published"+saved, nil)
 				return
 			}
-			if errors.Is(err, posts.ErrSlugExists) {
-				writeError(w, r, http.StatusConflict, "CONFLICT", "slug already exists", nil)
+			if errors.Is(err, posts.ErrRevertFailed) {
+				h.logger.Error("publish post failed", "slug", slug, "error", err, "request_id", middleware.GetRequestID(r.Context()))
+				writeError(w, r, http.StatusInternalServerError, "EVENT_UNDELIVERED", "post published but its event could not be delivered; it will be retried", nil)
+				return
+			}
+			if errors.Is(err, posts.ErrEventUndelivered) {
+				writeError(w, r, http.StatusServiceUnavailable, "EVENT_UNDELIVERED", "post not published: event could not be delivered"+saved, nil)
 				return
 			}
-			h.logger.Error("update post failed", "slug", slug, "error", err)
-			writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error", nil)
+			if details, ok := contentDetails(err); ok {
+				writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", details)
+				return
+			}
+			if saved != "" {
+				h.logger.Error("publish post failed", "slug", slug, "error", err, "request_id", middleware.GetRequestID(r.Context()))
+				writeError(w, r, http.StatusInternalServerError, "PUBLISH_FAILED", "post not published"+saved, nil)
+				return
+			}
+			h.internalError(w, r, "publish post failed", err, "slug", slug)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, post)
+		writeVersioned(w, r, http.StatusOK, post)
 	}
 }
 
-func (h *PostsHandler) Delete() http.HandlerFunc {
+// UpdateContent replaces a post's markdown with the raw request body. An
+// If-Match header makes the write conditional on the content ETag.
+func (h *PostsHandler) UpdateContent() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slug := r.PathValue("slug")
 		if slug == "" {
@@ -198,21 +737,66 @@ func (h *PostsHandler) Delete() http.HandlerFunc {
 			return
 		}
 
-		if err := h.svc.DeletePost(r.Context(), slug); err != nil {
+		h.extendUploadDeadline(w, r)
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxContentSize))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeError(w, r, http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE", "content exceeds size limit", nil)
+				return
+			}
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "could not read body", nil)
+			return
+		}
+		if len(data) == 0 {
+			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", map[string]string{"content": "required"})
+			return
+		}
+		if !utf8.Valid(data) {
+			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", map[string]string{"content": "invalid utf-8"})
+			return
+		}
+
+		result, err := h.svc.UpdatePostContent(r.Context(), slug, string(data), parseIfMatch(r.Header.Get("If-Match")))
+		if err != nil {
 			if errors.Is(err, posts.ErrNotFound) {
-				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post not found", nil)
+				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post or content not found", nil)
+				return
+			}
+			if errors.Is(err, posts.ErrPreconditionFailed) {
+				writeError(w, r, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "content has changed since it was read", nil)
+				return
+			}
+			if details, ok := contentDetails(err); ok {
+				writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "validation failed", details)
 				return
 			}
-			h.logger.Error("delete post failed", "slug", slug, "error", err)
-			writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error", nil)
+			h.internalError(w, r, "update post content failed", err, "slug", slug)
 			return
 		}
 
-		w.WriteHeader(http.StatusNoContent)
+		w.Header().Set("ETag", `"`+result.ContentSHA256+`"`)
+		writeVersioned(w, r, http.StatusOK, result)
 	}
 }
 
-func (h *PostsHandler) Publish() http.HandlerFunc {
+// parseIfMatch splits an If-Match header into its ETags. A missing header or
+// "*" yields nil, meaning any current content matches.
+func parseIfMatch(header string) []string {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil
+	}
+	var etags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			etags = append(etags, tag)
+		}
+	}
+	return etags
+}
+
+func (h *PostsHandler) ReprocessImages() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slug := r.PathValue("slug")
 		if slug == "" {
@@ -220,22 +804,195 @@ func (h *PostsHandler) Publish() http.HandlerFunc {
 			return
 		}
 
-		post, err := h.svc.PublishPost(r.Context(), slug)
+		extracted, err := h.svc.ReprocessImages(r.Context(), slug)
 		if err != nil {
 			if errors.Is(err, posts.ErrNotFound) {
-				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post not found or already published", nil)
+				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post or content not found", nil)
+				return
+			}
+			h.internalError(w, r, "reprocess images failed", err, "slug", slug)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{
+			"slug":             slug,
+			"images_extracted": extracted,
+		})
+	}
+}
+
+func (h *PostsHandler) Export() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		published := posts.Published
+		status := &published
+		switch s := r.URL.Query().Get("status"); s {
+		case "":
+		case "all":
+			status = nil
+		default:
+			st := posts.Status(s)
+			if st != posts.Draft && st != posts.Published {
+				writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "invalid status", nil)
 				return
 			}
-			h.logger.Error("publish post failed", "slug", slug, "error", err)
-			writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error", nil)
+			status = &st
+		}
+
+		zw := &streamWriter{w: w, header: http.Header{
+			"Content-Type":        {"application/zip"},
+			"Content-Disposition": {`attachment; filename="entries-export.zip"`},
+		}}
+		if err := h.svc.ExportPosts(r.Context(), zw, status); err != nil {
+			h.logger.Error("export posts failed", "error", err, "request_id", middleware.GetRequestID(r.Context()))
+			if !zw.started {
+				writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error", nil)
+			}
+		}
+	}
+}
+
+type ImportResult struct {
+	File    string            `json:"file"`
+	Slug    string            `json:"slug,omitempty"`
+	Status  string            `json:"status"`
+	Error   string            `json:"error,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+type ImportReport struct {
+	Results    []ImportResult `json:"results"`
+	Created    int            `json:"created"`
+	Duplicates int            `json:"duplicates"`
+	Failed     int            `json:"failed"`
+}
+
+func (h *PostsHandler) Import() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.extendUploadDeadline(w, r)
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeError(w, r, http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE", "import archive too large", nil)
+				return
+			}
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "failed to read body", nil)
+			return
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "invalid zip archive", nil)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, post)
+		report := ImportReport{Results: make([]ImportResult, 0)}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() || path.Ext(f.Name) != ".md" {
+				continue
+			}
+			res := h.importFile(r.Context(), f)
+			switch res.Status {
+			case "created":
+				report.Created++
+			case "duplicate":
+				report.Duplicates++
+			default:
+				report.Failed++
+			}
+			report.Results = append(report.Results, res)
+		}
+
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// importFile creates a draft post from one archive entry. The slug comes from
+// the frontmatter when present, otherwise from the file name, and a
+// frontmatter created_at backdates the post.
+func (h *PostsHandler) importFile(ctx context.Context, f *zip.File) ImportResult {
+	res := ImportResult{File: f.Name, Status: "failed"}
+	if f.UncompressedSize64 > maxImportFileSize {
+		res.Error = "file too large"
+		return res
+	}
+	rc, err := f.Open()
+	if err != nil {
+		res.Error = "cannot open file"
+		return res
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, maxImportFileSize+1))
+	rc.Close()
+	if err != nil {
+		res.Error = "cannot read file"
+		return res
+	}
+	if len(data) > maxImportFileSize {
+		res.Error = "file too large"
+		return res
+	}
+
+	fm, content := posts.ParseFrontmatter(string(data))
+	slug := fm.Slug
+	if slug == "" {
+		slug = strings.TrimSuffix(path.Base(f.Name), ".md")
+	}
+	title := fm.Title
+	if title == "" {
+		title = slug
+	}
+	res.Slug = slug
+
+	errs := h.validatePostRequest(title, slug, content)
+	h.validateTags(fm.Tags, errs)
+	var createdAt *time.Time
+	if fm.CreatedAt != "" {
+		createdAt = parseCreatedAt(&fm.CreatedAt, time.Now(), errs)
+	}
+	if len(errs) > 0 {
+		res.Error = "validation failed"
+		res.Details = errs
+		return res
+	}
+
+	if _, err := h.svc.CreatePost(ctx, posts.CreatePostInput{Title: title, Slug: slug, Content: content, Tags: fm.Tags, CreatedAt: createdAt}); err != nil {
+		if errors.Is(err, posts.ErrSlugExists) {
+			res.Status = "duplicate"
+			res.Error = "slug already exists"
+			return res
+		}
+		if details, ok := contentDetails(err); ok {
+			res.Error = "validation failed"
+			res.Details = details
+			return res
+		}
+		h.logger.Error("import post failed", "file", f.Name, "slug", slug, "error", err, "request_id", middleware.GetRequestID(ctx))
+		res.Error = "internal error"
+		return res
+	}
+	res.Status = "created"
+	return res
+}
+
+// streamWriter defers the 200 status and its headers until the first byte is
+// written, so failures before any output can still produce an error envelope.
+type streamWriter struct {
+	w       http.ResponseWriter
+	header  http.Header
+	started bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if !sw.started {
+		sw.started = true
+		for k, v := range sw.header {
+			sw.w.Header()[k] = v
+		}
+		sw.w.WriteHeader(http.StatusOK)
 	}
+	return sw.w.Write(p)
 }
 
-func validatePostRequest(title, slug, content string) map[string]string {
+func (h *PostsHandler) validatePostRequest(title, slug, content string) map[string]string {
 	errs := make(map[string]string)
 	if title == "" {
 		errs["title"] = "required"
@@ -248,14 +1005,73 @@ func validatePostRequest(title, slug, content string) map[string]string {
 		errs["slug"] = "max 100 characters"
 	} else if !slugRegex.MatchString(slug) {
 		errs["slug"] = "must be lowercase alphanumeric with hyphens"
+	} else if _, ok := h.reservedSlugs[slug]; ok {
+		errs["slug"] = "reserved"
 	}
 	if content == "" {
 		errs["content"] = "required"
+	} else if !utf8.ValidString(content) {
+		errs["content"] = "invalid utf-8"
 	}
 	return errs
 }
 
-func validateUpdateRequest(req UpdatePostRequest) map[string]string {
+// validateTags adds an entry to errs for each tag that is invalid once trimmed
+// and lowercased, keyed by its index, and for too many distinct tags.
+func (h *PostsHandler) validateTags(tags []string, errs map[string]string) {
+	for i, tag := range tags {
+		key := "tags[" + strconv.Itoa(i) + "]"
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		switch {
+		case tag == "":
+			errs[key] = "cannot be empty"
+		case len(tag) > h.maxTagLength:
+			errs[key] = "max " + strconv.Itoa(h.maxTagLength) + " characters"
+		case !slugRegex.MatchString(tag):
+			errs[key] = "must be lowercase alphanumeric with hyphens"
+		}
+	}
+	if n := len(posts.NormalizeTags(tags)); n > h.maxTags {
+		errs["tags"] = "max " + strconv.Itoa(h.maxTags) + " tags"
+	}
+}
+
+// validateCanonicalURL records a problem in errs unless u is absent, empty or
+// an absolute http(s) URL.
+func validateCanonicalURL(u *string, errs map[string]string) {
+	if u == nil || *u == "" {
+		return
+	}
+	if len(*u) > 2048 {
+		errs["canonical_url"] = "max 2048 characters"
+		return
+	}
+	parsed, err := url.Parse(*u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs["canonical_url"] = "must be an absolute http(s) URL"
+	}
+}
+
+// parseCreatedAt parses an optional created_at override, recording an error
+// unless it is an RFC 3339 time between minCreatedAt and maxCreatedAtSkew
+// past now.
+func parseCreatedAt(s *string, now time.Time, errs map[string]string) *time.Time {
+	if s == nil {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, *s)
+	if err != nil {
+		errs["created_at"] = "must be an RFC 3339 timestamp"
+		return nil
+	}
+	if t.Before(minCreatedAt) || t.After(now.Add(maxCreatedAtSkew)) {
+		errs["created_at"] = "out of range"
+		return nil
+	}
+	return &t
+}
+
+func (h *PostsHandler) validateUpdateRequest(req UpdatePostRequest) map[string]string {
 	errs := make(map[string]string)
 	if req.Title != nil {
 		if *req.Title == "" {
@@ -271,7 +1087,12 @@ func validateUpdateRequest(req UpdatePostRequest) map[string]string {
 			errs["slug"] = "max 100 characters"
 		} else if !slugRegex.MatchString(*req.Slug) {
 			errs["slug"] = "must be lowercase alphanumeric with hyphens"
+		} else if _, ok := h.reservedSlugs[*req.Slug]; ok {
+			errs["slug"] = "reserved"
 		}
 	}
+	if req.Content != nil && !utf8.ValidString(*req.Content) {
+		errs["content"] = "invalid utf-8"
+	}
 	return errs
 }
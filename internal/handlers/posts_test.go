@@ -1,33 +1,49 @@
 package handlers
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/events"
+	"github.com/jeremyjsx/entries/internal/middleware"
 	"github.com/jeremyjsx/entries/internal/posts"
 	"github.com/jeremyjsx/entries/internal/storage"
 )
 
 type testMockRepo struct {
-	create    func(ctx context.Context, title, slug, s3Key string) (*posts.Post, error)
+	create    func(ctx context.Context, p posts.CreateParams) (*posts.Post, error)
 	getBySlug func(ctx context.Context, slug string) (*posts.Post, error)
+	getByID   func(ctx context.Context, id uuid.UUID) (*posts.Post, error)
 	list      func(ctx context.Context, params posts.ListParams) ([]*posts.Post, error)
-	count     func(ctx context.Context, status *posts.Status) (int64, error)
-	update    func(ctx context.Context, id uuid.UUID, title, slug, s3Key string) (*posts.Post, error)
+	count     func(ctx context.Context, statuses []posts.Status) (int64, error)
+	update    func(ctx context.Context, p posts.UpdateParams) (*posts.Post, error)
 	delete    func(ctx context.Context, slug string) error
 	publish   func(ctx context.Context, slug string) (*posts.Post, error)
+	listTags  func(ctx context.Context, status *posts.Status) ([]posts.TagCount, error)
+	adjacent  func(ctx context.Context, post *posts.Post) (string, string, error)
+	existing  func(ctx context.Context, slugs []string) ([]string, error)
+	revert    func(ctx context.Context, id, eventID uuid.UUID) error
 }
 
-func (m *testMockRepo) Create(ctx context.Context, title, slug, s3Key string) (*posts.Post, error) {
+func (m *testMockRepo) Create(ctx context.Context, p posts.CreateParams) (*posts.Post, error) {
 	if m.create != nil {
-		return m.create(ctx, title, slug, s3Key)
+		return m.create(ctx, p)
 	}
 	return nil, posts.ErrNotFound
 }
@@ -39,6 +55,13 @@ func (m *testMockRepo) GetBySlug(ctx context.Context, slug string) (*posts.Post,
 	return nil, posts.ErrNotFound
 }
 
+func (m *testMockRepo) GetByID(ctx context.Context, id uuid.UUID) (*posts.Post, error) {
+	if m.getByID != nil {
+		return m.getByID(ctx, id)
+	}
+	return nil, posts.ErrNotFound
+}
+
 func (m *testMockRepo) List(ctx context.Context, params posts.ListParams) ([]*posts.Post, error) {
 	if m.list != nil {
 		return m.list(ctx, params)
@@ -46,16 +69,16 @@ func (m *testMockRepo) List(ctx context.Context, params posts.ListParams) ([]*po
 	return nil, nil
 }
 
-func (m *testMockRepo) Count(ctx context.Context, status *posts.Status) (int64, error) {
+func (m *testMockRepo) Count(ctx context.Context, statuses []posts.Status) (int64, error) {
 	if m.count != nil {
-		return m.count(ctx, status)
+		return m.count(ctx, statuses)
 	}
 	return 0, nil
 }
 
-func (m *testMockRepo) Update(ctx context.Context, id uuid.UUID, title, slug, s3Key string) (*posts.Post, error) {
+func (m *testMockRepo) Update(ctx context.Context, p posts.UpdateParams) (*posts.Post, error) {
 	if m.update != nil {
-		return m.update(ctx, id, title, slug, s3Key)
+		return m.update(ctx, p)
 	}
 	return nil, posts.ErrNotFound
 }
@@ -67,11 +90,50 @@ func (m *testMockRepo) Delete(ctx context.Context, slug string) error {
 	return nil
 }
 
-func (m *testMockRepo) Publish(ctx context.Context, slug string) (*posts.Post, error) {
-	if m.publish != nil {
-		return m.publish(ctx, slug)
+func (m *testMockRepo) Publish(ctx context.Context, slug string, newEvent posts.NewEventFunc) (*posts.Post, error) {
+	if m.publish == nil {
+		return nil, posts.ErrNotFound
 	}
-	return nil, posts.ErrNotFound
+	post, err := m.publish(ctx, slug)
+	if err != nil || post == nil || newEvent == nil {
+		return post, err
+	}
+	if _, err := newEvent(post); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (m *testMockRepo) RevertPublish(ctx context.Context, id, eventID uuid.UUID) error {
+	if m.revert != nil {
+		return m.revert(ctx, id, eventID)
+	}
+	return nil
+}
+
+func (m *testMockRepo) IncrementViews(ctx context.Context, id uuid.UUID, delta int64) error {
+	return nil
+}
+
+func (m *testMockRepo) ListTags(ctx context.Context, status *posts.Status) ([]posts.TagCount, error) {
+	if m.listTags != nil {
+		return m.listTags(ctx, status)
+	}
+	return nil, nil
+}
+
+func (m *testMockRepo) ExistingSlugs(ctx context.Context, slugs []string) ([]string, error) {
+	if m.existing != nil {
+		return m.existing(ctx, slugs)
+	}
+	return nil, nil
+}
+
+func (m *testMockRepo) Adjacent(ctx context.Context, post *posts.Post) (string, string, error) {
+	if m.adjacent != nil {
+		return m.adjacent(ctx, post)
+	}
+	return "", "", nil
 }
 
 type testMockStorage struct {
@@ -79,7 +141,9 @@ type testMockStorage struct {
 	download     func(ctx context.Context, key string) (io.ReadCloser, error)
 	delete       func(ctx context.Context, key string) error
 	deletePrefix func(ctx context.Context, prefix string) error
+	list         func(ctx context.Context, prefix string) ([]string, error)
 	exists       func(ctx context.Context, key string) (bool, error)
+	size         func(ctx context.Context, key string) (int64, error)
 }
 
 func (m *testMockStorage) Upload(ctx context.Context, key string, body io.Reader, contentType string) error {
@@ -110,6 +174,13 @@ func (m *testMockStorage) DeletePrefix(ctx context.Context, prefix string) error
 	return nil
 }
 
+func (m *testMockStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	if m.list != nil {
+		return m.list(ctx, prefix)
+	}
+	return nil, nil
+}
+
 func (m *testMockStorage) Exists(ctx context.Context, key string) (bool, error) {
 	if m.exists != nil {
 		return m.exists(ctx, key)
@@ -117,11 +188,18 @@ func (m *testMockStorage) Exists(ctx context.Context, key string) (bool, error)
 	return false, nil
 }
 
+func (m *testMockStorage) Size(ctx context.Context, key string) (int64, error) {
+	if m.size != nil {
+		return m.size(ctx, key)
+	}
+	return 0, storage.ErrNotFound
+}
+
 func testHandler(t *testing.T) (*PostsHandler, *testMockRepo, *testMockStorage) {
 	repo := &testMockRepo{}
 	st := &testMockStorage{}
 	svc := posts.NewService(repo, st, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-	h := NewPostsHandler(svc, slog.Default())
+	h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{})
 	return h, repo, st
 }
 
@@ -129,18 +207,54 @@ func testMux(h *PostsHandler) *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /posts", h.List())
 	mux.HandleFunc("POST /posts", h.Create())
+	mux.HandleFunc("POST /posts/exists", h.Exists())
 	mux.HandleFunc("GET /posts/{slug}/content", h.GetContent())
+	mux.HandleFunc("HEAD /posts/{slug}/content", h.GetContent())
+	mux.HandleFunc("GET /posts/{slug}/preview", h.Preview())
+	mux.HandleFunc("PUT /posts/{slug}/content", h.UpdateContent())
 	mux.HandleFunc("GET /posts/{slug}", h.GetBySlug())
+	mux.Handle("GET "+ByIDPattern, ByID(h.GetByID()))
 	mux.HandleFunc("PUT /posts/{slug}", h.Update())
+	mux.Handle("PUT "+ByIDPattern, ByID(h.UpdateByID()))
 	mux.HandleFunc("DELETE /posts/{slug}", h.Delete())
 	mux.HandleFunc("PATCH /posts/{slug}/publish", h.Publish())
+	mux.HandleFunc("GET /tags", h.Tags())
+	mux.HandleFunc("GET /export", h.Export())
+	mux.HandleFunc("POST /import", h.Import())
 	return mux
 }
 
+func TestPostsHandler_BasePath(t *testing.T) {
+	repo := &testMockRepo{create: func(_ context.Context, p posts.CreateParams) (*posts.Post, error) {
+		return &posts.Post{ID: uuid.New(), Title: p.Title, Slug: p.Slug, S3Key: p.S3Key, Status: posts.Draft}, nil
+	}}
+	st := &testMockStorage{upload: func(context.Context, string, io.Reader, string) error { return nil }}
+	svc := posts.NewService(repo, st, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+	h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{BasePath: "/api/v1"})
+	mux := http.NewServeMux()
+	mux.HandleFunc(Route("/api/v1", "POST /posts"), h.Create())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(`{"title":"Hi","slug":"hi","content":"# Hi"}`))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if loc := rec.Header().Get("Location"); loc != "/api/v1/posts/hi" {
+		t.Errorf("Location %q", loc)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unprefixed route: status %d, want 404", rec.Code)
+	}
+}
+
 func TestPostsHandler_Create(t *testing.T) {
 	h, repo, st := testHandler(t)
-	repo.create = func(ctx context.Context, title, slug, s3Key string) (*posts.Post, error) {
-		return &posts.Post{ID: uuid.New(), Title: title, Slug: slug, S3Key: s3Key, Status: posts.Draft}, nil
+	repo.create = func(ctx context.Context, p posts.CreateParams) (*posts.Post, error) {
+		return &posts.Post{ID: uuid.New(), Title: p.Title, Slug: p.Slug, S3Key: p.S3Key, Status: posts.Draft}, nil
 	}
 	st.upload = func(context.Context, string, io.Reader, string) error { return nil }
 
@@ -174,6 +288,98 @@ func TestPostsHandler_Create_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestPostsHandler_RequireJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"create form data", http.MethodPost, "/posts", "application/x-www-form-urlencoded", "title=Hello", http.StatusUnsupportedMediaType},
+		{"create text/plain", http.MethodPost, "/posts", "text/plain", `{"title":"Hello"}`, http.StatusUnsupportedMediaType},
+		{"create missing", http.MethodPost, "/posts", "", `{"title":"Hello"}`, http.StatusUnsupportedMediaType},
+		{"update text/plain", http.MethodPut, "/posts/hello", "text/plain", `{"title":"Hello"}`, http.StatusUnsupportedMediaType},
+		{"exists form data", http.MethodPost, "/posts/exists", "multipart/form-data; boundary=x", "--x--", http.StatusUnsupportedMediaType},
+		{"publish text/plain", http.MethodPatch, "/posts/hello/publish", "text/plain", `{"title":"Hello"}`, http.StatusUnsupportedMediaType},
+		{"charset allowed", http.MethodPost, "/posts", "application/json; charset=utf-8", `not json`, http.StatusBadRequest},
+		{"publish without body", http.MethodPatch, "/posts/hello/publish", "", "", http.StatusNotFound},
+		{"raw content", http.MethodPut, "/posts/hello/content", "text/markdown", "# Hi", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, _ := testHandler(t)
+			h.requireJSON = true
+			repo.getBySlug = func(context.Context, string) (*posts.Post, error) { return nil, posts.ErrNotFound }
+			repo.publish = func(context.Context, string) (*posts.Post, error) { return nil, posts.ErrNotFound }
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want == http.StatusUnsupportedMediaType {
+				if e := decodeAPIError(t, rec); e.Code != "UNSUPPORTED_MEDIA_TYPE" {
+					t.Errorf("code = %q, want UNSUPPORTED_MEDIA_TYPE", e.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestPostsHandler_Create_CreatedAt(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt string
+		key       string
+		wantCode  int
+		want      time.Time
+	}{
+		{"admin override", "2015-06-01T12:00:00Z", "admin", http.StatusCreated, time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{"offset kept as instant", "2015-06-01T14:00:00+02:00", "admin", http.StatusCreated, time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{"write key", "2015-06-01T12:00:00Z", "writer", http.StatusForbidden, time.Time{}},
+		{"not RFC 3339", "June 1, 2015", "admin", http.StatusBadRequest, time.Time{}},
+		{"far future", "2999-01-01T00:00:00Z", "admin", http.StatusBadRequest, time.Time{}},
+		{"before 1970", "1969-12-31T23:59:59Z", "admin", http.StatusBadRequest, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, st := testHandler(t)
+			h.apiKeys = middleware.APIKeys{"admin": middleware.AllScopes, "writer": {middleware.ScopeWrite}}
+			var got *time.Time
+			repo.create = func(_ context.Context, p posts.CreateParams) (*posts.Post, error) {
+				got = p.CreatedAt
+				return &posts.Post{ID: uuid.New(), Slug: p.Slug, Status: posts.Draft}, nil
+			}
+			st.upload = func(context.Context, string, io.Reader, string) error { return nil }
+
+			body := `{"title":"Old","slug":"old","content":"# Old","created_at":"` + tt.createdAt + `"}`
+			req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(middleware.APIKeyHeader, tt.key)
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantCode != http.StatusCreated {
+				if got != nil {
+					t.Error("post created despite the error")
+				}
+				return
+			}
+			if got == nil || !got.Equal(tt.want) {
+				t.Errorf("CreatedAt = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestPostsHandler_Create_ValidationError(t *testing.T) {
 	h, _, _ := testHandler(t)
 	body := bytes.NewBufferString(`{"title":"","slug":"","content":""}`)
@@ -188,7 +394,7 @@ func TestPostsHandler_Create_ValidationError(t *testing.T) {
 
 func TestPostsHandler_Create_Conflict(t *testing.T) {
 	h, repo, st := testHandler(t)
-	repo.create = func(context.Context, string, string, string) (*posts.Post, error) {
+	repo.create = func(context.Context, posts.CreateParams) (*posts.Post, error) {
 		return nil, posts.ErrSlugExists
 	}
 	st.upload = func(context.Context, string, io.Reader, string) error { return nil }
@@ -203,6 +409,131 @@ func TestPostsHandler_Create_Conflict(t *testing.T) {
 	}
 }
 
+func TestPostsHandler_Create_CanonicalURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		canonical string
+		wantCode  int
+		wantURL   *string
+	}{
+		{"absent", ``, http.StatusCreated, nil},
+		{"empty", `,"canonical_url":""`, http.StatusCreated, nil},
+		{"valid", `,"canonical_url":"https://original.example/post"`, http.StatusCreated, ptr("https://original.example/post")},
+		{"relative", `,"canonical_url":"/post"`, http.StatusBadRequest, nil},
+		{"bad scheme", `,"canonical_url":"javascript:alert(1)"`, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, _ := testHandler(t)
+			var got *string
+			repo.create = func(_ context.Context, p posts.CreateParams) (*posts.Post, error) {
+				got = p.CanonicalURL
+				return &posts.Post{ID: uuid.New(), Title: p.Title, Slug: p.Slug, CanonicalURL: p.CanonicalURL}, nil
+			}
+
+			body := bytes.NewBufferString(`{"title":"Hello","slug":"hello","content":"# Hi"` + tt.canonical + `}`)
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/posts", body))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if rec.Code == http.StatusBadRequest {
+				if !strings.Contains(rec.Body.String(), `"canonical_url":"must be an absolute http(s) URL"`) {
+					t.Errorf("body %s", rec.Body)
+				}
+				return
+			}
+			if (got == nil) != (tt.wantURL == nil) || (got != nil && *got != *tt.wantURL) {
+				t.Errorf("stored canonical URL %v, want %v", got, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_Create_Tags(t *testing.T) {
+	longTag := strings.Repeat("a", 33)
+	tooMany := make([]string, 11)
+	for i := range tooMany {
+		tooMany[i] = "tag-" + strconv.Itoa(i)
+	}
+	tests := []struct {
+		name     string
+		tags     []string
+		wantCode int
+		wantErrs map[string]string
+		wantTags []string
+	}{
+		{
+			name:     "too many",
+			tags:     tooMany,
+			wantCode: http.StatusBadRequest,
+			wantErrs: map[string]string{"tags": "max 10 tags"},
+		},
+		{
+			name:     "too long",
+			tags:     []string{"go", longTag},
+			wantCode: http.StatusBadRequest,
+			wantErrs: map[string]string{"tags[1]": "max 32 characters"},
+		},
+		{
+			name:     "bad pattern",
+			tags:     []string{"go", "web dev", "c++"},
+			wantCode: http.StatusBadRequest,
+			wantErrs: map[string]string{
+				"tags[1]": "must be lowercase alphanumeric with hyphens",
+				"tags[2]": "must be lowercase alphanumeric with hyphens",
+			},
+		},
+		{
+			name:     "normalized and deduplicated",
+			tags:     append([]string{" Go ", "go", "GO"}, tooMany[:9]...),
+			wantCode: http.StatusCreated,
+			wantTags: append([]string{"go"}, tooMany[:9]...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, _ := testHandler(t)
+			var gotTags []string
+			repo.create = func(_ context.Context, p posts.CreateParams) (*posts.Post, error) {
+				gotTags = p.Tags
+				return &posts.Post{ID: uuid.New(), Title: p.Title, Slug: p.Slug, Tags: p.Tags, Status: posts.Draft}, nil
+			}
+
+			payload, _ := json.Marshal(PostRequest{Title: "T", Slug: "t", Content: "c", Tags: tt.tags})
+			req := httptest.NewRequest(http.MethodPost, "/posts", bytes.NewReader(payload))
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status %d, body %s", rec.Code, rec.Body.Bytes())
+			}
+			if tt.wantErrs != nil {
+				var resp struct {
+					Error struct {
+						Code    string            `json:"code"`
+						Details map[string]string `json:"details"`
+					} `json:"error"`
+				}
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if resp.Error.Code != "VALIDATION_ERROR" {
+					t.Errorf("code %q", resp.Error.Code)
+				}
+				for k, v := range tt.wantErrs {
+					if resp.Error.Details[k] != v {
+						t.Errorf("details[%q] = %q, want %q", k, resp.Error.Details[k], v)
+					}
+				}
+				return
+			}
+			if !slices.Equal(gotTags, tt.wantTags) {
+				t.Errorf("persisted tags %q, want %q", gotTags, tt.wantTags)
+			}
+		})
+	}
+}
+
 func TestPostsHandler_GetBySlug(t *testing.T) {
 	h, repo, _ := testHandler(t)
 	want := &posts.Post{ID: uuid.New(), Title: "A", Slug: "a", Status: posts.Draft}
@@ -223,6 +554,77 @@ func TestPostsHandler_GetBySlug(t *testing.T) {
 	}
 }
 
+func TestPostsHandler_GetBySlug_Nav(t *testing.T) {
+	order := []string{"first", "middle", "last"}
+	tests := []struct {
+		slug     string
+		wantPrev string
+		wantNext string
+	}{
+		{slug: "first", wantNext: "middle"},
+		{slug: "middle", wantPrev: "first", wantNext: "last"},
+		{slug: "last", wantPrev: "middle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			h, repo, _ := testHandler(t)
+			repo.getBySlug = func(_ context.Context, slug string) (*posts.Post, error) {
+				return &posts.Post{ID: uuid.New(), Slug: slug, Status: posts.Published}, nil
+			}
+			repo.adjacent = func(_ context.Context, p *posts.Post) (string, string, error) {
+				i := slices.Index(order, p.Slug)
+				var prev, next string
+				if i > 0 {
+					prev = order[i-1]
+				}
+				if i < len(order)-1 {
+					next = order[i+1]
+				}
+				return prev, next, nil
+			}
+
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/"+tt.slug+"?nav=true", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d", rec.Code)
+			}
+			var resp map[string]any
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp["slug"] != tt.slug {
+				t.Errorf("slug %v", resp["slug"])
+			}
+			for key, want := range map[string]string{"prev_slug": tt.wantPrev, "next_slug": tt.wantNext} {
+				got, ok := resp[key]
+				if want == "" {
+					if ok {
+						t.Errorf("%s = %v, want omitted", key, got)
+					}
+					continue
+				}
+				if got != want {
+					t.Errorf("%s = %v, want %q", key, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("omitted without nav", func(t *testing.T) {
+		h, repo, _ := testHandler(t)
+		repo.getBySlug = func(context.Context, string) (*posts.Post, error) { return &posts.Post{Slug: "middle"}, nil }
+		repo.adjacent = func(context.Context, *posts.Post) (string, string, error) {
+			t.Error("Adjacent should not be called without nav")
+			return "", "", nil
+		}
+		rec := httptest.NewRecorder()
+		testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/middle", nil))
+		if strings.Contains(rec.Body.String(), "prev_slug") || strings.Contains(rec.Body.String(), "next_slug") {
+			t.Errorf("body %s", rec.Body.String())
+		}
+	})
+}
+
 func TestPostsHandler_GetBySlug_NotFound(t *testing.T) {
 	h, repo, _ := testHandler(t)
 	repo.getBySlug = func(context.Context, string) (*posts.Post, error) { return nil, posts.ErrNotFound }
@@ -235,6 +637,46 @@ func TestPostsHandler_GetBySlug_NotFound(t *testing.T) {
 	}
 }
 
+func TestPostsHandler_GetByID(t *testing.T) {
+	known := uuid.New()
+	h, repo, _ := testHandler(t)
+	repo.getByID = func(_ context.Context, id uuid.UUID) (*posts.Post, error) {
+		if id != known {
+			return nil, posts.ErrNotFound
+		}
+		return &posts.Post{ID: id, Slug: "renamed"}, nil
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"found", "/posts/id/" + known.String(), http.StatusOK},
+		{"not found", "/posts/id/" + uuid.NewString(), http.StatusNotFound},
+		{"malformed", "/posts/id/not-a-uuid", http.StatusBadRequest},
+		{"other prefix", "/posts/foo/" + known.String(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want == http.StatusOK {
+				var post posts.Post
+				if err := json.NewDecoder(rec.Body).Decode(&post); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if post.ID != known || post.Slug != "renamed" {
+					t.Errorf("post %+v", post)
+				}
+			}
+		})
+	}
+}
+
 func TestPostsHandler_GetContent(t *testing.T) {
 	h, repo, st := testHandler(t)
 	repo.getBySlug = func(context.Context, string) (*posts.Post, error) {
@@ -258,6 +700,448 @@ func TestPostsHandler_GetContent(t *testing.T) {
 	}
 }
 
+func TestPostsHandler_GetContent_Accept(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   posts.Format
+		accept   string
+		wantCode int
+		wantType string
+	}{
+		{"no header", posts.FormatMarkdown, "", http.StatusOK, "text/markdown; charset=utf-8"},
+		{"any", posts.FormatMarkdown, "*/*", http.StatusOK, "text/markdown; charset=utf-8"},
+		{"text wildcard", posts.FormatMarkdown, "text/*", http.StatusOK, "text/markdown; charset=utf-8"},
+		{"markdown", posts.FormatMarkdown, "text/markdown", http.StatusOK, "text/markdown; charset=utf-8"},
+		{"plain", posts.FormatMarkdown, "text/plain", http.StatusOK, "text/plain; charset=utf-8"},
+		{"plain preferred by q", posts.FormatMarkdown, "text/markdown;q=0.5, text/plain", http.StatusOK, "text/plain; charset=utf-8"},
+		{"plain with wildcard fallback", posts.FormatMarkdown, "text/plain, */*;q=0.1", http.StatusOK, "text/plain; charset=utf-8"},
+		{"native for asciidoc", posts.FormatAsciiDoc, "text/asciidoc, text/plain", http.StatusOK, "text/asciidoc; charset=utf-8"},
+		{"markdown refused", posts.FormatMarkdown, "text/markdown;q=0, text/*", http.StatusOK, "text/plain; charset=utf-8"},
+		{"not acceptable", posts.FormatMarkdown, "application/json", http.StatusNotAcceptable, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, st := testHandler(t)
+			repo.getBySlug = func(context.Context, string) (*posts.Post, error) {
+				return &posts.Post{Slug: "a", S3Key: "posts/a.md", Format: tt.format}, nil
+			}
+			st.download = func(context.Context, string) (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader("# Hello")), nil
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/posts/a/content", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantType != "" {
+				if ct := rec.Header().Get("Content-Type"); ct != tt.wantType {
+					t.Errorf("Content-Type %q, want %q", ct, tt.wantType)
+				}
+			}
+			if v := rec.Header().Get("Vary"); v != "Accept" {
+				t.Errorf("Vary %q", v)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_UpdateContent_InvalidUTF8(t *testing.T) {
+	h, repo, _ := testHandler(t)
+	repo.getBySlug = func(context.Context, string) (*posts.Post, error) {
+		t.Error("post should not be looked up")
+		return nil, posts.ErrNotFound
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/posts/a/content", bytes.NewReader([]byte{'#', ' ', 0xff, 0xfe}))
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "invalid utf-8") {
+		t.Errorf("body %s", rec.Body.String())
+	}
+}
+
+func TestPostsHandler_Import_InvalidUTF8(t *testing.T) {
+	h, repo, _ := testHandler(t)
+	repo.create = func(context.Context, posts.CreateParams) (*posts.Post, error) {
+		t.Error("post should not be created")
+		return nil, errors.New("unexpected")
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fw, err := zw.Create("bad.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write([]byte{'#', ' ', 'a', 0xc3, 0x28}); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/import", &buf)
+	req.Header.Set("Content-Type", "application/zip")
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	var report ImportReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.Failed != 1 || len(report.Results) != 1 || report.Results[0].Details["content"] != "invalid utf-8" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestValidateUpdateRequest_InvalidUTF8(t *testing.T) {
+	h, _, _ := testHandler(t)
+	content := "caf\xe9"
+	errs := h.validateUpdateRequest(UpdatePostRequest{Content: &content})
+	if errs["content"] != "invalid utf-8" {
+		t.Errorf("errs = %v", errs)
+	}
+}
+
+func TestPostsHandler_GetContent_Head(t *testing.T) {
+	const body = "# Hello"
+	sum := sha256.Sum256([]byte(body))
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	h, repo, st := testHandler(t)
+	repo.getBySlug = func(context.Context, string) (*posts.Post, error) {
+		return &posts.Post{Slug: "a", S3Key: "posts/a.md", Status: posts.Published, ContentSHA256: hex.EncodeToString(sum[:]), UpdatedAt: updated}, nil
+	}
+	downloads := 0
+	st.download = func(context.Context, string) (io.ReadCloser, error) {
+		downloads++
+		return io.NopCloser(strings.NewReader(body)), nil
+	}
+	st.size = func(context.Context, string) (int64, error) { return int64(len(body)), nil }
+
+	headers := make(map[string]http.Header)
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		rec := httptest.NewRecorder()
+		testMux(h).ServeHTTP(rec, httptest.NewRequest(method, "/posts/a/content", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", method, rec.Code)
+		}
+		headers[method] = rec.Header()
+		switch method {
+		case http.MethodHead:
+			if rec.Body.Len() != 0 {
+				t.Errorf("HEAD body %q, want empty", rec.Body)
+			}
+			if downloads != 0 {
+				t.Errorf("HEAD downloaded the content")
+			}
+		case http.MethodGet:
+			if rec.Body.String() != body {
+				t.Errorf("GET body %q", rec.Body)
+			}
+		}
+	}
+	for _, name := range []string{"Content-Type", "Content-Length", "ETag", "Last-Modified"} {
+		head, get := headers[http.MethodHead].Get(name), headers[http.MethodGet].Get(name)
+		if head == "" || head != get {
+			t.Errorf("%s: HEAD %q, GET %q", name, head, get)
+		}
+	}
+	if got := headers[http.MethodHead].Get("Content-Length"); got != "7" {
+		t.Errorf("Content-Length %q, want 7", got)
+	}
+	if got := headers[http.MethodHead].Get("Last-Modified"); got != "Wed, 01 May 2024 12:00:00 GMT" {
+		t.Errorf("Last-Modified %q", got)
+	}
+}
+
+func TestPostsHandler_GetContent_Frontmatter(t *testing.T) {
+	h, repo, st := testHandler(t)
+	post := &posts.Post{Title: "Hello", Slug: "a", S3Key: "posts/a.md", Status: posts.Published, Tags: []string{"go"}}
+	repo.getBySlug = func(context.Context, string) (*posts.Post, error) { return post, nil }
+	st.download = func(context.Context, string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("# Hello")), nil
+	}
+
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/a/content?frontmatter=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	if want := posts.RenderFrontmatter(post) + "# Hello"; rec.Body.String() != want {
+		t.Errorf("body %q, want %q", rec.Body.String(), want)
+	}
+	if etag := rec.Header().Get("ETag"); etag != "" {
+		t.Errorf("ETag %q should be omitted with frontmatter", etag)
+	}
+}
+
+func TestPostsHandler_AsciiDocRoundTrip(t *testing.T) {
+	h, repo, st := testHandler(t)
+	var stored *posts.Post
+	repo.create = func(_ context.Context, p posts.CreateParams) (*posts.Post, error) {
+		stored = &posts.Post{ID: uuid.New(), Title: p.Title, Slug: p.Slug, S3Key: p.S3Key, Status: posts.Draft, Format: p.Format}
+		return stored, nil
+	}
+	repo.getBySlug = func(context.Context, string) (*posts.Post, error) { return stored, nil }
+	var body []byte
+	st.upload = func(_ context.Context, _ string, r io.Reader, contentType string) error {
+		if contentType != "text/asciidoc" {
+			t.Errorf("upload Content-Type %q", contentType)
+		}
+		var err error
+		body, err = io.ReadAll(r)
+		return err
+	}
+	st.download = func(context.Context, string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	content := "= Hello\n\nLiteral ![x](data:image/png;base64,aGk=) stays as text."
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"title":"Hello","slug":"hello","content":"`+strings.ReplaceAll(content, "\n", `\n`)+`","format":"asciidoc"}`))
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Create: status %d, body %s", rec.Code, rec.Body.Bytes())
+	}
+	var post posts.Post
+	if err := json.NewDecoder(rec.Body).Decode(&post); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if post.Format != posts.FormatAsciiDoc {
+		t.Errorf("format %q", post.Format)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/posts/hello/content", nil)
+	rec = httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetContent: status %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/asciidoc; charset=utf-8" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	if rec.Body.String() != content {
+		t.Errorf("body %q, want %q", rec.Body.String(), content)
+	}
+}
+
+func TestPostsHandler_Create_StrictHeadings(t *testing.T) {
+	repo := &testMockRepo{create: func(context.Context, posts.CreateParams) (*posts.Post, error) {
+		t.Error("post should not be stored")
+		return nil, nil
+	}}
+	svc := posts.NewService(repo, &testMockStorage{}, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r", StrictHeadings: true})
+	h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{})
+
+	body := strings.NewReader(`{"title":"X","slug":"x","content":"# One\n\n#### Deep"}`)
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/posts", body))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Error APIError `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Error.Code != "VALIDATION_ERROR" || !strings.Contains(resp.Error.Details["content"], "skips from level 1 to 4") {
+		t.Errorf("error %+v", resp.Error)
+	}
+}
+
+func TestPostsHandler_Create_RejectEmbeddedImages(t *testing.T) {
+	repo := &testMockRepo{create: func(context.Context, posts.CreateParams) (*posts.Post, error) {
+		t.Error("post should not be stored")
+		return nil, nil
+	}}
+	svc := posts.NewService(repo, &testMockStorage{}, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r", ImageEmbedMode: posts.ImageEmbedReject})
+	h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{})
+
+	body := strings.NewReader(`{"title":"X","slug":"x","content":"![a](data:image/png;base64,AAAA)"}`)
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/posts", body))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Error APIError `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Error.Code != "VALIDATION_ERROR" || resp.Error.Details["content"] != "data-URL images are not allowed" {
+		t.Errorf("error %+v", resp.Error)
+	}
+}
+
+func TestPostsHandler_ReservedSlug(t *testing.T) {
+	tests := []struct {
+		name     string
+		reserved []string
+		method   string
+		path     string
+		body     string
+		want     int
+	}{
+		{"default on create", nil, http.MethodPost, "/posts", `{"title":"X","slug":"health","content":"c"}`, http.StatusBadRequest},
+		{"default on rename", nil, http.MethodPut, "/posts/a", `{"slug":"tags"}`, http.StatusBadRequest},
+		{"configured", []string{"about"}, http.MethodPost, "/posts", `{"title":"X","slug":"about","content":"c"}`, http.StatusBadRequest},
+		{"configured replaces defaults", []string{"about"}, http.MethodPost, "/posts", `{"title":"X","slug":"health","content":"c"}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &testMockRepo{create: func(_ context.Context, p posts.CreateParams) (*posts.Post, error) {
+				return &posts.Post{ID: uuid.New(), Slug: p.Slug}, nil
+			}}
+			st := &testMockStorage{upload: func(context.Context, string, io.Reader, string) error { return nil }}
+			svc := posts.NewService(repo, st, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+			h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{ReservedSlugs: tt.reserved})
+
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want == http.StatusBadRequest && !strings.Contains(rec.Body.String(), `"slug":"reserved"`) {
+				t.Errorf("body %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestPostsHandler_Create_InvalidFormat(t *testing.T) {
+	h, _, _ := testHandler(t)
+	body := bytes.NewBufferString(`{"title":"X","slug":"x","content":"c","format":"docx"}`)
+	req := httptest.NewRequest(http.MethodPost, "/posts", body)
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"format"`) {
+		t.Errorf("body %s", rec.Body.String())
+	}
+}
+
+func TestPostsHandler_UpdateContent_IfMatch(t *testing.T) {
+	stored := "# Original"
+	sum := sha256.Sum256([]byte(stored))
+	h, repo, st := testHandler(t)
+	post := func() *posts.Post {
+		return &posts.Post{ID: uuid.New(), Title: "T", Slug: "a", S3Key: "posts/a.md", ContentSHA256: hex.EncodeToString(sum[:])}
+	}
+	repo.getBySlug = func(context.Context, string) (*posts.Post, error) { return post(), nil }
+	repo.getByID = func(context.Context, uuid.UUID) (*posts.Post, error) { return post(), nil }
+	repo.update = func(_ context.Context, p posts.UpdateParams) (*posts.Post, error) {
+		if p.ExpectedContentSHA256 == nil || *p.ExpectedContentSHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("ExpectedContentSHA256 = %v", p.ExpectedContentSHA256)
+		}
+		return &posts.Post{ID: p.ID, Slug: p.Slug, ContentSHA256: p.ContentSHA256}, nil
+	}
+	st.download = func(context.Context, string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(stored)), nil
+	}
+	var uploads int
+	st.upload = func(context.Context, string, io.Reader, string) error {
+		uploads++
+		return nil
+	}
+
+	getReq := httptest.NewRequest(http.MethodGet, "/posts/a/content", nil)
+	getRec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(getRec, getReq)
+	etag := getRec.Header().Get("ETag")
+	if etag != `"`+hex.EncodeToString(sum[:])+`"` {
+		t.Fatalf("GetContent ETag = %q", etag)
+	}
+
+	t.Run("stale etag", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/posts/a/content", strings.NewReader("# Mine"))
+		req.Header.Set("If-Match", `"0000"`)
+		rec := httptest.NewRecorder()
+		testMux(h).ServeHTTP(rec, req)
+		if rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("expected 412, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if uploads != 0 {
+			t.Errorf("stale write uploaded content")
+		}
+	})
+
+	t.Run("matching etag", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/posts/a/content", strings.NewReader("# Mine"))
+		req.Header.Set("If-Match", etag)
+		rec := httptest.NewRecorder()
+		testMux(h).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if uploads != 1 {
+			t.Errorf("uploads = %d, want 1", uploads)
+		}
+		if got, want := rec.Header().Get("ETag"), posts.ContentETag([]byte("# Mine")); got != want {
+			t.Errorf("ETag = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("changed before write", func(t *testing.T) {
+		repo.update = func(context.Context, posts.UpdateParams) (*posts.Post, error) {
+			return nil, posts.ErrPreconditionFailed
+		}
+		req := httptest.NewRequest(http.MethodPut, "/posts/a/content", strings.NewReader("# Mine"))
+		req.Header.Set("If-Match", etag)
+		rec := httptest.NewRecorder()
+		testMux(h).ServeHTTP(rec, req)
+		if rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("expected 412, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestPostsHandler_GetContent_ProtectDraft(t *testing.T) {
+	tests := []struct {
+		name   string
+		status posts.Status
+		key    string
+		want   int
+	}{
+		{"draft without key", posts.Draft, "", http.StatusNotFound},
+		{"draft with wrong key", posts.Draft, "nope", http.StatusUnauthorized},
+		{"draft with key", posts.Draft, "secret", http.StatusOK},
+		{"published without key", posts.Published, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &testMockRepo{getBySlug: func(context.Context, string) (*posts.Post, error) {
+				return &posts.Post{Slug: "a", S3Key: "posts/a.md", Status: tt.status}, nil
+			}}
+			st := &testMockStorage{download: func(context.Context, string) (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader("# Hello")), nil
+			}}
+			svc := posts.NewService(repo, st, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+			h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{APIKeys: middleware.APIKeys{"secret": {middleware.ScopeRead}}, ProtectDraftContent: true})
+
+			req := httptest.NewRequest(http.MethodGet, "/posts/a/content", nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
 func TestPostsHandler_GetContent_NotFound(t *testing.T) {
 	h, repo, _ := testHandler(t)
 	repo.getBySlug = func(context.Context, string) (*posts.Post, error) { return nil, posts.ErrNotFound }
@@ -270,12 +1154,64 @@ func TestPostsHandler_GetContent_NotFound(t *testing.T) {
 	}
 }
 
+func TestPostsHandler_GetContent_ContentMissing(t *testing.T) {
+	for _, mode := range []posts.ContentMissingMode{posts.ContentMissingFail, posts.ContentMissingPlaceholder} {
+		t.Run(string(mode), func(t *testing.T) {
+			repo := &testMockRepo{}
+			st := &testMockStorage{}
+			repo.getBySlug = func(context.Context, string) (*posts.Post, error) {
+				return &posts.Post{Slug: "a", S3Key: "posts/a.md", Status: posts.Published}, nil
+			}
+			st.download = func(context.Context, string) (io.ReadCloser, error) { return nil, storage.ErrNotFound }
+			svc := posts.NewService(repo, st, nil, nil, posts.ServiceConfig{
+				S3Bucket:           "b",
+				AWSRegion:          "r",
+				ContentMissing:     mode,
+				ContentPlaceholder: "gone\n",
+			})
+			h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{})
+
+			req := httptest.NewRequest(http.MethodGet, "/posts/a/content", nil)
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, req)
+			if mode == posts.ContentMissingFail {
+				if rec.Code != http.StatusNotFound {
+					t.Errorf("expected 404, got %d", rec.Code)
+				}
+				return
+			}
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if rec.Body.String() != "gone\n" {
+				t.Errorf("body = %q, want placeholder", rec.Body.String())
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", got)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_NilLogger(t *testing.T) {
+	repo := &testMockRepo{}
+	repo.getBySlug = func(context.Context, string) (*posts.Post, error) { return nil, errors.New("db down") }
+	svc := posts.NewService(repo, &testMockStorage{}, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+	h := NewPostsHandler(svc, nil, PostsHandlerConfig{})
+
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/a", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rec.Code)
+	}
+}
+
 func TestPostsHandler_List(t *testing.T) {
 	h, repo, _ := testHandler(t)
 	repo.list = func(context.Context, posts.ListParams) ([]*posts.Post, error) {
 		return []*posts.Post{{ID: uuid.New(), Slug: "one"}}, nil
 	}
-	repo.count = func(context.Context, *posts.Status) (int64, error) { return 1, nil }
+	repo.count = func(context.Context, []posts.Status) (int64, error) { return 1, nil }
 
 	req := httptest.NewRequest(http.MethodGet, "/posts", nil)
 	rec := httptest.NewRecorder()
@@ -295,14 +1231,214 @@ func TestPostsHandler_List_InvalidStatus(t *testing.T) {
 	}
 }
 
+func TestPostsHandler_List_MultipleStatuses(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []posts.Status
+	}{
+		{"", nil},
+		{"?status=draft", []posts.Status{posts.Draft}},
+		{"?status=draft,published", []posts.Status{posts.Draft, posts.Published}},
+		{"?status=published,%20draft,published", []posts.Status{posts.Published, posts.Draft}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			h, repo, _ := testHandler(t)
+			var counted, listed []posts.Status
+			repo.count = func(_ context.Context, statuses []posts.Status) (int64, error) {
+				counted = statuses
+				return 1, nil
+			}
+			repo.list = func(_ context.Context, p posts.ListParams) ([]*posts.Post, error) {
+				listed = p.Statuses
+				return []*posts.Post{{ID: uuid.New(), Slug: "one"}}, nil
+			}
+
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts"+tt.query, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			if !slices.Equal(counted, tt.want) || !slices.Equal(listed, tt.want) {
+				t.Errorf("count %v, list %v, want %v", counted, listed, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_List_InvalidStatusInList(t *testing.T) {
+	h, _, _ := testHandler(t)
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts?status=draft,scheduled", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if e := decodeAPIError(t, rec); e.Code != "VALIDATION_ERROR" || e.Details["status"] != `unknown status "scheduled"` {
+		t.Errorf("error %+v", e)
+	}
+}
+
+func TestPostsHandler_List_Pagination(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantDetails map[string]string
+		wantPage    int
+		wantPerPage int
+	}{
+		{"absent", "", http.StatusOK, nil, 1, 20},
+		{"valid", "?page=3&per_page=5", http.StatusOK, nil, 3, 5},
+		{"page not a number", "?page=abc", http.StatusBadRequest, map[string]string{"page": "must be a non-negative integer"}, 0, 0},
+		{"negative per_page", "?per_page=-5", http.StatusBadRequest, map[string]string{"per_page": "must be a non-negative integer"}, 0, 0},
+		{"both invalid", "?page=1.5&per_page=x", http.StatusBadRequest, map[string]string{
+			"page":     "must be a non-negative integer",
+			"per_page": "must be a non-negative integer",
+		}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, _ := testHandler(t)
+			repo.list = func(context.Context, posts.ListParams) ([]*posts.Post, error) { return nil, nil }
+			repo.count = func(context.Context, []posts.Status) (int64, error) { return 0, nil }
+
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				var resp struct {
+					Error APIError `json:"error"`
+				}
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if resp.Error.Code != "VALIDATION_ERROR" || !maps.Equal(resp.Error.Details, tt.wantDetails) {
+					t.Errorf("error %+v", resp.Error)
+				}
+				return
+			}
+			var result posts.ListResult
+			if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if result.Page != tt.wantPage || result.PerPage != tt.wantPerPage {
+				t.Errorf("page %d per_page %d, want %d and %d", result.Page, result.PerPage, tt.wantPage, tt.wantPerPage)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_List_PageOutOfRange(t *testing.T) {
+	repo := &testMockRepo{
+		count: func(context.Context, []posts.Status) (int64, error) { return 15, nil },
+	}
+	svc := posts.NewService(repo, &testMockStorage{}, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r", PageOutOfRange: posts.PageOutOfRangeFail})
+	h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{})
+
+	req := httptest.NewRequest(http.MethodGet, "/posts?page=9999&per_page=10", nil)
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", rec.Code)
+	}
+	apiErr := decodeAPIError(t, rec)
+	if apiErr.Code != "VALIDATION_ERROR" || apiErr.Details["page"] != "max 2" {
+		t.Errorf("error %+v", apiErr)
+	}
+}
+
+func TestPostsHandler_List_AnonymousMaxPerPage(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		query       string
+		wantPerPage int
+	}{
+		{"anonymous over cap", "", "?per_page=100", 10},
+		{"anonymous default over cap", "", "", 10},
+		{"anonymous under cap", "", "?per_page=5", 5},
+		{"invalid key is anonymous", "wrong", "?per_page=100", 10},
+		{"authenticated", "reader", "?per_page=100", 100},
+		{"authenticated default", "reader", "", 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &testMockRepo{
+				list:  func(context.Context, posts.ListParams) ([]*posts.Post, error) { return nil, nil },
+				count: func(context.Context, []posts.Status) (int64, error) { return 0, nil },
+			}
+			svc := posts.NewService(repo, &testMockStorage{}, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+			keys := middleware.APIKeys{"reader": {middleware.ScopeRead}}
+			h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{APIKeys: keys, AnonymousMaxPerPage: 10})
+
+			req := httptest.NewRequest(http.MethodGet, "/posts"+tt.query, nil)
+			if tt.key != "" {
+				req.Header.Set(middleware.APIKeyHeader, tt.key)
+			}
+			rec := httptest.NewRecorder()
+			middleware.IdentifyKey(keys)(h.List()).ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			var result posts.ListResult
+			if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if result.PerPage != tt.wantPerPage {
+				t.Errorf("per_page %d, want %d", result.PerPage, tt.wantPerPage)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_List_SortByViews(t *testing.T) {
+	h, repo, _ := testHandler(t)
+	repo.list = func(_ context.Context, p posts.ListParams) ([]*posts.Post, error) {
+		if p.Sort != posts.SortViews {
+			t.Errorf("Sort = %q, want views", p.Sort)
+		}
+		if !slices.Equal(p.Statuses, []posts.Status{posts.Published}) {
+			t.Errorf("Statuses = %v, want published", p.Statuses)
+		}
+		return []*posts.Post{{Slug: "hot", Views: 10}, {Slug: "warm", Views: 3}}, nil
+	}
+	repo.count = func(context.Context, []posts.Status) (int64, error) { return 2, nil }
+
+	req := httptest.NewRequest(http.MethodGet, "/posts?sort=views", nil)
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("List: status %d", rec.Code)
+	}
+	var result posts.ListResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(result.Posts) != 2 || result.Posts[0].Slug != "hot" || result.Posts[0].Views != 10 {
+		t.Errorf("got %+v", result.Posts)
+	}
+}
+
+func TestPostsHandler_List_InvalidSort(t *testing.T) {
+	h, _, _ := testHandler(t)
+	req := httptest.NewRequest(http.MethodGet, "/posts?sort=random", nil)
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid sort, got %d", rec.Code)
+	}
+}
+
 func TestPostsHandler_Update(t *testing.T) {
 	h, repo, st := testHandler(t)
 	pid := uuid.New()
 	repo.getBySlug = func(context.Context, string) (*posts.Post, error) {
 		return &posts.Post{ID: pid, Title: "Old", Slug: "old", S3Key: "posts/old.md"}, nil
 	}
-	repo.update = func(ctx context.Context, id uuid.UUID, title, slug, s3Key string) (*posts.Post, error) {
-		return &posts.Post{ID: id, Title: title, Slug: slug, S3Key: s3Key}, nil
+	repo.update = func(ctx context.Context, p posts.UpdateParams) (*posts.Post, error) {
+		return &posts.Post{ID: p.ID, Title: p.Title, Slug: p.Slug, S3Key: p.S3Key}, nil
 	}
 	st.upload = func(context.Context, string, io.Reader, string) error { return nil }
 
@@ -316,6 +1452,83 @@ func TestPostsHandler_Update(t *testing.T) {
 	}
 }
 
+func TestPostsHandler_UpdateByID(t *testing.T) {
+	h, repo, st := testHandler(t)
+	pid := uuid.New()
+	repo.getByID = func(_ context.Context, id uuid.UUID) (*posts.Post, error) {
+		if id != pid {
+			return nil, posts.ErrNotFound
+		}
+		return &posts.Post{ID: pid, Title: "T", Slug: "current", S3Key: "posts/current.md"}, nil
+	}
+	repo.update = func(_ context.Context, p posts.UpdateParams) (*posts.Post, error) {
+		return &posts.Post{ID: p.ID, Title: p.Title, Slug: p.Slug, S3Key: p.S3Key}, nil
+	}
+	st.download = func(context.Context, string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("# Hi")), nil
+	}
+	st.upload = func(context.Context, string, io.Reader, string) error { return nil }
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"slug change", "/posts/id/" + pid.String(), `{"slug":"renamed"}`, http.StatusOK},
+		{"not found", "/posts/id/" + uuid.NewString(), `{"title":"X"}`, http.StatusNotFound},
+		{"malformed id", "/posts/id/nope", `{"title":"X"}`, http.StatusBadRequest},
+		{"no fields", "/posts/id/" + pid.String(), `{}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodPut, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var post posts.Post
+			if err := json.NewDecoder(rec.Body).Decode(&post); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if post.ID != pid || post.Slug != "renamed" || post.S3Key != "posts/renamed.md" {
+				t.Errorf("post %+v", post)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_Update_NotModified(t *testing.T) {
+	h, repo, st := testHandler(t)
+	sum := sha256.Sum256([]byte("# Same"))
+	repo.getBySlug = func(context.Context, string) (*posts.Post, error) {
+		return &posts.Post{ID: uuid.New(), Title: "T", Slug: "s", S3Key: "posts/s.md", ContentSHA256: hex.EncodeToString(sum[:])}, nil
+	}
+	st.upload = func(context.Context, string, io.Reader, string) error {
+		t.Error("unexpected upload")
+		return nil
+	}
+
+	body := bytes.NewBufferString(`{"content":"# Same"}`)
+	req := httptest.NewRequest(http.MethodPut, "/posts/s", body)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Update: status %d, body %s", rec.Code, rec.Body.Bytes())
+	}
+	var got map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got["not_modified"] != true || got["slug"] != "s" {
+		t.Errorf("got %v", got)
+	}
+}
+
 func TestPostsHandler_Update_InvalidJSON(t *testing.T) {
 	h, _, _ := testHandler(t)
 	body := bytes.NewBufferString(`not json`)
@@ -361,7 +1574,7 @@ func TestPostsHandler_Update_Conflict(t *testing.T) {
 	repo.getBySlug = func(context.Context, string) (*posts.Post, error) {
 		return &posts.Post{ID: pid, Title: "Old", Slug: "old", S3Key: "posts/old.md"}, nil
 	}
-	repo.update = func(context.Context, uuid.UUID, string, string, string) (*posts.Post, error) {
+	repo.update = func(context.Context, posts.UpdateParams) (*posts.Post, error) {
 		return nil, posts.ErrSlugExists
 	}
 	st.upload = func(context.Context, string, io.Reader, string) error { return nil }
@@ -393,8 +1606,57 @@ func TestPostsHandler_Delete(t *testing.T) {
 	}
 }
 
+func TestPostsHandler_Delete_DryRun(t *testing.T) {
+	h, repo, st := testHandler(t)
+	repo.getBySlug = func(context.Context, string) (*posts.Post, error) {
+		return &posts.Post{Slug: "d", S3Key: "posts/d.md"}, nil
+	}
+	repo.delete = func(context.Context, string) error {
+		t.Error("post deleted on dry run")
+		return nil
+	}
+	st.delete = func(_ context.Context, key string) error {
+		t.Errorf("object %s deleted on dry run", key)
+		return nil
+	}
+	st.deletePrefix = func(_ context.Context, prefix string) error {
+		t.Errorf("prefix %s deleted on dry run", prefix)
+		return nil
+	}
+	st.list = func(_ context.Context, prefix string) ([]string, error) {
+		if prefix != "posts/d/images/" {
+			t.Errorf("listed prefix %q", prefix)
+		}
+		return []string{"posts/d/images/1.png", "posts/d/images/2.webp"}, nil
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/posts/d?dry_run=true", nil)
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var plan posts.DeletePlan
+	if err := json.NewDecoder(rec.Body).Decode(&plan); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if plan.ContentKey != "posts/d.md" || !slices.Equal(plan.ImageKeys, []string{"posts/d/images/1.png", "posts/d/images/2.webp"}) {
+		t.Errorf("plan %+v", plan)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/posts/d?dry_run=maybe", nil)
+	rec = httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid dry_run: status %d, want 400", rec.Code)
+	}
+}
+
 func TestPostsHandler_Publish(t *testing.T) {
 	h, repo, _ := testHandler(t)
+	repo.getBySlug = func(context.Context, string) (*posts.Post, error) {
+		return &posts.Post{ID: uuid.New(), Slug: "p", Status: posts.Draft}, nil
+	}
 	repo.publish = func(context.Context, string) (*posts.Post, error) {
 		return &posts.Post{ID: uuid.New(), Slug: "p", Status: posts.Published}, nil
 	}
@@ -407,6 +1669,46 @@ func TestPostsHandler_Publish(t *testing.T) {
 	}
 }
 
+func TestPostsHandler_Publish_WithContent(t *testing.T) {
+	h, repo, st := testHandler(t)
+	repo.getBySlug = func(context.Context, string) (*posts.Post, error) {
+		return &posts.Post{ID: uuid.New(), Title: "T", Slug: "p", S3Key: "posts/p.md", Status: posts.Draft}, nil
+	}
+	repo.update = func(_ context.Context, p posts.UpdateParams) (*posts.Post, error) {
+		return &posts.Post{ID: p.ID, Title: p.Title, Slug: p.Slug}, nil
+	}
+	repo.publish = func(context.Context, string) (*posts.Post, error) {
+		return &posts.Post{ID: uuid.New(), Slug: "p", Status: posts.Published}, nil
+	}
+	var uploaded string
+	st.upload = func(_ context.Context, _ string, body io.Reader, _ string) error {
+		data, _ := io.ReadAll(body)
+		uploaded = string(data)
+		return nil
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/posts/p/publish", strings.NewReader(`{"content":"# Final"}`))
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Publish: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if uploaded != "# Final" {
+		t.Errorf("uploaded %q", uploaded)
+	}
+
+	repo.publish = func(context.Context, string) (*posts.Post, error) { return nil, errors.New("db down") }
+	req = httptest.NewRequest(http.MethodPatch, "/posts/p/publish", strings.NewReader(`{"content":"# Final"}`))
+	rec = httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("failed publish: status %d", rec.Code)
+	}
+	if apiErr := decodeAPIError(t, rec); apiErr.Code != "PUBLISH_FAILED" || !strings.HasSuffix(apiErr.Message, "edits were saved") {
+		t.Errorf("failed publish: %+v", apiErr)
+	}
+}
+
 func TestPostsHandler_Publish_NotFound(t *testing.T) {
 	h, repo, _ := testHandler(t)
 	repo.publish = func(context.Context, string) (*posts.Post, error) { return nil, posts.ErrNotFound }
@@ -419,6 +1721,56 @@ func TestPostsHandler_Publish_NotFound(t *testing.T) {
 	}
 }
 
+type failingPublisher struct{}
+
+func (failingPublisher) PublishPostPublished(context.Context, events.PostPublished) error {
+	return errors.New("broker down")
+}
+
+func TestPostsHandler_Publish_EventUndelivered(t *testing.T) {
+	repo := &testMockRepo{
+		getBySlug: func(context.Context, string) (*posts.Post, error) {
+			return &posts.Post{ID: uuid.New(), Slug: "p", Status: posts.Draft}, nil
+		},
+		publish: func(context.Context, string) (*posts.Post, error) {
+			return &posts.Post{ID: uuid.New(), Slug: "p", Status: posts.Published}, nil
+		},
+	}
+	for _, required := range []bool{false, true} {
+		svc := posts.NewService(repo, &testMockStorage{}, failingPublisher{}, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r", RequireEvent: required})
+		h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{})
+
+		req := httptest.NewRequest(http.MethodPatch, "/posts/p/publish", nil)
+		rec := httptest.NewRecorder()
+		testMux(h).ServeHTTP(rec, req)
+		if !required {
+			if rec.Code != http.StatusOK {
+				t.Errorf("lenient: status %d, want 200", rec.Code)
+			}
+			continue
+		}
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("strict: status %d, want 503", rec.Code)
+		}
+		if apiErr := decodeAPIError(t, rec); apiErr.Code != "EVENT_UNDELIVERED" {
+			t.Errorf("strict: code %q", apiErr.Code)
+		}
+	}
+
+	repo.revert = func(context.Context, uuid.UUID, uuid.UUID) error { return errors.New("db down") }
+	svc := posts.NewService(repo, &testMockStorage{}, failingPublisher{}, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r", RequireEvent: true})
+	h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{})
+	req := httptest.NewRequest(http.MethodPatch, "/posts/p/publish", nil)
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("revert failed: status %d, want 500", rec.Code)
+	}
+	if apiErr := decodeAPIError(t, rec); apiErr.Code != "EVENT_UNDELIVERED" || !strings.Contains(apiErr.Message, "published") {
+		t.Errorf("revert failed: %+v", apiErr)
+	}
+}
+
 func TestPostsHandler_Delete_NotFound(t *testing.T) {
 	h, repo, _ := testHandler(t)
 	repo.getBySlug = func(context.Context, string) (*posts.Post, error) { return nil, posts.ErrNotFound }
@@ -430,3 +1782,288 @@ func TestPostsHandler_Delete_NotFound(t *testing.T) {
 		t.Errorf("expected 404, got %d", rec.Code)
 	}
 }
+
+func TestPostsHandler_Export(t *testing.T) {
+	h, repo, st := testHandler(t)
+	repo.list = func(_ context.Context, p posts.ListParams) ([]*posts.Post, error) {
+		if !slices.Equal(p.Statuses, []posts.Status{posts.Published}) {
+			t.Errorf("expected published filter, got %v", p.Statuses)
+		}
+		if p.Offset > 0 {
+			return nil, nil
+		}
+		return []*posts.Post{
+			{ID: uuid.New(), Title: "One", Slug: "one", S3Key: "posts/one.md", Status: posts.Published},
+			{ID: uuid.New(), Title: "Two", Slug: "two", S3Key: "posts/two.md", Status: posts.Published},
+		}, nil
+	}
+	st.download = func(_ context.Context, key string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader([]byte("content of " + key))), nil
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/export", nil)
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Export: status %d, body %s", rec.Code, rec.Body.Bytes())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Content-Type %q", ct)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+	if files["one.md"] != "content of posts/one.md" || files["two.md"] != "content of posts/two.md" {
+		t.Errorf("unexpected markdown entries: %v", files)
+	}
+	var manifest []posts.ExportManifestEntry
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if len(manifest) != 2 || manifest[0].Slug != "one" || manifest[1].File != "two.md" {
+		t.Errorf("manifest = %+v", manifest)
+	}
+}
+
+func TestPostsHandler_Import(t *testing.T) {
+	h, repo, st := testHandler(t)
+	created := make(map[string]string)
+	createdAt := make(map[string]*time.Time)
+	repo.create = func(_ context.Context, p posts.CreateParams) (*posts.Post, error) {
+		if p.Slug == "existing" {
+			return nil, posts.ErrSlugExists
+		}
+		created[p.Slug] = p.Title
+		createdAt[p.Slug] = p.CreatedAt
+		return &posts.Post{ID: uuid.New(), Title: p.Title, Slug: p.Slug, S3Key: p.S3Key, Status: posts.Draft}, nil
+	}
+	uploads := make(map[string]string)
+	st.upload = func(_ context.Context, key string, body io.Reader, _ string) error {
+		data, _ := io.ReadAll(body)
+		uploads[key] = string(data)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"hello-world.md": "---\ntitle: \"Hello, World\"\ncreated_at: 2024-03-04T05:06:07Z\n---\n# Hello",
+		"existing.md":    "# Already here",
+		"bad-date.md":    "---\ncreated_at: yesterday\n---\n# Bad",
+	} {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/import", &buf)
+	req.Header.Set("Content-Type", "application/zip")
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Import: status %d, body %s", rec.Code, rec.Body.Bytes())
+	}
+	var report ImportReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.Created != 1 || report.Duplicates != 1 || report.Failed != 1 {
+		t.Errorf("report = %+v", report)
+	}
+	if created["hello-world"] != "Hello, World" {
+		t.Errorf("created = %v", created)
+	}
+	if want := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC); createdAt["hello-world"] == nil || !createdAt["hello-world"].Equal(want) {
+		t.Errorf("created_at = %v, want %v", createdAt["hello-world"], want)
+	}
+	if uploads["posts/hello-world.md"] != "# Hello" {
+		t.Errorf("frontmatter should be stripped from content, got %q", uploads["posts/hello-world.md"])
+	}
+}
+
+func TestPostsHandler_Import_InvalidZip(t *testing.T) {
+	h, _, _ := testHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/import", bytes.NewBufferString("not a zip"))
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestPostsHandler_Exists(t *testing.T) {
+	h, repo, _ := testHandler(t)
+	calls := 0
+	repo.existing = func(_ context.Context, slugs []string) ([]string, error) {
+		calls++
+		if !slices.Equal(slugs, []string{"hello", "missing", "draft"}) {
+			t.Errorf("slugs %q", slugs)
+		}
+		return []string{"draft", "hello"}, nil
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/posts/exists", strings.NewReader(`{"slugs":["hello","missing","draft"]}`))
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("%d repository calls, want 1", calls)
+	}
+	var resp struct {
+		Data map[string]bool `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := map[string]bool{"hello": true, "missing": false, "draft": true}
+	if !maps.Equal(resp.Data, want) {
+		t.Errorf("got %v, want %v", resp.Data, want)
+	}
+}
+
+func TestPostsHandler_Exists_Validation(t *testing.T) {
+	tooMany, _ := json.Marshal(map[string][]string{"slugs": make([]string, maxExistsSlugs+1)})
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"invalid json", `{`, http.StatusBadRequest},
+		{"missing", `{}`, http.StatusBadRequest},
+		{"empty", `{"slugs":[]}`, http.StatusBadRequest},
+		{"too many", string(tooMany), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, _ := testHandler(t)
+			repo.existing = func(context.Context, []string) ([]string, error) {
+				t.Error("repository called for an invalid request")
+				return nil, nil
+			}
+			req := httptest.NewRequest(http.MethodPost, "/posts/exists", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, req)
+			if rec.Code != tt.code {
+				t.Errorf("status %d, want %d", rec.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_Tags(t *testing.T) {
+	seeded := map[posts.Status][]posts.TagCount{
+		posts.Published: {{Tag: "go", Count: 3}, {Tag: "web", Count: 1}},
+		posts.Draft:     {{Tag: "rust", Count: 2}},
+	}
+	tests := []struct {
+		query      string
+		wantStatus *posts.Status
+		want       []posts.TagCount
+	}{
+		{query: "", wantStatus: ptr(posts.Published), want: seeded[posts.Published]},
+		{query: "?status=draft", wantStatus: ptr(posts.Draft), want: seeded[posts.Draft]},
+		{query: "?status=all", want: []posts.TagCount{{Tag: "go", Count: 3}, {Tag: "rust", Count: 2}, {Tag: "web", Count: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			h, repo, _ := testHandler(t)
+			repo.listTags = func(_ context.Context, status *posts.Status) ([]posts.TagCount, error) {
+				if (status == nil) != (tt.wantStatus == nil) || status != nil && *status != *tt.wantStatus {
+					t.Errorf("status %v, want %v", status, tt.wantStatus)
+				}
+				if status == nil {
+					return tt.want, nil
+				}
+				return seeded[*status], nil
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/tags"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d", rec.Code)
+			}
+			var resp struct {
+				Data []posts.TagCount `json:"data"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !slices.Equal(resp.Data, tt.want) {
+				t.Errorf("got %+v, want %+v", resp.Data, tt.want)
+			}
+		})
+	}
+
+	t.Run("empty and invalid", func(t *testing.T) {
+		h, _, _ := testHandler(t)
+		rec := httptest.NewRecorder()
+		testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tags", nil))
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"data":[]}` {
+			t.Errorf("empty: status %d, body %s", rec.Code, rec.Body.String())
+		}
+		rec = httptest.NewRecorder()
+		testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tags?status=archived", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("invalid: status %d", rec.Code)
+		}
+	})
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestPostsHandler_InternalErrorCorrelatesRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	repo := &testMockRepo{getBySlug: func(context.Context, string) (*posts.Post, error) {
+		return nil, errors.New("db exploded")
+	}}
+	svc := posts.NewService(repo, &testMockStorage{}, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+	h := NewPostsHandler(svc, slog.New(slog.NewJSONHandler(&logs, nil)), PostsHandlerConfig{})
+
+	rec := httptest.NewRecorder()
+	middleware.RequestID(testMux(h)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/a", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d", rec.Code)
+	}
+	var resp struct {
+		Error APIError `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Error.Code != "INTERNAL_ERROR" || resp.Error.RequestID == "" {
+		t.Fatalf("got %+v", resp.Error)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log %q: %v", logs.String(), err)
+	}
+	if entry["request_id"] != resp.Error.RequestID {
+		t.Errorf("log request_id %v, response %q", entry["request_id"], resp.Error.RequestID)
+	}
+	if entry["error"] != "db exploded" || entry["level"] != "ERROR" {
+		t.Errorf("log entry %v", entry)
+	}
+}
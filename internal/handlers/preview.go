@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/jeremyjsx/entries/internal/middleware"
+	"github.com/jeremyjsx/entries/internal/posts"
+)
+
+// PreviewPage is the data a preview template is executed with.
+type PreviewPage struct {
+	Post  *posts.Post
+	Title string
+	// Description is a plain-text excerpt of the content, or empty.
+	Description string
+	// URL is the page's own address, absolute when a site URL is configured.
+	URL string
+	// CanonicalURL is the post's canonical URL override, or URL.
+	CanonicalURL string
+	// CoverImage is the URL of the post's first image, or empty. Relative
+	// URLs are resolved against the site URL.
+	CoverImage string
+	// Draft is set for unpublished posts, which should not be indexed.
+	Draft bool
+	// Content is the sanitized rendering of the post's content.
+	Content template.HTML
+}
+
+// DefaultPreviewTemplate renders a minimal standalone page.
+var DefaultPreviewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+{{- with .Description}}
+<meta name="description" content="{{.}}">
+{{- end}}
+<meta property="og:type" content="article">
+<meta property="og:title" content="{{.Title}}">
+<link rel="canonical" href="{{.CanonicalURL}}">
+<meta property="og:url" content="{{.URL}}">
+{{- with .Description}}
+<meta property="og:description" content="{{.}}">
+{{- end}}
+{{- with .CoverImage}}
+<meta property="og:image" content="{{.}}">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:image" content="{{.}}">
+{{- else}}
+<meta name="twitter:card" content="summary">
+{{- end}}
+<meta name="twitter:title" content="{{.Title}}">
+{{- with .Description}}
+<meta name="twitter:description" content="{{.}}">
+{{- end}}
+{{- if .Draft}}
+<meta name="robots" content="noindex">
+{{- end}}
+</head>
+<body>
+<article>
+{{.Content}}</article>
+</body>
+</html>
+`))
+
+// Preview serves a post as a standalone HTML page. Drafts need a key with the
+// read scope whenever keys are configured; without one they are reported
+// missing.
+func (h *PostsHandler) Preview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		rendered, err := h.svc.RenderPost(r.Context(), slug)
+		if err != nil {
+			if errors.Is(err, posts.ErrNotFound) {
+				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post or content not found", nil)
+				return
+			}
+			h.internalError(w, r, "render post failed", err, "slug", slug)
+			return
+		}
+
+		draft := rendered.Post.Status != posts.Published
+		if draft {
+			presented, ok := middleware.CheckScope(r, h.apiKeys, middleware.ScopeRead)
+			if presented && !ok {
+				writeError(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "missing or invalid API key", nil)
+				return
+			}
+			if !ok {
+				writeError(w, r, http.StatusNotFound, "NOT_FOUND", "post or content not found", nil)
+				return
+			}
+		}
+
+		pageURL := h.absoluteURL(h.basePath + "/posts/" + rendered.Post.Slug + "/preview")
+		canonicalURL := pageURL
+		if rendered.Post.CanonicalURL != nil {
+			canonicalURL = *rendered.Post.CanonicalURL
+		}
+		var buf bytes.Buffer
+		err = h.previewTemplate.Execute(&buf, PreviewPage{
+			Post:         rendered.Post,
+			Title:        rendered.Post.Title,
+			Description:  rendered.Excerpt,
+			URL:          pageURL,
+			CanonicalURL: canonicalURL,
+			CoverImage:   h.absoluteURL(rendered.CoverImage),
+			Draft:        draft,
+			Content:      template.HTML(rendered.HTML),
+		})
+		if err != nil {
+			h.internalError(w, r, "execute preview template failed", err, "slug", slug)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if h.previewCSP != "" {
+			w.Header().Set("Content-Security-Policy", h.previewCSP)
+		}
+		if draft {
+			w.Header().Set("Cache-Control", "private, no-store")
+		} else if h.previewMaxAge > 0 {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.previewMaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusOK)
+		if _, err := buf.WriteTo(w); err != nil {
+			h.logger.Error("write preview failed", "slug", slug, "error", err)
+		}
+	}
+}
+
+// absoluteURL resolves ref against the configured site URL. ref is returned
+// unchanged when it is empty or already absolute, or no site URL is set.
+func (h *PostsHandler) absoluteURL(ref string) string {
+	if ref == "" || h.siteURL == nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.IsAbs() {
+		return ref
+	}
+	return h.siteURL.ResolveReference(u).String()
+}
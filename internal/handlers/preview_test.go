@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremyjsx/entries/internal/middleware"
+	"github.com/jeremyjsx/entries/internal/posts"
+)
+
+func previewHandler(status posts.Status, content string, cfg PostsHandlerConfig) *PostsHandler {
+	repo := &testMockRepo{getBySlug: func(context.Context, string) (*posts.Post, error) {
+		return &posts.Post{Title: "Hello <World>", Slug: "a", S3Key: "posts/a.md", Status: status}, nil
+	}}
+	st := &testMockStorage{download: func(context.Context, string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(content)), nil
+	}}
+	svc := posts.NewService(repo, st, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+	return NewPostsHandler(svc, slog.Default(), cfg)
+}
+
+func TestPostsHandler_Preview(t *testing.T) {
+	content := "# Heading\n\n![cover](https://cdn.example.com/c.png)\n\nSome **bold** text.<script>alert(1)</script>"
+	h := previewHandler(posts.Published, content, PostsHandlerConfig{})
+
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/a/preview", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<title>Hello &lt;World&gt;</title>",
+		`<meta property="og:image" content="https://cdn.example.com/c.png">`,
+		"<h1>Heading</h1>",
+		"<strong>bold</strong>",
+		"&lt;script&gt;",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "<script>") || strings.Contains(body, "noindex") {
+		t.Errorf("unexpected markup in body:\n%s", body)
+	}
+}
+
+func TestPostsHandler_Preview_SocialTags(t *testing.T) {
+	content := "# Heading\n\n![cover](/files/posts/a/images/c.png)\n\nA [short](https://e.com) *intro* & more."
+	site, _ := url.Parse("https://blog.example.com")
+	h := previewHandler(posts.Published, content, PostsHandlerConfig{SiteURL: site, BasePath: "/api"})
+
+	rec := httptest.NewRecorder()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/posts/{slug}/preview", h.Preview())
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/posts/a/preview", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<meta property="og:title" content="Hello &lt;World&gt;">`,
+		`<meta property="og:description" content="A short intro &amp; more.">`,
+		`<meta property="og:url" content="https://blog.example.com/api/posts/a/preview">`,
+		`<meta property="og:image" content="https://blog.example.com/files/posts/a/images/c.png">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`<meta name="twitter:title" content="Hello &lt;World&gt;">`,
+		`<meta name="twitter:description" content="A short intro &amp; more.">`,
+		`<meta name="twitter:image" content="https://blog.example.com/files/posts/a/images/c.png">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %s:\n%s", want, body)
+		}
+	}
+
+	t.Run("no image", func(t *testing.T) {
+		h := previewHandler(posts.Published, "Just text.", PostsHandlerConfig{})
+		rec := httptest.NewRecorder()
+		testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/a/preview", nil))
+		body := rec.Body.String()
+		if !strings.Contains(body, `<meta name="twitter:card" content="summary">`) || strings.Contains(body, "og:image") {
+			t.Errorf("unexpected image tags:\n%s", body)
+		}
+		if !strings.Contains(body, `<meta property="og:url" content="/posts/a/preview">`) {
+			t.Errorf("og:url should stay relative without SITE_URL:\n%s", body)
+		}
+	})
+}
+
+func TestPostsHandler_Preview_CanonicalURL(t *testing.T) {
+	site, _ := url.Parse("https://blog.example.com")
+	tests := []struct {
+		name      string
+		canonical *string
+		want      string
+	}{
+		{"default", nil, `<link rel="canonical" href="https://blog.example.com/posts/a/preview">`},
+		{"override", ptr("https://original.example/a?x=1&y=2"), `<link rel="canonical" href="https://original.example/a?x=1&amp;y=2">`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &testMockRepo{getBySlug: func(context.Context, string) (*posts.Post, error) {
+				return &posts.Post{Title: "T", Slug: "a", S3Key: "posts/a.md", Status: posts.Published, CanonicalURL: tt.canonical}, nil
+			}}
+			st := &testMockStorage{download: func(context.Context, string) (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader("hi")), nil
+			}}
+			svc := posts.NewService(repo, st, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+			h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{SiteURL: site})
+
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/a/preview", nil))
+			if body := rec.Body.String(); !strings.Contains(body, tt.want) {
+				t.Errorf("missing %s in:\n%s", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_Preview_Template(t *testing.T) {
+	tmpl := template.Must(template.New("t").Parse(`<title>{{.Title}}</title>{{.Content}}`))
+	h := previewHandler(posts.Published, "hi", PostsHandlerConfig{PreviewTemplate: tmpl})
+
+	rec := httptest.NewRecorder()
+	testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/a/preview", nil))
+	if got, want := rec.Body.String(), "<title>Hello &lt;World&gt;</title><p>hi</p>\n"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestPostsHandler_Preview_CSP(t *testing.T) {
+	const csp = "default-src 'none'; img-src https:"
+	for _, tt := range []struct {
+		name string
+		csp  string
+	}{
+		{"configured", csp},
+		{"off", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := previewHandler(posts.Published, "hi", PostsHandlerConfig{PreviewCSP: tt.csp})
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/a/preview", nil))
+			if got := rec.Header().Get("Content-Security-Policy"); got != tt.csp {
+				t.Errorf("Content-Security-Policy = %q, want %q", got, tt.csp)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_Preview_CacheControl(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		status posts.Status
+		maxAge time.Duration
+		want   string
+	}{
+		{"published", posts.Published, 10 * time.Minute, "public, max-age=600"},
+		{"published without max-age", posts.Published, 0, ""},
+		{"draft", posts.Draft, 10 * time.Minute, "private, no-store"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := previewHandler(tt.status, "# Hi", PostsHandlerConfig{PreviewMaxAge: tt.maxAge})
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/a/preview", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostsHandler_Preview_Draft(t *testing.T) {
+	keys := middleware.APIKeys{"secret": {middleware.ScopeRead}}
+	tests := []struct {
+		name string
+		keys middleware.APIKeys
+		key  string
+		want int
+	}{
+		{"without key", keys, "", http.StatusNotFound},
+		{"with wrong key", keys, "nope", http.StatusUnauthorized},
+		{"with key", keys, "secret", http.StatusOK},
+		{"auth disabled", nil, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := previewHandler(posts.Draft, "# Draft", PostsHandlerConfig{APIKeys: tt.keys})
+			req := httptest.NewRequest(http.MethodGet, "/posts/a/preview", nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && !strings.Contains(rec.Body.String(), `<meta name="robots" content="noindex">`) {
+				t.Errorf("draft preview should not be indexable:\n%s", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Route prefixes the path of a "METHOD /path" ServeMux pattern with basePath,
+// so the API can be mounted under a gateway prefix such as /api/v1.
+func Route(basePath, pattern string) string {
+	method, p, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return basePath + pattern
+	}
+	return method + " " + basePath + p
+}
+
+// ByIDPattern is the ServeMux pattern for by-ID post routes. ServeMux refuses
+// /posts/id/{id} next to /posts/{slug}/content, since both match
+// /posts/id/content and neither is more specific, so the literal segment is a
+// wildcard checked by ByID instead.
+const ByIDPattern = "/posts/{by}/{id}"
+
+// ByID restricts a handler registered at ByIDPattern to /posts/id/{id},
+// answering other paths with the JSON 404.
+func ByID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("by") != "id" {
+			writeError(w, r, http.StatusNotFound, "NOT_FOUND", "resource not found", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SlugNormalizeMode says what GetBySlug and GetContent do with a requested
+// slug that differs from its lowercased, trimmed form.
+type SlugNormalizeMode string
+
+const (
+	// SlugNormalizeOff looks the slug up exactly as requested.
+	SlugNormalizeOff SlugNormalizeMode = "off"
+	// SlugNormalizeRedirect answers 301 to the normalized path.
+	SlugNormalizeRedirect SlugNormalizeMode = "redirect"
+	// SlugNormalizeLookup looks the normalized slug up in place.
+	SlugNormalizeLookup SlugNormalizeMode = "lookup"
+)
+
+// Valid reports whether m is one of the supported modes.
+func (m SlugNormalizeMode) Valid() bool {
+	switch m {
+	case SlugNormalizeOff, SlugNormalizeRedirect, SlugNormalizeLookup:
+		return true
+	}
+	return false
+}
+
+// requestSlug returns the slug to look up for r, whose path is
+// /posts/{slug} followed by suffix. It reports false when it has answered the
+// request itself with a redirect.
+func (h *PostsHandler) requestSlug(w http.ResponseWriter, r *http.Request, suffix string) (string, bool) {
+	slug := r.PathValue("slug")
+	if h.slugNormalize == "" || h.slugNormalize == SlugNormalizeOff {
+		return slug, true
+	}
+	normalized := strings.ToLower(strings.TrimSpace(slug))
+	if normalized == slug || normalized == "" {
+		return slug, true
+	}
+	if h.slugNormalize == SlugNormalizeLookup {
+		return normalized, true
+	}
+	target := url.URL{Path: h.basePath + "/posts/" + normalized + suffix, RawQuery: r.URL.RawQuery}
+	http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+	return "", false
+}
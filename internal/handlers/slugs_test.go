@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeremyjsx/entries/internal/posts"
+)
+
+func TestPostsHandler_SlugNormalize(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         SlugNormalizeMode
+		path         string
+		wantCode     int
+		wantLocation string
+	}{
+		{"off keeps uppercase", SlugNormalizeOff, "/posts/My-Post", http.StatusNotFound, ""},
+		{"lookup uppercase", SlugNormalizeLookup, "/posts/My-Post", http.StatusOK, ""},
+		{"lookup trailing whitespace", SlugNormalizeLookup, "/posts/my-post%20", http.StatusOK, ""},
+		{"redirect uppercase", SlugNormalizeRedirect, "/posts/My-Post?nav=true", http.StatusMovedPermanently, "/posts/my-post?nav=true"},
+		{"redirect trailing whitespace", SlugNormalizeRedirect, "/posts/my-post%20/content", http.StatusMovedPermanently, "/posts/my-post/content"},
+		{"redirect leaves canonical slugs", SlugNormalizeRedirect, "/posts/my-post", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &testMockRepo{}
+			repo.getBySlug = func(_ context.Context, slug string) (*posts.Post, error) {
+				if slug != "my-post" {
+					return nil, posts.ErrNotFound
+				}
+				return &posts.Post{Slug: slug, Status: posts.Published}, nil
+			}
+			svc := posts.NewService(repo, &testMockStorage{}, nil, nil, posts.ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+			h := NewPostsHandler(svc, slog.Default(), PostsHandlerConfig{SlugNormalize: tt.mode})
+
+			rec := httptest.NewRecorder()
+			testMux(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/posts"
+)
+
+// API versions of the JSON post shape. Clients pick one with
+// "Accept: application/vnd.entries.v1+json"; anything else gets the latest.
+const (
+	apiV1 = 1
+	apiV2 = 2
+
+	latestAPIVersion = apiV2
+	vendorMediaType  = "application/vnd.entries.v"
+	APIVersionHeader = "Entries-API-Version"
+)
+
+// requestAPIVersion returns the version named by a vendor media type in the
+// Accept header, or the latest version when none is named or it is unknown.
+func requestAPIVersion(r *http.Request) int {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		rest, ok := strings.CutPrefix(mediaType, vendorMediaType)
+		if !ok {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSuffix(rest, "+json"))
+		if err == nil && v >= apiV1 && v <= latestAPIVersion {
+			return v
+		}
+	}
+	return latestAPIVersion
+}
+
+// writeVersioned writes data shaped for the API version the client asked for.
+func writeVersioned(w http.ResponseWriter, r *http.Request, status int, data any) {
+	version := requestAPIVersion(r)
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set(APIVersionHeader, strconv.Itoa(version))
+	if version == apiV1 {
+		data = shapeV1(data)
+	}
+	if wantStringNumbers(r) {
+		data = shapeStringNumbers(data)
+	}
+	writeJSON(w, status, data)
+}
+
+// postV1 is the post shape from before posts carried a format, tags, a
+// content checksum and a view count.
+type postV1 struct {
+	ID        uuid.UUID    `json:"id"`
+	Title     string       `json:"title"`
+	Slug      string       `json:"slug"`
+	S3Key     string       `json:"s3_key"`
+	Status    posts.Status `json:"status"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type updateResultV1 struct {
+	postV1
+	NotModified bool `json:"not_modified,omitempty"`
+}
+
+type postNavV1 struct {
+	postV1
+	PrevSlug string `json:"prev_slug,omitempty"`
+	NextSlug string `json:"next_slug,omitempty"`
+}
+
+type listResultV1 struct {
+	Posts      []postV1 `json:"data"`
+	Total      int64    `json:"total"`
+	Page       int      `json:"page"`
+	PerPage    int      `json:"per_page"`
+	TotalPages int      `json:"total_pages"`
+}
+
+func toPostV1(p *posts.Post) postV1 {
+	return postV1{
+		ID:        p.ID,
+		Title:     p.Title,
+		Slug:      p.Slug,
+		S3Key:     p.S3Key,
+		Status:    p.Status,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+// shapeV1 converts the post responses of the latest version to their v1
+// shape. Other values are returned unchanged.
+func shapeV1(data any) any {
+	switch v := data.(type) {
+	case *posts.Post:
+		return toPostV1(v)
+	case *posts.UpdateResult:
+		return updateResultV1{postV1: toPostV1(v.Post), NotModified: v.NotModified}
+	case *posts.PostNav:
+		return postNavV1{postV1: toPostV1(v.Post), PrevSlug: v.PrevSlug, NextSlug: v.NextSlug}
+	case *posts.ListResult:
+		out := listResultV1{
+			Posts:      make([]postV1, len(v.Posts)),
+			Total:      v.Total,
+			Page:       v.Page,
+			PerPage:    v.PerPage,
+			TotalPages: v.TotalPages,
+		}
+		for i, p := range v.Posts {
+			out.Posts[i] = toPostV1(p)
+		}
+		return out
+	}
+	return data
+}
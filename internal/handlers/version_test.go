@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/posts"
+)
+
+func TestAPIVersion_PostShape(t *testing.T) {
+	h, repo, _ := testHandler(t)
+	post := &posts.Post{ID: uuid.New(), Title: "T", Slug: "a", Status: posts.Published, Format: posts.FormatMarkdown, Tags: []string{"go"}, Views: 3}
+	repo.getBySlug = func(context.Context, string) (*posts.Post, error) { return post, nil }
+	repo.list = func(context.Context, posts.ListParams) ([]*posts.Post, error) { return []*posts.Post{post}, nil }
+	repo.count = func(context.Context, []posts.Status) (int64, error) { return 1, nil }
+
+	newer := []string{"format", "tags", "views"}
+	tests := []struct {
+		name        string
+		accept      string
+		wantVersion string
+		wantNewer   bool
+	}{
+		{"default is latest", "", "2", true},
+		{"plain json is latest", "application/json", "2", true},
+		{"v1", "application/vnd.entries.v1+json", "1", false},
+		{"v2", "application/vnd.entries.v2+json", "2", true},
+		{"unknown version is latest", "application/vnd.entries.v9+json", "2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{"/posts/a", "/posts"} {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				if tt.accept != "" {
+					req.Header.Set("Accept", tt.accept)
+				}
+				rec := httptest.NewRecorder()
+				testMux(h).ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("%s: status %d", path, rec.Code)
+				}
+				if v := rec.Header().Get(APIVersionHeader); v != tt.wantVersion {
+					t.Errorf("%s: version header %q, want %q", path, v, tt.wantVersion)
+				}
+
+				var got map[string]any
+				if path == "/posts" {
+					var list struct {
+						Data []map[string]any `json:"data"`
+					}
+					if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || len(list.Data) != 1 {
+						t.Fatalf("decode list: %v, %d items", err, len(list.Data))
+					}
+					got = list.Data[0]
+				} else if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if got["slug"] != "a" {
+					t.Errorf("%s: slug %v", path, got["slug"])
+				}
+				for _, field := range newer {
+					if _, ok := got[field]; ok != tt.wantNewer {
+						t.Errorf("%s: field %q present = %v, want %v", path, field, ok, tt.wantNewer)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// ScopesKey is the context key under which RequireScope stores the scopes of
+// the matched API key.
+const ScopesKey ctxKey = "scopes"
+
+// Scope is a permission granted to an API key.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	// ScopeAdmin grants every other scope.
+	ScopeAdmin Scope = "admin"
+)
+
+// AllScopes is granted to the legacy single API_KEY.
+var AllScopes = []Scope{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// APIKeys maps each accepted key to the scopes it grants. An empty set
+// disables authentication.
+type APIKeys map[string][]Scope
+
+// ParseAPIKeys parses comma-separated "key:scope|scope" entries, for example
+// "k1:read,k2:read|write". A key without scopes is read-only.
+func ParseAPIKeys(s string) (APIKeys, error) {
+	keys := make(APIKeys)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, list, _ := strings.Cut(entry, ":")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("api key entry %q has no key", entry)
+		}
+		scopes := []Scope{ScopeRead}
+		if strings.TrimSpace(list) != "" {
+			scopes = scopes[:0]
+			for _, name := range strings.Split(list, "|") {
+				scope := Scope(strings.ToLower(strings.TrimSpace(name)))
+				switch scope {
+				case ScopeRead, ScopeWrite, ScopeAdmin:
+					scopes = append(scopes, scope)
+				default:
+					return nil, fmt.Errorf("api key entry %q: unknown scope %q", entry, name)
+				}
+			}
+		}
+		keys[key] = scopes
+	}
+	return keys, nil
+}
+
+// lookup returns the scopes of got. Every configured key is compared so the
+// time taken does not reveal which one matched.
+func (k APIKeys) lookup(got string) ([]Scope, bool) {
+	var match []Scope
+	found := false
+	for key, scopes := range k {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(key)) == 1 {
+			match, found = scopes, true
+		}
+	}
+	return match, found
+}
+
+// HasScope reports whether scopes grant want. Admin grants everything.
+func HasScope(scopes []Scope, want Scope) bool {
+	for _, s := range scopes {
+		if s == want || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope rejects requests without a known key (401) or whose key lacks
+// scope (403). The key may be sent via X-API-Key or "Authorization: Bearer".
+// An empty key set rejects every request.
+func RequireScope(keys APIKeys, scope Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, ok := keys.lookup(requestAPIKey(r))
+			if !ok {
+				writeError(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "missing or invalid API key")
+				return
+			}
+			if !HasScope(scopes, scope) {
+				writeError(w, r, http.StatusForbidden, "FORBIDDEN", "API key lacks the "+string(scope)+" scope")
+				return
+			}
+			ctx := context.WithValue(r.Context(), ScopesKey, scopes)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// IdentifyKey stores the scopes of a valid API key in the request context, as
+// RequireScope does, but never rejects: requests with a missing or unknown key
+// pass through anonymously. Handlers use Authenticated to tell them apart.
+func IdentifyKey(keys APIKeys) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(keys) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if scopes, ok := keys.lookup(requestAPIKey(r)); ok {
+				r = r.WithContext(context.WithValue(r.Context(), ScopesKey, scopes))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Authenticated reports whether RequireScope or IdentifyKey matched a valid
+// API key for the request.
+func Authenticated(ctx context.Context) bool {
+	_, ok := ctx.Value(ScopesKey).([]Scope)
+	return ok
+}
+
+// CheckScope reports whether r presents an API key at all and whether that key
+// is known and grants scope. An empty key set grants every request.
+func CheckScope(r *http.Request, keys APIKeys, scope Scope) (presented, ok bool) {
+	got := requestAPIKey(r)
+	if len(keys) == 0 {
+		return got != "", true
+	}
+	scopes, found := keys.lookup(got)
+	return got != "", found && HasScope(scopes, scope)
+}
+
+// GetScopes returns the scopes stored by RequireScope, or nil.
+func GetScopes(ctx context.Context) []Scope {
+	scopes, _ := ctx.Value(ScopesKey).([]Scope)
+	return scopes
+}
+
+func requestAPIKey(r *http.Request) string {
+	if k := r.Header.Get(APIKeyHeader); k != "" {
+		return k
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys(" reader , editor:read|write, ops:ADMIN ")
+	if err != nil {
+		t.Fatalf("ParseAPIKeys: %v", err)
+	}
+	want := APIKeys{
+		"reader": {ScopeRead},
+		"editor": {ScopeRead, ScopeWrite},
+		"ops":    {ScopeAdmin},
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("got %v, want %v", keys, want)
+	}
+	for k, scopes := range want {
+		if !slices.Equal(keys[k], scopes) {
+			t.Errorf("%s: scopes %v, want %v", k, keys[k], scopes)
+		}
+	}
+
+	for _, bad := range []string{"k:owner", ":read"} {
+		if _, err := ParseAPIKeys(bad); err == nil {
+			t.Errorf("ParseAPIKeys(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	keys := APIKeys{
+		"reader": {ScopeRead},
+		"editor": {ScopeRead, ScopeWrite},
+		"ops":    {ScopeAdmin},
+	}
+	var gotScopes []Scope
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotScopes = GetScopes(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux := http.NewServeMux()
+	mux.Handle("GET /posts", RequireScope(keys, ScopeRead)(next))
+	mux.Handle("POST /posts", RequireScope(keys, ScopeWrite)(next))
+	mux.Handle("DELETE /posts/{slug}", RequireScope(keys, ScopeWrite)(next))
+	mux.Handle("POST /import", RequireScope(keys, ScopeAdmin)(next))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		want   int
+	}{
+		{"read key on read", http.MethodGet, "/posts", "reader", http.StatusNoContent},
+		{"read key on create", http.MethodPost, "/posts", "reader", http.StatusForbidden},
+		{"read key on delete", http.MethodDelete, "/posts/a", "reader", http.StatusForbidden},
+		{"write key on create", http.MethodPost, "/posts", "editor", http.StatusNoContent},
+		{"write key on admin", http.MethodPost, "/import", "editor", http.StatusForbidden},
+		{"admin key on write", http.MethodPost, "/posts", "ops", http.StatusNoContent},
+		{"unknown key", http.MethodGet, "/posts", "nope", http.StatusUnauthorized},
+		{"no key", http.MethodPost, "/posts", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotScopes = nil
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusNoContent && !slices.Equal(gotScopes, keys[tt.key]) {
+				t.Errorf("scopes in context %v, want %v", gotScopes, keys[tt.key])
+			}
+		})
+	}
+}
+
+func TestRequireScope_NoKeysConfigured(t *testing.T) {
+	h := RequireScope(nil, ScopeWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/posts", nil)
+	req.Header.Set(APIKeyHeader, "anything")
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want 401 with no keys configured", rec.Code)
+	}
+}
+
+func TestIdentifyKey(t *testing.T) {
+	keys := APIKeys{"reader": {ScopeRead}}
+	var authenticated bool
+	h := IdentifyKey(keys)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated = Authenticated(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, tt := range []struct {
+		key  string
+		want bool
+	}{
+		{"", false},
+		{"wrong", false},
+		{"reader", true},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+		if tt.key != "" {
+			req.Header.Set(APIKeyHeader, tt.key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("key %q: status %d", tt.key, rec.Code)
+		}
+		if authenticated != tt.want {
+			t.Errorf("key %q: authenticated %v, want %v", tt.key, authenticated, tt.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type apiError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// writeError mirrors the handlers package error envelope for responses that
+// are produced before a request reaches a handler.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": apiError{
+			Code:      code,
+			Message:   message,
+			RequestID: GetRequestID(r.Context()),
+		},
+	})
+}
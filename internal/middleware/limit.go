@@ -0,0 +1,29 @@
+package middleware
+
+import "net/http"
+
+// MaxInFlight rejects requests with 503 and Retry-After while n requests are
+// already being served. healthPath is never shed so load balancers keep seeing
+// the instance. n <= 0 disables the limit.
+func MaxInFlight(n int, healthPath string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if n <= 0 {
+			return next
+		}
+		sem := make(chan struct{}, n)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == healthPath {
+				next.ServeHTTP(w, r)
+				return
+			}
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "1")
+				writeError(w, r, http.StatusServiceUnavailable, "OVERLOADED", "server is busy, retry shortly")
+			}
+		})
+	}
+}
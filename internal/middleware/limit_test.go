@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestMaxInFlight(t *testing.T) {
+	const limit = 2
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(limit)
+	h := MaxInFlight(limit, "/health")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started.Done()
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var done sync.WaitGroup
+	for range limit {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("in-flight request: status %d", rec.Code)
+			}
+		}()
+	}
+	started.Wait()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("saturated: status %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("missing Retry-After")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("health while saturated: status %d", rec.Code)
+	}
+
+	close(release)
+	done.Wait()
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("after release: status %d", rec.Code)
+	}
+}
@@ -1,11 +1,15 @@
 package middleware
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"time"
 )
 
+// routeKey holds a *string that SetRoute fills in with the matched pattern.
+const routeKey ctxKey = "route"
+
 type responseWriter struct {
 	http.ResponseWriter
 	status int
@@ -16,16 +20,27 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the connection underneath.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Logging logs one line per request. Besides the concrete path it logs the
+// route, the ServeMux pattern that matched (such as "GET /posts/{slug}"), so
+// requests can be grouped without one entry per slug; requests no pattern
+// matched have an empty route.
 func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+			route := new(string)
 
-			next.ServeHTTP(rw, r)
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), routeKey, route)))
 
 			logger.Info("request",
 				"method", r.Method,
+				"route", *route,
 				"path", r.URL.Path,
 				"status", rw.status,
 				"duration_ms", time.Since(start).Milliseconds(),
@@ -34,3 +49,11 @@ func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// SetRoute records the pattern that matched r for Logging. It does nothing
+// outside Logging.
+func SetRoute(r *http.Request, pattern string) {
+	if route, ok := r.Context().Value(routeKey).(*string); ok {
+		*route = pattern
+	}
+}
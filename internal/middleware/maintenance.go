@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Maintenance answers mutating requests with 503 MAINTENANCE and Retry-After
+// while on is set, so writes can be paused during a deploy without taking
+// reads down. GET, HEAD and OPTIONS pass through, as does healthPath. on is
+// read per request and may be flipped at runtime.
+func Maintenance(on *atomic.Bool, retryAfter time.Duration, healthPath string) func(http.Handler) http.Handler {
+	retry := strconv.Itoa(max(int(retryAfter.Seconds()), 1))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !on.Load() || r.URL.Path == healthPath {
+				next.ServeHTTP(w, r)
+				return
+			}
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Retry-After", retry)
+			writeError(w, r, http.StatusServiceUnavailable, "MAINTENANCE", "down for maintenance, retry shortly")
+		})
+	}
+}
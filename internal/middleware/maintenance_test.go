@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	var on atomic.Bool
+	h := Maintenance(&on, 30*time.Second, "/health")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/posts", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("POST while off: status %d", rec.Code)
+	}
+
+	on.Store(true)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/posts", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST during maintenance: status %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+	if !strings.Contains(rec.Body.String(), `"code":"MAINTENANCE"`) {
+		t.Errorf("body = %s, want MAINTENANCE error", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET during maintenance: status %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("health during maintenance: status %d", rec.Code)
+	}
+}
@@ -16,7 +16,7 @@ func Recovery(logger *slog.Logger) func(http.Handler) http.Handler {
 						"stack", string(debug.Stack()),
 						"request_id", GetRequestID(r.Context()),
 					)
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
 				}
 			}()
 			next.ServeHTTP(w, r)
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	tests := []struct {
+		name string
+		cfg  SecurityHeadersConfig
+		want map[string]string
+	}{
+		{"all set", SecurityHeadersConfig{Server: "entries", NoSniff: true, FrameOptions: "DENY"}, map[string]string{
+			"Server":                 "entries",
+			"X-Content-Type-Options": "nosniff",
+			"X-Frame-Options":        "DENY",
+		}},
+		{"all off", SecurityHeadersConfig{}, map[string]string{
+			"Server":                 "",
+			"X-Content-Type-Options": "",
+			"X-Frame-Options":        "",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			SecurityHeaders(tt.cfg)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts", nil))
+			for name, want := range tt.want {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
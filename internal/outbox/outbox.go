@@ -0,0 +1,40 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is an event recorded in the outbox table, written in the same
+// transaction as the state change it describes.
+type Message struct {
+	ID        uuid.UUID
+	Type      string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// Store reads and acknowledges outbox messages. Messages are inserted by the
+// repositories that own the surrounding transaction.
+type Store interface {
+	ListUnsent(ctx context.Context, createdBefore time.Time, limit int) ([]Message, error)
+	MarkSent(ctx context.Context, id uuid.UUID) error
+	// MarkDead takes a message that can never be published out of the
+	// pending set, recording why.
+	MarkDead(ctx context.Context, id uuid.UUID, reason string) error
+	// DeleteSent removes messages sent before the given time and returns how
+	// many were deleted. Dead messages are kept.
+	DeleteSent(ctx context.Context, sentBefore time.Time) (int64, error)
+}
+
+// NewMessage wraps an event envelope for insertion into the outbox.
+func NewMessage(eventType string, event any) (Message, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{ID: uuid.New(), Type: eventType, Payload: payload}, nil
+}
@@ -0,0 +1,55 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/db"
+)
+
+var _ Store = (*postgresStore)(nil)
+
+type postgresStore struct {
+	queries *db.Queries
+}
+
+func NewPostgresStore(sqlDB *sql.DB) Store {
+	return &postgresStore{queries: db.New(sqlDB)}
+}
+
+func (s *postgresStore) ListUnsent(ctx context.Context, createdBefore time.Time, limit int) ([]Message, error) {
+	rows, err := s.queries.ListUnsentOutboxEvents(ctx, db.ListUnsentOutboxEventsParams{
+		CreatedBefore: createdBefore,
+		BatchSize:     int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]Message, len(rows))
+	for i, row := range rows {
+		msgs[i] = Message{
+			ID:        row.ID,
+			Type:      row.EventType,
+			Payload:   row.Payload,
+			CreatedAt: row.CreatedAt,
+		}
+	}
+	return msgs, nil
+}
+
+func (s *postgresStore) MarkSent(ctx context.Context, id uuid.UUID) error {
+	return s.queries.MarkOutboxEventSent(ctx, id)
+}
+
+func (s *postgresStore) MarkDead(ctx context.Context, id uuid.UUID, reason string) error {
+	return s.queries.MarkOutboxEventDead(ctx, db.MarkOutboxEventDeadParams{
+		ID:        id,
+		LastError: sql.NullString{String: reason, Valid: true},
+	})
+}
+
+func (s *postgresStore) DeleteSent(ctx context.Context, sentBefore time.Time) (int64, error) {
+	return s.queries.DeleteSentOutboxEvents(ctx, sentBefore)
+}
@@ -0,0 +1,166 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jeremyjsx/entries/internal/events"
+)
+
+const (
+	defaultRelayInterval  = 10 * time.Second
+	defaultRelayGrace     = time.Minute
+	defaultRelayBatchSize = 100
+	defaultRelayRetention = 7 * 24 * time.Hour
+	relayPruneInterval    = time.Hour
+)
+
+// errUndeliverable marks messages that no retry can publish.
+var errUndeliverable = errors.New("undeliverable")
+
+type RelayConfig struct {
+	// Interval between polls. Defaults to 10s.
+	Interval time.Duration
+	// Grace is how old a message must be before the relay picks it up, leaving
+	// room for the request that wrote it to publish and mark it first.
+	// Defaults to 1m.
+	Grace time.Duration
+	// BatchSize caps messages per poll. Defaults to 100.
+	BatchSize int
+	// Retention is how long sent messages are kept. Defaults to 7 days.
+	Retention time.Duration
+	// Now is the relay's clock. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Relay publishes outbox messages that were committed but never marked sent,
+// giving at-least-once delivery across crashes.
+type Relay struct {
+	store     Store
+	publisher events.Publisher
+	logger    *slog.Logger
+	interval  time.Duration
+	grace     time.Duration
+	batchSize int
+	retention time.Duration
+	now       func() time.Time
+}
+
+func NewRelay(store Store, publisher events.Publisher, logger *slog.Logger, cfg RelayConfig) *Relay {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	r := &Relay{
+		store:     store,
+		publisher: publisher,
+		logger:    logger,
+		interval:  cfg.Interval,
+		grace:     cfg.Grace,
+		batchSize: cfg.BatchSize,
+		retention: cfg.Retention,
+		now:       cfg.Now,
+	}
+	if r.interval <= 0 {
+		r.interval = defaultRelayInterval
+	}
+	if r.grace <= 0 {
+		r.grace = defaultRelayGrace
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = defaultRelayBatchSize
+	}
+	if r.retention <= 0 {
+		r.retention = defaultRelayRetention
+	}
+	if r.now == nil {
+		r.now = time.Now
+	}
+	return r
+}
+
+// RelayOnce publishes one batch of pending messages and returns how many were
+// sent. A message that fails to publish stays pending for the next poll,
+// unless it cannot be decoded or has an unknown type: it is marked dead so it
+// does not hold up later messages.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	msgs, err := r.store.ListUnsent(ctx, r.now().Add(-r.grace), r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("list outbox: %w", err)
+	}
+	sent := 0
+	for _, msg := range msgs {
+		if err := r.publish(ctx, msg); err != nil {
+			if errors.Is(err, errUndeliverable) {
+				r.logger.Error("outbox message undeliverable; marking dead", "id", msg.ID, "type", msg.Type, "error", err)
+				if err := r.store.MarkDead(ctx, msg.ID, err.Error()); err != nil {
+					return sent, fmt.Errorf("mark outbox dead: %w", err)
+				}
+				continue
+			}
+			r.logger.Warn("outbox publish failed", "id", msg.ID, "type", msg.Type, "error", err)
+			continue
+		}
+		if err := r.store.MarkSent(ctx, msg.ID); err != nil {
+			return sent, fmt.Errorf("mark outbox sent: %w", err)
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func (r *Relay) publish(ctx context.Context, msg Message) error {
+	switch msg.Type {
+	case events.TypePostPublished:
+		var e events.PostPublished
+		if err := json.Unmarshal(msg.Payload, &e); err != nil {
+			return fmt.Errorf("%w: decode payload: %v", errUndeliverable, err)
+		}
+		return r.publisher.PublishPostPublished(ctx, e)
+	default:
+		return fmt.Errorf("%w: unknown event type %q", errUndeliverable, msg.Type)
+	}
+}
+
+// Prune deletes messages sent longer ago than the retention window and
+// returns how many were removed.
+func (r *Relay) Prune(ctx context.Context) (int64, error) {
+	n, err := r.store.DeleteSent(ctx, r.now().Add(-r.retention))
+	if err != nil {
+		return 0, fmt.Errorf("prune outbox: %w", err)
+	}
+	return n, nil
+}
+
+// Run polls until ctx is done, pruning sent messages every hour.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	prune := time.NewTicker(relayPruneInterval)
+	defer prune.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n, err := r.RelayOnce(ctx)
+			if err != nil {
+				r.logger.Warn("outbox relay failed", "error", err)
+			}
+			if n > 0 {
+				r.logger.Info("outbox relayed events", "count", n)
+			}
+		case <-prune.C:
+			n, err := r.Prune(ctx)
+			if err != nil {
+				r.logger.Warn("outbox prune failed", "error", err)
+			}
+			if n > 0 {
+				r.logger.Info("outbox pruned sent events", "count", n)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
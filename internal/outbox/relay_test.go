@@ -0,0 +1,176 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/events"
+)
+
+type memStore struct {
+	msgs   []Message
+	sent   map[uuid.UUID]bool
+	dead   map[uuid.UUID]string
+	sentAt map[uuid.UUID]time.Time
+}
+
+func (s *memStore) ListUnsent(_ context.Context, createdBefore time.Time, limit int) ([]Message, error) {
+	var out []Message
+	for _, m := range s.msgs {
+		_, dead := s.dead[m.ID]
+		if !s.sent[m.ID] && !dead && !m.CreatedAt.After(createdBefore) && len(out) < limit {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) MarkSent(_ context.Context, id uuid.UUID) error {
+	s.sent[id] = true
+	return nil
+}
+
+func (s *memStore) MarkDead(_ context.Context, id uuid.UUID, reason string) error {
+	if s.dead == nil {
+		s.dead = make(map[uuid.UUID]string)
+	}
+	s.dead[id] = reason
+	return nil
+}
+
+func (s *memStore) DeleteSent(_ context.Context, sentBefore time.Time) (int64, error) {
+	var n int64
+	for id, at := range s.sentAt {
+		if _, dead := s.dead[id]; !dead && at.Before(sentBefore) {
+			delete(s.sentAt, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+type funcPublisher func(ctx context.Context, e events.PostPublished) error
+
+func (f funcPublisher) PublishPostPublished(ctx context.Context, e events.PostPublished) error {
+	return f(ctx, e)
+}
+
+func newTestMessage(t *testing.T, slug string, createdAt time.Time) Message {
+	t.Helper()
+	msg, err := NewMessage(events.TypePostPublished, events.NewPostPublished(events.PostPublishedPayload{PostID: uuid.New(), Slug: slug, Title: slug}, createdAt))
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	msg.CreatedAt = createdAt
+	return msg
+}
+
+func TestRelay_RelayOnce(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	old := newTestMessage(t, "old", now.Add(-5*time.Minute))
+	fresh := newTestMessage(t, "fresh", now.Add(-time.Second))
+	store := &memStore{msgs: []Message{old, fresh}, sent: map[uuid.UUID]bool{}}
+
+	var published []string
+	pub := funcPublisher(func(_ context.Context, e events.PostPublished) error {
+		published = append(published, e.Payload.Slug)
+		return nil
+	})
+	relay := NewRelay(store, pub, nil, RelayConfig{Grace: time.Minute, Now: func() time.Time { return now }})
+
+	n, err := relay.RelayOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RelayOnce: %v", err)
+	}
+	if n != 1 || len(published) != 1 || published[0] != "old" {
+		t.Errorf("sent %d, published %v; want only the message past the grace period", n, published)
+	}
+	if !store.sent[old.ID] || store.sent[fresh.ID] {
+		t.Errorf("sent marks = %v", store.sent)
+	}
+
+	n, err = relay.RelayOnce(context.Background())
+	if err != nil || n != 0 {
+		t.Errorf("second RelayOnce = (%d, %v), want nothing left to send", n, err)
+	}
+}
+
+func TestRelay_RelayOnce_PublishFailureStaysPending(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	msg := newTestMessage(t, "p", now.Add(-time.Hour))
+	store := &memStore{msgs: []Message{msg}, sent: map[uuid.UUID]bool{}}
+
+	fail := true
+	pub := funcPublisher(func(context.Context, events.PostPublished) error {
+		if fail {
+			return errors.New("broker down")
+		}
+		return nil
+	})
+	relay := NewRelay(store, pub, nil, RelayConfig{Now: func() time.Time { return now }})
+
+	if n, _ := relay.RelayOnce(context.Background()); n != 0 || store.sent[msg.ID] {
+		t.Fatalf("failed publish should leave message pending")
+	}
+	fail = false
+	if n, _ := relay.RelayOnce(context.Background()); n != 1 || !store.sent[msg.ID] {
+		t.Errorf("message not sent on retry")
+	}
+}
+
+func TestRelay_RelayOnce_UndeliverableMarkedDead(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	unknown := Message{ID: uuid.New(), Type: "post.archived", Payload: []byte(`{}`), CreatedAt: now.Add(-3 * time.Hour)}
+	garbled := Message{ID: uuid.New(), Type: events.TypePostPublished, Payload: []byte(`"nope"`), CreatedAt: now.Add(-2 * time.Hour)}
+	good := newTestMessage(t, "good", now.Add(-time.Hour))
+	store := &memStore{msgs: []Message{unknown, garbled, good}, sent: map[uuid.UUID]bool{}}
+
+	var published []string
+	pub := funcPublisher(func(_ context.Context, e events.PostPublished) error {
+		published = append(published, e.Payload.Slug)
+		return nil
+	})
+	relay := NewRelay(store, pub, nil, RelayConfig{BatchSize: 2, Now: func() time.Time { return now }})
+
+	if n, err := relay.RelayOnce(context.Background()); err != nil || n != 0 {
+		t.Fatalf("first RelayOnce = (%d, %v)", n, err)
+	}
+	if store.dead[unknown.ID] == "" || store.dead[garbled.ID] == "" {
+		t.Fatalf("dead = %v, want both bad messages recorded", store.dead)
+	}
+	if n, err := relay.RelayOnce(context.Background()); err != nil || n != 1 || len(published) != 1 || published[0] != "good" {
+		t.Errorf("second RelayOnce = (%d, %v), published %v; bad messages should not block", n, err, published)
+	}
+}
+
+func TestRelay_Prune(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	old, recent, dead := uuid.New(), uuid.New(), uuid.New()
+	store := &memStore{
+		sent: map[uuid.UUID]bool{},
+		dead: map[uuid.UUID]string{dead: "unknown event type"},
+		sentAt: map[uuid.UUID]time.Time{
+			old:    now.Add(-8 * 24 * time.Hour),
+			recent: now.Add(-time.Hour),
+			dead:   now.Add(-30 * 24 * time.Hour),
+		},
+	}
+	relay := NewRelay(store, funcPublisher(nil), nil, RelayConfig{Now: func() time.Time { return now }})
+
+	n, err := relay.Prune(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("Prune = (%d, %v), want 1 deleted", n, err)
+	}
+	if _, ok := store.sentAt[old]; ok {
+		t.Error("message past the retention window kept")
+	}
+	if _, ok := store.sentAt[recent]; !ok {
+		t.Error("recent message pruned")
+	}
+	if _, ok := store.sentAt[dead]; !ok {
+		t.Error("dead message pruned")
+	}
+}
@@ -0,0 +1,198 @@
+package posts
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultCacheTTL = 30 * time.Second
+
+var _ Repository = (*cachedRepository)(nil)
+
+// cachedRepository serves GetBySlug from an in-memory LRU cache and drops a
+// slug's entry whenever this process writes to that post. Writes made by other
+// processes, and view counts, are seen once the entry expires.
+type cachedRepository struct {
+	Repository
+	cache *lruCache
+}
+
+// NewCachedRepository wraps repo with a GetBySlug cache holding up to size
+// posts for ttl each (30s when zero). A size of zero or less returns repo as is.
+func NewCachedRepository(repo Repository, size int, ttl time.Duration) Repository {
+	if size <= 0 {
+		return repo
+	}
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &cachedRepository{Repository: repo, cache: newLRUCache(size, ttl, time.Now)}
+}
+
+func (r *cachedRepository) GetBySlug(ctx context.Context, slug string) (*Post, error) {
+	if post, ok := r.cache.get(slug); ok {
+		return post, nil
+	}
+	// Writes invalidate once they complete; if one does while the row is
+	// read, the generation check keeps that possibly older row out.
+	gen := r.cache.generation()
+	post, err := r.Repository.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	r.cache.add(slug, post, gen)
+	return post, nil
+}
+
+func (r *cachedRepository) Create(ctx context.Context, params CreateParams) (*Post, error) {
+	post, err := r.Repository.Create(ctx, params)
+	r.cache.remove(params.Slug)
+	return post, err
+}
+
+func (r *cachedRepository) Update(ctx context.Context, params UpdateParams) (*Post, error) {
+	post, err := r.Repository.Update(ctx, params)
+	// The old slug is only known by ID; drop both it and the new slug.
+	r.cache.removeID(params.ID)
+	r.cache.remove(params.Slug)
+	return post, err
+}
+
+func (r *cachedRepository) Delete(ctx context.Context, slug string) error {
+	err := r.Repository.Delete(ctx, slug)
+	r.cache.remove(slug)
+	return err
+}
+
+func (r *cachedRepository) Publish(ctx context.Context, slug string, newEvent NewEventFunc) (*Post, error) {
+	post, err := r.Repository.Publish(ctx, slug, newEvent)
+	r.cache.remove(slug)
+	return post, err
+}
+
+func (r *cachedRepository) RevertPublish(ctx context.Context, id, eventID uuid.UUID) error {
+	err := r.Repository.RevertPublish(ctx, id, eventID)
+	r.cache.removeID(id)
+	return err
+}
+
+// copyPost returns a copy callers can modify without touching the cache.
+func copyPost(p *Post) *Post {
+	c := *p
+	c.Tags = slices.Clone(p.Tags)
+	return &c
+}
+
+type cacheEntry struct {
+	slug    string
+	post    *Post
+	expires time.Time
+}
+
+// lruCache is a size-bounded, expiring map of slug to post, safe for
+// concurrent use.
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	order   *list.List
+	entries map[string]*list.Element
+	// byID indexes the slugs cached for each post ID.
+	byID map[uuid.UUID]map[string]struct{}
+	// gen counts invalidations; see add.
+	gen uint64
+}
+
+func newLRUCache(size int, ttl time.Duration, now func() time.Time) *lruCache {
+	return &lruCache{
+		size:    size,
+		ttl:     ttl,
+		now:     now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+		byID:    make(map[uuid.UUID]map[string]struct{}, size),
+	}
+}
+
+func (c *lruCache) get(slug string) (*Post, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[slug]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if !c.now().Before(e.expires) {
+		c.removeElement(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return copyPost(e.post), true
+}
+
+// generation returns the invalidation count, to be passed to add.
+func (c *lruCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// add caches post under slug unless an entry was invalidated since gen was
+// read, in which case post may predate that write.
+func (c *lruCache) add(slug string, post *Post, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return
+	}
+	if el, ok := c.entries[slug]; ok {
+		c.removeElement(el)
+	}
+	entry := &cacheEntry{slug: slug, post: copyPost(post), expires: c.now().Add(c.ttl)}
+	c.entries[slug] = c.order.PushFront(entry)
+	if c.byID[post.ID] == nil {
+		c.byID[post.ID] = make(map[string]struct{})
+	}
+	c.byID[post.ID][slug] = struct{}{}
+	if c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *lruCache) remove(slug string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if el, ok := c.entries[slug]; ok {
+		c.removeElement(el)
+	}
+}
+
+// removeID drops the entries for the post with id, whatever their slug.
+func (c *lruCache) removeID(id uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for slug := range c.byID[id] {
+		c.removeElement(c.entries[slug])
+	}
+}
+
+// removeElement drops el from the list and both maps. c.mu must be held.
+func (c *lruCache) removeElement(el *list.Element) {
+	e := el.Value.(*cacheEntry)
+	c.order.Remove(el)
+	delete(c.entries, e.slug)
+	if slugs := c.byID[e.post.ID]; slugs != nil {
+		delete(slugs, e.slug)
+		if len(slugs) == 0 {
+			delete(c.byID, e.post.ID)
+		}
+	}
+}
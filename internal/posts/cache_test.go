@@ -0,0 +1,179 @@
+package posts
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCachedRepository(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+
+	newRepo := func() (*mockRepo, *int) {
+		calls := 0
+		return &mockRepo{
+			getBySlug: func(_ context.Context, slug string) (*Post, error) {
+				calls++
+				if slug == "missing" {
+					return nil, ErrNotFound
+				}
+				return &Post{ID: id, Slug: slug, Title: "v" + string(rune('0'+calls))}, nil
+			},
+			update: func(_ context.Context, p UpdateParams) (*Post, error) {
+				return &Post{ID: p.ID, Slug: p.Slug, Title: p.Title}, nil
+			},
+		}, &calls
+	}
+
+	t.Run("hit", func(t *testing.T) {
+		inner, calls := newRepo()
+		repo := NewCachedRepository(inner, 10, time.Minute)
+		first, _ := repo.GetBySlug(ctx, "a")
+		second, err := repo.GetBySlug(ctx, "a")
+		if err != nil {
+			t.Fatalf("GetBySlug: %v", err)
+		}
+		if *calls != 1 || second.Title != first.Title {
+			t.Errorf("calls = %d, titles %q/%q", *calls, first.Title, second.Title)
+		}
+		second.Title = "mutated"
+		if third, _ := repo.GetBySlug(ctx, "a"); third.Title != first.Title {
+			t.Errorf("cached post was mutated: %q", third.Title)
+		}
+	})
+
+	t.Run("miss is not cached", func(t *testing.T) {
+		inner, calls := newRepo()
+		repo := NewCachedRepository(inner, 10, time.Minute)
+		for range 2 {
+			if _, err := repo.GetBySlug(ctx, "missing"); err != ErrNotFound {
+				t.Fatalf("got err %v", err)
+			}
+		}
+		if *calls != 2 {
+			t.Errorf("calls = %d, want 2", *calls)
+		}
+	})
+
+	t.Run("update invalidates old and new slug", func(t *testing.T) {
+		inner, calls := newRepo()
+		repo := NewCachedRepository(inner, 10, time.Minute)
+		_, _ = repo.GetBySlug(ctx, "old")
+		_, _ = repo.GetBySlug(ctx, "new")
+		if _, err := repo.Update(ctx, UpdateParams{ID: id, Slug: "new", Title: "T"}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		_, _ = repo.GetBySlug(ctx, "old")
+		_, _ = repo.GetBySlug(ctx, "new")
+		if *calls != 4 {
+			t.Errorf("calls = %d, want 4", *calls)
+		}
+	})
+
+	t.Run("delete and publish invalidate", func(t *testing.T) {
+		inner, calls := newRepo()
+		inner.publish = func(_ context.Context, slug string) (*Post, error) { return &Post{Slug: slug}, nil }
+		repo := NewCachedRepository(inner, 10, time.Minute)
+		_, _ = repo.GetBySlug(ctx, "a")
+		_ = repo.Delete(ctx, "a")
+		_, _ = repo.GetBySlug(ctx, "a")
+		_, _ = repo.Publish(ctx, "a", nil)
+		_, _ = repo.GetBySlug(ctx, "a")
+		if *calls != 3 {
+			t.Errorf("calls = %d, want 3", *calls)
+		}
+	})
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		inner, calls := newRepo()
+		repo := NewCachedRepository(inner, 2, time.Minute)
+		_, _ = repo.GetBySlug(ctx, "a")
+		_, _ = repo.GetBySlug(ctx, "b")
+		_, _ = repo.GetBySlug(ctx, "a")
+		_, _ = repo.GetBySlug(ctx, "c") // evicts b
+		_, _ = repo.GetBySlug(ctx, "a")
+		_, _ = repo.GetBySlug(ctx, "b")
+		if *calls != 4 {
+			t.Errorf("calls = %d, want 4", *calls)
+		}
+	})
+
+	t.Run("read racing a write is not cached", func(t *testing.T) {
+		inner, calls := newRepo()
+		var repo Repository
+		getBySlug := inner.getBySlug
+		inner.getBySlug = func(ctx context.Context, slug string) (*Post, error) {
+			post, err := getBySlug(ctx, slug)
+			if *calls == 1 {
+				// The update commits after this read but before it is cached.
+				_, _ = repo.Update(ctx, UpdateParams{ID: id, Slug: slug, Title: "T"})
+			}
+			return post, err
+		}
+		repo = NewCachedRepository(inner, 10, time.Minute)
+		_, _ = repo.GetBySlug(ctx, "a")
+		_, _ = repo.GetBySlug(ctx, "a")
+		if *calls != 2 {
+			t.Errorf("calls = %d, want 2: the row read before the update was cached", *calls)
+		}
+	})
+
+	t.Run("removeID drops every slug of the post", func(t *testing.T) {
+		c := newLRUCache(10, time.Minute, time.Now)
+		other := uuid.New()
+		c.add("old", &Post{ID: id, Slug: "old"}, c.generation())
+		c.add("new", &Post{ID: id, Slug: "new"}, c.generation())
+		c.add("b", &Post{ID: other, Slug: "b"}, c.generation())
+		c.removeID(id)
+		for slug, want := range map[string]bool{"old": false, "new": false, "b": true} {
+			if _, ok := c.get(slug); ok != want {
+				t.Errorf("%s cached = %v, want %v", slug, ok, want)
+			}
+		}
+		if len(c.byID) != 1 {
+			t.Errorf("byID has %d posts, want 1", len(c.byID))
+		}
+	})
+
+	t.Run("expires after ttl", func(t *testing.T) {
+		now := time.Now()
+		c := newLRUCache(10, time.Second, func() time.Time { return now })
+		c.add("a", &Post{Slug: "a"}, c.generation())
+		if _, ok := c.get("a"); !ok {
+			t.Fatal("expected hit")
+		}
+		now = now.Add(time.Second)
+		if _, ok := c.get("a"); ok {
+			t.Error("expected expiry")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		inner, _ := newRepo()
+		if repo := NewCachedRepository(inner, 0, time.Minute); repo != Repository(inner) {
+			t.Error("size 0 should return the repository unwrapped")
+		}
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		c := newLRUCache(4, time.Minute, time.Now)
+		var wg sync.WaitGroup
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				slug := string(rune('a' + i))
+				for range 100 {
+					c.add(slug, &Post{ID: id, Slug: slug}, c.generation())
+					c.get(slug)
+					c.removeID(id)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
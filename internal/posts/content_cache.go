@@ -0,0 +1,113 @@
+package posts
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+)
+
+// Content cache kinds, so a post's raw body and derived renderings can be
+// cached side by side.
+const (
+	contentKindRaw  = "raw"
+	contentKindHTML = "html"
+)
+
+// ContentCache holds post bodies in memory, evicting the least recently used
+// once their total size exceeds a limit. Entries are keyed by the post's
+// updated_at, so an update makes older entries unreachable; they are also
+// dropped explicitly when this process writes the post. A nil *ContentCache
+// caches nothing.
+type ContentCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List
+	entries  map[string]*list.Element
+	bySlug   map[string]map[string]struct{}
+}
+
+type contentCacheEntry struct {
+	key  string
+	slug string
+	data []byte
+}
+
+// NewContentCache returns a cache holding up to maxBytes of content, or nil
+// (caching disabled) when maxBytes is zero or less.
+func NewContentCache(maxBytes int64) *ContentCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &ContentCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		bySlug:   make(map[string]map[string]struct{}),
+	}
+}
+
+func contentCacheKey(kind string, post *Post) string {
+	return kind + ":" + post.Slug + "@" + strconv.FormatInt(post.UpdatedAt.UnixNano(), 10)
+}
+
+func (c *ContentCache) get(kind string, post *Post) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[contentCacheKey(kind, post)]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*contentCacheEntry).data, true
+}
+
+// add stores data, which must not be modified afterwards. Content larger than
+// the whole cache is not stored.
+func (c *ContentCache) add(kind string, post *Post, data []byte) {
+	if c == nil || int64(len(data)) > c.maxBytes {
+		return
+	}
+	key := contentCacheKey(kind, post)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+	entry := &contentCacheEntry{key: key, slug: post.Slug, data: data}
+	c.entries[key] = c.order.PushFront(entry)
+	if c.bySlug[post.Slug] == nil {
+		c.bySlug[post.Slug] = make(map[string]struct{})
+	}
+	c.bySlug[post.Slug][key] = struct{}{}
+	c.size += int64(len(data))
+	for c.size > c.maxBytes {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// invalidate drops every cached entry for slug.
+func (c *ContentCache) invalidate(slug string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.bySlug[slug] {
+		c.removeElement(c.entries[key])
+	}
+}
+
+func (c *ContentCache) removeElement(el *list.Element) {
+	e := el.Value.(*contentCacheEntry)
+	c.order.Remove(el)
+	delete(c.entries, e.key)
+	delete(c.bySlug[e.slug], e.key)
+	if len(c.bySlug[e.slug]) == 0 {
+		delete(c.bySlug, e.slug)
+	}
+	c.size -= int64(len(e.data))
+}
@@ -0,0 +1,120 @@
+package posts
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestService_GetPostContent_cache(t *testing.T) {
+	ctx := context.Background()
+	updatedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	stored := "# v1"
+	downloads := 0
+	repo := &mockRepo{
+		getBySlug: func(context.Context, string) (*Post, error) {
+			return &Post{Slug: "a", S3Key: "posts/a.md", UpdatedAt: updatedAt}, nil
+		},
+	}
+	st := &mockStorage{download: func(context.Context, string) (io.ReadCloser, error) {
+		downloads++
+		return io.NopCloser(strings.NewReader(stored)), nil
+	}}
+	svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r", ContentCache: NewContentCache(1 << 10)})
+
+	read := func() string {
+		t.Helper()
+		got, err := svc.GetPostContent(ctx, "a")
+		if err != nil {
+			t.Fatalf("GetPostContent: %v", err)
+		}
+		return string(got.Body)
+	}
+
+	if got := read(); got != "# v1" {
+		t.Fatalf("first read %q", got)
+	}
+	if got := read(); got != "# v1" || downloads != 1 {
+		t.Errorf("cached read %q after %d downloads, want 1", got, downloads)
+	}
+
+	// Another writer changed the content and with it updated_at.
+	stored = "# v2"
+	updatedAt = updatedAt.Add(time.Second)
+	if got := read(); got != "# v2" || downloads != 2 {
+		t.Errorf("read after update %q after %d downloads, want 2", got, downloads)
+	}
+
+	// Updates through the service drop the entry even before updated_at moves.
+	repo.update = func(_ context.Context, p UpdateParams) (*Post, error) {
+		return &Post{ID: p.ID, Slug: p.Slug}, nil
+	}
+	stored = "# v3"
+	if _, err := svc.UpdatePost(ctx, "a", UpdatePostInput{Content: &stored}); err != nil {
+		t.Fatalf("UpdatePost: %v", err)
+	}
+	if got := read(); got != "# v3" || downloads != 3 {
+		t.Errorf("read after UpdatePost %q after %d downloads, want 3", got, downloads)
+	}
+}
+
+func TestContentCache(t *testing.T) {
+	post := func(slug string) *Post { return &Post{Slug: slug, UpdatedAt: time.Unix(1, 0)} }
+
+	t.Run("evicts least recently used past max bytes", func(t *testing.T) {
+		c := NewContentCache(10)
+		c.add(contentKindRaw, post("a"), []byte("aaaa"))
+		c.add(contentKindRaw, post("b"), []byte("bbbb"))
+		c.get(contentKindRaw, post("a"))
+		c.add(contentKindRaw, post("c"), []byte("cccc")) // evicts b
+		if _, ok := c.get(contentKindRaw, post("b")); ok {
+			t.Error("b should be evicted")
+		}
+		for _, slug := range []string{"a", "c"} {
+			if _, ok := c.get(contentKindRaw, post(slug)); !ok {
+				t.Errorf("%s should be cached", slug)
+			}
+		}
+		if c.size != 8 {
+			t.Errorf("size = %d, want 8", c.size)
+		}
+	})
+
+	t.Run("skips content larger than the cache", func(t *testing.T) {
+		c := NewContentCache(3)
+		c.add(contentKindRaw, post("a"), []byte("aaaa"))
+		if _, ok := c.get(contentKindRaw, post("a")); ok || c.size != 0 {
+			t.Error("oversized content should not be cached")
+		}
+	})
+
+	t.Run("invalidate drops every version of a slug", func(t *testing.T) {
+		c := NewContentCache(100)
+		old, cur := post("a"), post("a")
+		cur.UpdatedAt = time.Unix(2, 0)
+		c.add(contentKindRaw, old, []byte("old"))
+		c.add(contentKindRaw, cur, []byte("new"))
+		c.add(contentKindRaw, post("b"), []byte("b"))
+		c.invalidate("a")
+		if _, ok := c.get(contentKindRaw, cur); ok {
+			t.Error("a should be invalidated")
+		}
+		if _, ok := c.get(contentKindRaw, post("b")); !ok {
+			t.Error("b should survive")
+		}
+		if c.size != 1 {
+			t.Errorf("size = %d, want 1", c.size)
+		}
+	})
+
+	t.Run("nil cache is a no-op", func(t *testing.T) {
+		var c *ContentCache
+		c.add(contentKindRaw, post("a"), []byte("a"))
+		c.invalidate("a")
+		if _, ok := c.get(contentKindRaw, post("a")); ok {
+			t.Error("nil cache should miss")
+		}
+	})
+}
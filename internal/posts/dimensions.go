@@ -0,0 +1,72 @@
+package posts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+const defaultMaxImageDimension = 10000
+
+// ErrImageTooLarge is returned for images whose width or height exceeds the
+// configured maximum.
+var ErrImageTooLarge = errors.New("image dimensions too large")
+
+func init() {
+	// The standard library has no WebP decoder; reading the header is enough
+	// for DecodeConfig.
+	image.RegisterFormat("webp", "RIFF????WEBP", func(io.Reader) (image.Image, error) {
+		return nil, errors.New("webp: decoding not supported")
+	}, decodeWebPConfig)
+}
+
+// checkImageDimensions reads only the image header. Images whose header
+// cannot be parsed are let through: no decoder can inflate them either.
+func checkImageDimensions(data []byte, maxDimension int) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	if cfg.Width > maxDimension || cfg.Height > maxDimension {
+		return fmt.Errorf("%w: %dx%d exceeds %d", ErrImageTooLarge, cfg.Width, cfg.Height, maxDimension)
+	}
+	return nil
+}
+
+// decodeWebPConfig reads the canvas size from the first chunk of a WebP file:
+// VP8X (extended), VP8L (lossless) or "VP8 " (lossy).
+func decodeWebPConfig(r io.Reader) (image.Config, error) {
+	var hdr [30]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return image.Config{}, err
+	}
+	var w, h int
+	switch string(hdr[12:16]) {
+	case "VP8X":
+		w = 1 + int(uint32(hdr[24])|uint32(hdr[25])<<8|uint32(hdr[26])<<16)
+		h = 1 + int(uint32(hdr[27])|uint32(hdr[28])<<8|uint32(hdr[29])<<16)
+	case "VP8L":
+		if hdr[20] != 0x2f {
+			return image.Config{}, errors.New("webp: bad VP8L signature")
+		}
+		bits := binary.LittleEndian.Uint32(hdr[21:25])
+		w = 1 + int(bits&0x3fff)
+		h = 1 + int(bits>>14&0x3fff)
+	case "VP8 ":
+		if hdr[23] != 0x9d || hdr[24] != 0x01 || hdr[25] != 0x2a {
+			return image.Config{}, errors.New("webp: bad VP8 start code")
+		}
+		w = int(binary.LittleEndian.Uint16(hdr[26:28]) & 0x3fff)
+		h = int(binary.LittleEndian.Uint16(hdr[28:30]) & 0x3fff)
+	default:
+		return image.Config{}, errors.New("webp: unknown chunk")
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: w, Height: h}, nil
+}
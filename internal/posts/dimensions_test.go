@@ -0,0 +1,88 @@
+package posts
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+)
+
+// pngHeader returns a PNG signature and IHDR chunk claiming w x h. Nothing
+// follows, so the image cannot be decoded, only its config read.
+func pngHeader(w, h uint32) []byte {
+	var ihdr bytes.Buffer
+	ihdr.WriteString("IHDR")
+	binary.Write(&ihdr, binary.BigEndian, w)
+	binary.Write(&ihdr, binary.BigEndian, h)
+	ihdr.Write([]byte{8, 6, 0, 0, 0})
+
+	var b bytes.Buffer
+	b.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&b, binary.BigEndian, uint32(ihdr.Len()-4))
+	b.Write(ihdr.Bytes())
+	binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(ihdr.Bytes()))
+	return b.Bytes()
+}
+
+// webpHeader returns a VP8X WebP header claiming w x h.
+func webpHeader(w, h int) []byte {
+	b := []byte("RIFF\x00\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x00\x00\x00\x00")
+	w, h = w-1, h-1
+	return append(b, byte(w), byte(w>>8), byte(w>>16), byte(h), byte(h>>8), byte(h>>16))
+}
+
+func TestCheckImageDimensions(t *testing.T) {
+	var small bytes.Buffer
+	png.Encode(&small, image.NewRGBA(image.Rect(0, 0, 4, 3)))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"small png", small.Bytes(), false},
+		{"huge png header", pngHeader(20000, 20000), true},
+		{"wide png header", pngHeader(20000, 1), true},
+		{"png at limit", pngHeader(10000, 10000), false},
+		{"huge webp header", webpHeader(16000, 16000), true},
+		{"small webp header", webpHeader(640, 480), false},
+		{"gif header", []byte("GIF89a\x20\x4e\x20\x4e\x00\x00\x00"), true},
+		{"unparseable", []byte("not an image"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkImageDimensions(tt.data, defaultMaxImageDimension)
+			if tt.wantErr != errors.Is(err, ErrImageTooLarge) || (!tt.wantErr && err != nil) {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestService_processMarkdownImages_maxImageDimension(t *testing.T) {
+	var uploads int
+	st := &mockStorage{upload: func(context.Context, string, io.Reader, string) error {
+		uploads++
+		return nil
+	}}
+	svc := NewService(&mockRepo{}, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r", MaxImageDimension: 100})
+
+	bomb := "![bomb](data:image/png;base64," + base64.StdEncoding.EncodeToString(pngHeader(20000, 20000)) + ")"
+	out, keys := svc.processMarkdownImages(context.Background(), "img", bomb)
+	if len(keys) != 0 || uploads != 0 || out != bomb {
+		t.Errorf("oversized image should stay inline: keys %v, uploads %d", keys, uploads)
+	}
+
+	ok := "![ok](data:image/png;base64," + base64.StdEncoding.EncodeToString(pngHeader(100, 50)) + ")"
+	out, keys = svc.processMarkdownImages(context.Background(), "img", ok)
+	if len(keys) != 1 || strings.Contains(out, "data:image") {
+		t.Errorf("image within limit should be uploaded: %q", out)
+	}
+}
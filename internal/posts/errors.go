@@ -1,8 +1,44 @@
 package posts
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
-	ErrNotFound   = errors.New("post not found")
-	ErrSlugExists = errors.New("slug already exists")
+	ErrNotFound           = errors.New("post not found")
+	ErrSlugExists         = errors.New("slug already exists")
+	ErrPreconditionFailed = errors.New("content has changed")
+	ErrInvalidFormat      = errors.New("unsupported format")
+	ErrEmbeddedImages     = errors.New("data-URL images are not allowed")
+	// ErrEventUndelivered is returned under ServiceConfig.RequireEvent when
+	// the post.published event could not be sent, so the publish was reverted.
+	ErrEventUndelivered = errors.New("post.published event could not be delivered")
+	// ErrRevertFailed is returned instead when the revert failed too; the
+	// post stays published and the outbox relay retries the event.
+	ErrRevertFailed = errors.New("post.published event undelivered and publish not reverted")
+	// ErrEditsSaved wraps a publish failure in PublishPostWithUpdate that
+	// came after its edits were stored; the post is still a draft.
+	ErrEditsSaved = errors.New("edits were saved but the post was not published")
 )
+
+// PageOutOfRangeError reports a listing page past the last one, under
+// PageOutOfRangeFail mode. LastPage is at least 1.
+type PageOutOfRangeError struct {
+	Page     int
+	LastPage int
+}
+
+func (e *PageOutOfRangeError) Error() string {
+	return fmt.Sprintf("page %d is out of range; last page is %d", e.Page, e.LastPage)
+}
+
+// HeadingsError reports markdown content rejected by ValidateHeadings.
+type HeadingsError struct {
+	Problems []string
+}
+
+func (e *HeadingsError) Error() string {
+	return "invalid headings: " + strings.Join(e.Problems, "; ")
+}
@@ -0,0 +1,100 @@
+package posts
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/storage"
+)
+
+const exportPageSize = 100
+
+// ExportManifestEntry describes one post in an export archive's manifest.json.
+type ExportManifestEntry struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	Slug      string    `json:"slug"`
+	Status    Status    `json:"status"`
+	File      string    `json:"file,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExportPosts streams a zip archive to w containing every post matching status
+// (all posts when nil) as {slug}.md plus a manifest.json of metadata. Posts are
+// paged from the repository and each object is copied straight from storage,
+// so the archive is never held in memory.
+func (s *Service) ExportPosts(ctx context.Context, w io.Writer, status *Status) error {
+	zw := zip.NewWriter(w)
+	manifest := make([]ExportManifestEntry, 0)
+	var statuses []Status
+	if status != nil {
+		statuses = []Status{*status}
+	}
+
+	for offset := 0; ; offset += exportPageSize {
+		page, err := s.repo.List(ctx, ListParams{Limit: exportPageSize, Offset: offset, Statuses: statuses})
+		if err != nil {
+			return fmt.Errorf("list posts: %w", err)
+		}
+		for _, post := range page {
+			entry := ExportManifestEntry{
+				ID:        post.ID,
+				Title:     post.Title,
+				Slug:      post.Slug,
+				Status:    post.Status,
+				CreatedAt: post.CreatedAt,
+				UpdatedAt: post.UpdatedAt,
+			}
+			file := post.Slug + ".md"
+			if err := s.exportContent(ctx, zw, file, post.S3Key); err != nil {
+				if !errors.Is(err, storage.ErrNotFound) {
+					return err
+				}
+				s.logger.Warn("export: content missing, skipping file", "slug", post.Slug)
+			} else {
+				entry.File = file
+			}
+			manifest = append(manifest, entry)
+		}
+		if len(page) < exportPageSize {
+			break
+		}
+	}
+
+	mw, err := zw.Create("manifest.json")
+	if err != nil {
+		return fmt.Errorf("create manifest: %w", err)
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return zw.Close()
+}
+
+func (s *Service) exportContent(ctx context.Context, zw *zip.Writer, name, key string) error {
+	body, err := s.storage.Download(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("download %s: %w", key, err)
+	}
+	defer body.Close()
+	fw, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+	if _, err := io.Copy(fw, body); err != nil {
+		return fmt.Errorf("copy %s: %w", name, err)
+	}
+	return nil
+}
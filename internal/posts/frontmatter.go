@@ -0,0 +1,99 @@
+package posts
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const frontmatterDelimiter = "---"
+
+// Frontmatter is the subset of a YAML frontmatter block the importer understands.
+type Frontmatter struct {
+	Title     string
+	Slug      string
+	Tags      []string
+	CreatedAt string
+}
+
+// ParseFrontmatter splits an optional leading "---" delimited block of
+// "key: value" lines from content and returns the recognised fields and the
+// remaining body. Content without a complete block is returned unchanged.
+func ParseFrontmatter(content string) (Frontmatter, string) {
+	var fm Frontmatter
+	normalized := strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(normalized, frontmatterDelimiter+"\n") {
+		return fm, content
+	}
+	rest := normalized[len(frontmatterDelimiter):]
+	end := strings.Index(rest, "\n"+frontmatterDelimiter)
+	if end < 0 {
+		return fm, content
+	}
+	block := rest[:end]
+	body := strings.TrimPrefix(rest[end+len(frontmatterDelimiter)+1:], "\n")
+
+	scanner := bufio.NewScanner(strings.NewReader(block))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "title":
+			fm.Title = unquote(value)
+		case "slug":
+			fm.Slug = unquote(value)
+		case "tags":
+			fm.Tags = parseFlowList(value)
+		case "created_at":
+			fm.CreatedAt = unquote(value)
+		}
+	}
+	return fm, body
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseFlowList reads a single-line YAML flow sequence such as "[go, web]".
+func parseFlowList(s string) []string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(s[1:len(s)-1], ",") {
+		if item = unquote(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// RenderFrontmatter returns a frontmatter block describing post, in the form
+// ParseFrontmatter reads back, followed by a newline.
+func RenderFrontmatter(post *Post) string {
+	var b strings.Builder
+	b.WriteString(frontmatterDelimiter + "\n")
+	b.WriteString("title: " + strconv.Quote(post.Title) + "\n")
+	b.WriteString("slug: " + post.Slug + "\n")
+	b.WriteString("tags: [" + strings.Join(post.Tags, ", ") + "]\n")
+	b.WriteString("status: " + string(post.Status) + "\n")
+	b.WriteString("created_at: " + post.CreatedAt.UTC().Format(time.RFC3339) + "\n")
+	b.WriteString("updated_at: " + post.UpdatedAt.UTC().Format(time.RFC3339) + "\n")
+	b.WriteString(frontmatterDelimiter + "\n")
+	return b.String()
+}
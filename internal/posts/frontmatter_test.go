@@ -0,0 +1,101 @@
+package posts
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFrontmatter(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		wantTitle string
+		wantSlug  string
+		wantTags  []string
+		wantBody  string
+	}{
+		{
+			name:      "title and slug",
+			in:        "---\ntitle: \"My Post\"\nslug: my-post\n---\n# Body",
+			wantTitle: "My Post",
+			wantSlug:  "my-post",
+			wantBody:  "# Body",
+		},
+		{
+			name:     "no frontmatter",
+			in:       "# Just markdown\n---\n",
+			wantBody: "# Just markdown\n---\n",
+		},
+		{
+			name:     "unterminated block",
+			in:       "---\ntitle: x\n# Body",
+			wantBody: "---\ntitle: x\n# Body",
+		},
+		{
+			name:      "crlf line endings",
+			in:        "---\r\ntitle: 'CRLF'\r\n---\r\nbody",
+			wantTitle: "CRLF",
+			wantBody:  "body",
+		},
+		{
+			name:      "tags and escaped title",
+			in:        "---\ntitle: \"Say \\\"hi\\\"\"\ntags: [go, 'web', \"api\"]\n---\nbody",
+			wantTitle: `Say "hi"`,
+			wantTags:  []string{"go", "web", "api"},
+			wantBody:  "body",
+		},
+		{
+			name:     "empty block",
+			in:       "---\n---\nbody",
+			wantBody: "body",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, body := ParseFrontmatter(tt.in)
+			if fm.Title != tt.wantTitle || fm.Slug != tt.wantSlug || !slices.Equal(fm.Tags, tt.wantTags) {
+				t.Errorf("frontmatter = %+v", fm)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRenderFrontmatter(t *testing.T) {
+	post := &Post{
+		Title:     `Quotes "and" colons: fine`,
+		Slug:      "my-post",
+		Tags:      []string{"go", "web"},
+		Status:    Published,
+		CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		UpdatedAt: time.Date(2025, 2, 3, 4, 5, 6, 0, time.FixedZone("X", 3600)),
+	}
+	got := RenderFrontmatter(post)
+	want := "---\n" +
+		"title: \"Quotes \\\"and\\\" colons: fine\"\n" +
+		"slug: my-post\n" +
+		"tags: [go, web]\n" +
+		"status: published\n" +
+		"created_at: 2025-01-02T03:04:05Z\n" +
+		"updated_at: 2025-02-03T03:05:06Z\n" +
+		"---\n"
+	if got != want {
+		t.Fatalf("RenderFrontmatter =\n%s\nwant\n%s", got, want)
+	}
+
+	fm, body := ParseFrontmatter(got + "# Body\n")
+	if fm.Title != post.Title || fm.Slug != post.Slug || !slices.Equal(fm.Tags, post.Tags) || fm.CreatedAt != "2025-01-02T03:04:05Z" {
+		t.Errorf("round trip frontmatter = %+v", fm)
+	}
+	if body != "# Body\n" {
+		t.Errorf("round trip body = %q", body)
+	}
+
+	if got := RenderFrontmatter(&Post{Slug: "s"}); !strings.Contains(got, "tags: []\n") {
+		t.Errorf("no tags rendered as %q", got)
+	}
+}
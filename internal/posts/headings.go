@@ -0,0 +1,72 @@
+package posts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateHeadings checks markdown against the editorial heading rules: the
+// first heading is the only level-1 heading and no level is skipped on the
+// way down. It returns one message per problem, or nil. Only ATX headings
+// ("# Title") are considered and fenced code blocks are ignored.
+func ValidateHeadings(content string) []string {
+	var (
+		problems []string
+		fence    string
+		prev     int
+		h1s      int
+	)
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		level := headingLevel(line)
+		if level == 0 {
+			continue
+		}
+		lineNo := i + 1
+		switch {
+		case level == 1:
+			h1s++
+			if h1s == 2 {
+				problems = append(problems, fmt.Sprintf("line %d: more than one level-1 heading", lineNo))
+			}
+		case prev == 0:
+			problems = append(problems, fmt.Sprintf("line %d: first heading must be level 1, got level %d", lineNo, level))
+		case level > prev+1:
+			problems = append(problems, fmt.Sprintf("line %d: heading skips from level %d to %d", lineNo, prev, level))
+		}
+		prev = level
+	}
+	if h1s == 0 && prev == 0 {
+		problems = append(problems, "missing level-1 heading")
+	}
+	return problems
+}
+
+// headingLevel returns the level of an ATX heading line, or 0. Up to three
+// leading spaces are allowed and the hashes must be followed by a space or
+// end the line.
+func headingLevel(line string) int {
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	if indent > 3 {
+		return 0
+	}
+	line = line[indent:]
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 {
+		return 0
+	}
+	if len(line) > level && line[level] != ' ' && line[level] != '\t' {
+		return 0
+	}
+	return level
+}
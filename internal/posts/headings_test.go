@@ -0,0 +1,30 @@
+package posts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateHeadings(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"valid", "# Title\n\nIntro\n\n## Part\n\n### Detail\n\n## Next", nil},
+		{"skipped level", "# Title\n\n## Part\n\n#### Deep", []string{"line 5: heading skips from level 2 to 4"}},
+		{"multiple h1", "# One\n\ntext\n\n# Two\n\n# Three", []string{"line 5: more than one level-1 heading"}},
+		{"starts below h1", "## Part\n\n# Title", []string{"line 1: first heading must be level 1, got level 2"}},
+		{"no headings", "just text", []string{"missing level-1 heading"}},
+		{"code fences ignored", "# Title\n\n```sh\n# comment\n#### not a heading\n```\n\n## Part", nil},
+		{"hashtag is not a heading", "# Title\n\n#golang is fun", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateHeadings(tt.content)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("ValidateHeadings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -13,26 +13,258 @@ const (
 	Published Status = "published"
 )
 
+// Format is the markup language a post's content is written in.
+type Format string
+
+const (
+	FormatMarkdown Format = "markdown"
+	FormatAsciiDoc Format = "asciidoc"
+	FormatRST      Format = "rst"
+)
+
+var formatContentTypes = map[Format]string{
+	FormatMarkdown: "text/markdown",
+	FormatAsciiDoc: "text/asciidoc",
+	FormatRST:      "text/x-rst",
+}
+
+// Valid reports whether f is one of the supported formats.
+func (f Format) Valid() bool {
+	_, ok := formatContentTypes[f]
+	return ok
+}
+
+// isMarkdown treats an unset format as markdown, the only format before
+// formats were recorded.
+func (f Format) isMarkdown() bool {
+	return f == "" || f == FormatMarkdown
+}
+
+// ContentType returns the MIME type content in f is stored and served as.
+// Unknown formats are treated as markdown.
+func (f Format) ContentType() string {
+	if ct, ok := formatContentTypes[f]; ok {
+		return ct
+	}
+	return formatContentTypes[FormatMarkdown]
+}
+
+// ImageEmbedMode says what happens to data-URL images in markdown content.
+type ImageEmbedMode string
+
+const (
+	// ImageEmbedUpload uploads embedded images and rewrites them to URLs.
+	ImageEmbedUpload ImageEmbedMode = "upload"
+	// ImageEmbedReject fails the write with ErrEmbeddedImages.
+	ImageEmbedReject ImageEmbedMode = "reject"
+	// ImageEmbedIgnore stores embedded images as they are.
+	ImageEmbedIgnore ImageEmbedMode = "ignore"
+)
+
+// Valid reports whether m is one of the supported modes.
+func (m ImageEmbedMode) Valid() bool {
+	switch m {
+	case ImageEmbedUpload, ImageEmbedReject, ImageEmbedIgnore:
+		return true
+	}
+	return false
+}
+
+// PageOutOfRangeMode says how ListPosts answers a page past the last one.
+type PageOutOfRangeMode string
+
+const (
+	// PageOutOfRangeEmpty returns the requested page with no posts.
+	PageOutOfRangeEmpty PageOutOfRangeMode = "empty"
+	// PageOutOfRangeClamp returns the last page instead.
+	PageOutOfRangeClamp PageOutOfRangeMode = "clamp"
+	// PageOutOfRangeFail fails with a *PageOutOfRangeError.
+	PageOutOfRangeFail PageOutOfRangeMode = "error"
+)
+
+// Valid reports whether m is one of the supported modes.
+func (m PageOutOfRangeMode) Valid() bool {
+	switch m {
+	case PageOutOfRangeEmpty, PageOutOfRangeClamp, PageOutOfRangeFail:
+		return true
+	}
+	return false
+}
+
+// ContentMissingMode says how GetPostContent answers for a post whose
+// content object is gone.
+type ContentMissingMode string
+
+const (
+	// ContentMissingFail reports ErrNotFound.
+	ContentMissingFail ContentMissingMode = "error"
+	// ContentMissingPlaceholder serves placeholder content instead.
+	ContentMissingPlaceholder ContentMissingMode = "placeholder"
+)
+
+// Valid reports whether m is one of the supported modes.
+func (m ContentMissingMode) Valid() bool {
+	return m == ContentMissingFail || m == ContentMissingPlaceholder
+}
+
+// DefaultContentPlaceholder is served for missing content in
+// ContentMissingPlaceholder mode when no other placeholder is configured.
+const DefaultContentPlaceholder = "_This content is currently unavailable._\n"
+
 type Post struct {
-	ID        uuid.UUID `json:"id"`
-	Title     string    `json:"title"`
-	Slug      string    `json:"slug"`
-	S3Key     string    `json:"s3_key"`
-	Status    Status    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uuid.UUID `json:"id"`
+	Title         string    `json:"title"`
+	Slug          string    `json:"slug"`
+	S3Key         string    `json:"s3_key"`
+	Status        Status    `json:"status"`
+	Format        Format    `json:"format"`
+	Tags          []string  `json:"tags"`
+	ContentSHA256 string    `json:"content_sha256,omitempty"`
+	// CanonicalURL points at the original of a republished post, or is nil.
+	CanonicalURL *string   `json:"canonical_url"`
+	Views        int64     `json:"views"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	// RawContentSHA256 is the checksum of the content last sent, before
+	// image processing.
+	RawContentSHA256 string `json:"-"`
+}
+
+type CreateParams struct {
+	Title         string
+	Slug          string
+	S3Key         string
+	Format        Format
+	Tags          []string
+	ContentSHA256 string
+	CanonicalURL  *string
+	// CreatedAt overrides the creation time, which defaults to now.
+	CreatedAt *time.Time
+	// RawContentSHA256 is the checksum of the content before processing.
+	RawContentSHA256 string
+}
+
+type UpdateParams struct {
+	ID            uuid.UUID
+	Title         string
+	Slug          string
+	S3Key         string
+	Tags          []string
+	ContentSHA256 string
+	CanonicalURL  *string
+	// RawContentSHA256 is the checksum of the content before processing.
+	RawContentSHA256 string
+
+	// ExpectedContentSHA256, when set, applies the update only if the stored
+	// checksum still equals it.
+	ExpectedContentSHA256 *string
+}
+
+// CreatePostInput is a new post. An empty Format means markdown.
+type CreatePostInput struct {
+	Title        string
+	Slug         string
+	Content      string
+	Format       Format
+	Tags         []string
+	CanonicalURL *string
+	// CreatedAt backdates the post, as for posts imported from elsewhere.
+	// Nil means now.
+	CreatedAt *time.Time
+}
+
+// UpdatePostInput holds the fields to change; nil fields are left as stored.
+// A non-nil empty Tags clears the post's tags, and an empty CanonicalURL
+// clears the canonical URL.
+type UpdatePostInput struct {
+	Title        *string
+	Slug         *string
+	Content      *string
+	Tags         []string
+	CanonicalURL *string
+}
+
+// UpdateResult is the updated post; NotModified reports that the request
+// matched the stored post and nothing was written.
+type UpdateResult struct {
+	*Post
+	NotModified bool `json:"not_modified,omitempty"`
+}
+
+// PostContent is a post's body as served, with the post it belongs to and the
+// ETag of the stored bytes.
+type PostContent struct {
+	Post *Post
+	Body []byte
+	ETag string
+	// Placeholder is set when Body stands in for a missing content object.
+	Placeholder bool
+}
+
+// ContentInfo is what GetPostContent would return, without the body.
+type ContentInfo struct {
+	Post *Post
+	ETag string
+	// Size is the length of the body as served, or -1 when it is only known
+	// once image URLs are signed.
+	Size        int64
+	Placeholder bool
+}
+
+// RenderedPost is a post's content rendered to HTML, with the URL of its
+// first image for use as a cover and a plain-text excerpt.
+type RenderedPost struct {
+	Post       *Post
+	HTML       string
+	CoverImage string
+	Excerpt    string
+}
+
+// PostNav is a post with the slugs of the published posts created just
+// before and after it. Either slug is empty at the ends.
+type PostNav struct {
+	*Post
+	PrevSlug string `json:"prev_slug,omitempty"`
+	NextSlug string `json:"next_slug,omitempty"`
+}
+
+// Sort selects the ordering of post listings.
+type Sort string
+
+const (
+	SortNewest Sort = "newest"
+	SortViews  Sort = "views"
+)
+
+// TagCount is a tag and the number of posts using it.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
 }
 
 type ListParams struct {
 	Limit  int
 	Offset int
-	Status *Status
+	// Statuses keeps posts with any of the statuses. Empty keeps all.
+	Statuses []Status
+	// Sort by SortViews lists published posts only, ignoring Statuses.
+	Sort Sort
 }
 
+const (
+	// DefaultPerPage is the page size used when none, or one over MaxPerPage,
+	// is requested.
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
 type ListResult struct {
 	Posts      []*Post `json:"data"`
 	Total      int64   `json:"total"`
 	Page       int     `json:"page"`
 	PerPage    int     `json:"per_page"`
 	TotalPages int     `json:"total_pages"`
+	// HasNext and HasPrev say whether a following or preceding page exists.
+	HasNext bool `json:"has_next"`
+	HasPrev bool `json:"has_prev"`
 }
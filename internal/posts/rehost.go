@@ -0,0 +1,125 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/safehttp"
+)
+
+const (
+	// maxRehostImages caps the distinct external images copied per save.
+	maxRehostImages = 20
+	// rehostTimeout bounds a whole rehosting pass, however many images it has.
+	rehostTimeout = time.Minute
+)
+
+// Matches markdown images with an absolute http(s) URL and an optional title.
+var externalImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\((https?://[^\s)]+)(\s+"[^"]*")?\)`)
+
+// rehostTypes maps the image types accepted from external hosts to the
+// extension they are stored under.
+var rehostTypes = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+	"image/webp": "webp",
+	"image/gif":  "gif",
+}
+
+// ImageFetcher downloads external images for rehosting through a
+// safehttp.Client, so only public addresses are contacted.
+type ImageFetcher struct {
+	client *safehttp.Client
+}
+
+// NewImageFetcher returns a fetcher accepting images up to maxBytes. A zero
+// timeout defaults to 10s.
+func NewImageFetcher(maxBytes int64, timeout time.Duration) *ImageFetcher {
+	return &ImageFetcher{client: safehttp.NewClient(safehttp.Config{Timeout: timeout, MaxBytes: maxBytes})}
+}
+
+// Fetch downloads url and returns the body and its media type. It fails for
+// non-200 responses, unsupported content types and bodies over the limit.
+func (f *ImageFetcher) Fetch(ctx context.Context, url string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if _, ok := rehostTypes[mediaType]; !ok {
+		return nil, "", fmt.Errorf("unsupported content type %q", mediaType)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, mediaType, nil
+}
+
+// rehostExternalImages copies external images in markdown content into
+// storage under the post's images prefix and rewrites them to the stored
+// copies. Images already hosted here and images that fail to fetch are left
+// as they are, as are those past maxRehostImages or rehostTimeout. Each URL is
+// fetched once however often it appears. It returns the rewritten content and
+// the keys uploaded.
+func (s *Service) rehostExternalImages(ctx context.Context, slug, content string) (string, []string) {
+	ctx, cancel := context.WithTimeout(ctx, rehostTimeout)
+	defer cancel()
+	var keys []string
+	// rehosted maps each URL tried to its stored copy, or "" if it failed.
+	rehosted := make(map[string]string)
+	skipped := 0
+	result := externalImageRegex.ReplaceAllStringFunc(content, func(match string) string {
+		subs := externalImageRegex.FindStringSubmatch(match)
+		alt, src, title := subs[1], subs[2], subs[3]
+		if s.imageURLRegex.MatchString(src) {
+			return match
+		}
+		if stored, ok := rehosted[src]; ok {
+			if stored == "" {
+				return match
+			}
+			return fmt.Sprintf("![%s](%s%s)", alt, stored, title)
+		}
+		if len(rehosted) >= maxRehostImages {
+			skipped++
+			return match
+		}
+		rehosted[src] = ""
+		data, contentType, err := s.imageFetcher.Fetch(ctx, src)
+		if err == nil {
+			err = checkImageDimensions(data, s.maxImageDim)
+		}
+		if err != nil {
+			s.logger.Warn("failed to rehost external image", "url", src, "error", err)
+			return match
+		}
+		key := fmt.Sprintf("posts/%s/images/%s.%s", slug, uuid.New().String(), rehostTypes[contentType])
+		if err := s.storage.Upload(ctx, key, strings.NewReader(string(data)), contentType); err != nil {
+			s.logger.Warn("failed to upload rehosted image", "url", src, "key", key, "error", err)
+			return match
+		}
+		keys = append(keys, key)
+		rehosted[src] = s.s3PublicURL(key)
+		return fmt.Sprintf("![%s](%s%s)", alt, rehosted[src], title)
+	})
+	if skipped > 0 {
+		s.logger.Warn("too many external images; left the rest in place", "slug", slug, "limit", maxRehostImages, "skipped", skipped)
+	}
+	return result, keys
+}
@@ -0,0 +1,157 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jeremyjsx/entries/internal/safehttp"
+)
+
+func imageServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cat.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png-bytes"))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>"))
+		case "/big.gif":
+			w.Header().Set("Content-Type", "image/gif")
+			w.Write([]byte(strings.Repeat("x", 64)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// loopbackFetcher is an ImageFetcher that may reach httptest servers.
+func loopbackFetcher(maxBytes int64) *ImageFetcher {
+	return &ImageFetcher{client: safehttp.NewClient(safehttp.Config{
+		MaxBytes:  maxBytes,
+		AllowAddr: func(netip.Addr) bool { return true },
+	})}
+}
+
+func TestImageFetcher_Fetch(t *testing.T) {
+	srv := imageServer(t)
+	ctx := context.Background()
+
+	t.Run("blocks private address", func(t *testing.T) {
+		_, _, err := NewImageFetcher(1024, 0).Fetch(ctx, srv.URL+"/cat.png")
+		if !errors.Is(err, safehttp.ErrBlockedAddress) {
+			t.Errorf("err = %v, want ErrBlockedAddress", err)
+		}
+	})
+
+	f := loopbackFetcher(32)
+	t.Run("image", func(t *testing.T) {
+		data, contentType, err := f.Fetch(ctx, srv.URL+"/cat.png")
+		if err != nil || string(data) != "png-bytes" || contentType != "image/png" {
+			t.Errorf("got %q %q %v", data, contentType, err)
+		}
+	})
+	for _, path := range []string{"/page.html", "/big.gif", "/missing.png"} {
+		t.Run("rejects "+path, func(t *testing.T) {
+			if _, _, err := f.Fetch(ctx, srv.URL+path); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestService_RehostExternalImages(t *testing.T) {
+	srv := imageServer(t)
+	tests := []struct {
+		name    string
+		fetcher *ImageFetcher
+		rehosts bool
+	}{
+		{"rehosted", loopbackFetcher(1024), true},
+		{"private address left alone", NewImageFetcher(1024, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockRepo{create: func(context.Context, CreateParams) (*Post, error) {
+				return &Post{Slug: "img"}, nil
+			}}
+			uploaded := make(map[string]string)
+			st := &mockStorage{upload: func(_ context.Context, key string, body io.Reader, contentType string) error {
+				data, _ := io.ReadAll(body)
+				uploaded[key] = contentType + " " + string(data)
+				return nil
+			}}
+			svc := NewService(repo, st, nil, nil, ServiceConfig{S3PublicBaseURL: "https://cdn.example.com", ImageFetcher: tt.fetcher})
+			content := "![cat](" + srv.URL + `/cat.png "A cat")`
+			if _, err := svc.CreatePost(context.Background(), CreatePostInput{Title: "T", Slug: "img", Content: content}); err != nil {
+				t.Fatalf("CreatePost: %v", err)
+			}
+
+			stored := uploaded["posts/img.md"]
+			if !tt.rehosts {
+				if len(uploaded) != 1 || !strings.Contains(stored, srv.URL) {
+					t.Errorf("uploads %v", uploaded)
+				}
+				return
+			}
+			var imageKey string
+			for key := range uploaded {
+				if strings.HasPrefix(key, "posts/img/images/") && strings.HasSuffix(key, ".png") {
+					imageKey = key
+				}
+			}
+			if imageKey == "" || uploaded[imageKey] != "image/png png-bytes" {
+				t.Fatalf("uploads %v", uploaded)
+			}
+			if want := `![cat](https://cdn.example.com/` + imageKey + ` "A cat")`; !strings.Contains(stored, want) {
+				t.Errorf("stored %q, want it to contain %q", stored, want)
+			}
+		})
+	}
+}
+
+func TestService_RehostExternalImages_Limits(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png-bytes"))
+	}))
+	t.Cleanup(srv.Close)
+
+	var images int
+	st := &mockStorage{upload: func(_ context.Context, key string, _ io.Reader, _ string) error {
+		if strings.HasPrefix(key, "posts/img/images/") {
+			images++
+		}
+		return nil
+	}}
+	svc := NewService(&mockRepo{}, st, nil, nil, ServiceConfig{S3PublicBaseURL: "https://cdn.example.com", ImageFetcher: loopbackFetcher(1024)})
+
+	var content strings.Builder
+	for i := range maxRehostImages + 5 {
+		// Every image appears twice.
+		fmt.Fprintf(&content, "![a](%s/%d.png)\n![b](%s/%d.png)\n", srv.URL, i, srv.URL, i)
+	}
+	got, keys := svc.rehostExternalImages(context.Background(), "img", content.String())
+	if int(hits.Load()) != maxRehostImages || images != maxRehostImages || len(keys) != maxRehostImages {
+		t.Errorf("fetched %d, uploaded %d, keys %d, want %d each", hits.Load(), images, len(keys), maxRehostImages)
+	}
+	if n := strings.Count(got, "https://cdn.example.com/"); n != 2*maxRehostImages {
+		t.Errorf("rewrote %d images, want %d", n, 2*maxRehostImages)
+	}
+	if n := strings.Count(got, srv.URL); n != 2*5 {
+		t.Errorf("left %d images in place, want %d", n, 2*5)
+	}
+}
@@ -0,0 +1,330 @@
+package posts
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RenderMarkdown converts markdown to HTML. It covers the subset posts use:
+// ATX headings, paragraphs, fenced code, block quotes, lists, thematic breaks,
+// and inline code, emphasis, links and images. All text is escaped and raw
+// HTML is never passed through, so the output is safe to embed in a page.
+// Links and images with a scheme other than http, https or mailto are
+// rendered without their URL.
+func RenderMarkdown(content string) string {
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n"), 0)
+	return b.String()
+}
+
+// RenderPlainText wraps content in a preformatted block, for formats with no
+// renderer.
+func RenderPlainText(content string) string {
+	return "<pre>" + escapeHTML(content) + "</pre>\n"
+}
+
+var (
+	hrRegex         = regexp.MustCompile(`^ {0,3}([-*_])( *[-*_]){2,} *$`)
+	bulletItemRegex = regexp.MustCompile(`^ {0,3}[-*+] +(.*)$`)
+	orderedRegex    = regexp.MustCompile(`^ {0,3}(\d{1,9})[.)] +(.*)$`)
+	coverImageRegex = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^\s)>]+)`)
+)
+
+// CoverImage returns the URL of the first image in markdown content, or "".
+func CoverImage(content string) string {
+	for _, m := range coverImageRegex.FindAllStringSubmatch(content, -1) {
+		if safeURL(m[1]) {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+var (
+	excerptImageRegex = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	excerptLinkRegex  = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	excerptMarkup     = strings.NewReplacer("**", "", "__", "", "*", "", "`", "")
+)
+
+// Excerpt returns the first paragraph of markdown content as plain text,
+// cut at a word boundary to at most maxRunes runes plus an ellipsis.
+// Headings, code, quotes, lists and image-only paragraphs are skipped.
+func Excerpt(content string, maxRunes int) string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence := trimmed[:3]
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					break
+				}
+			}
+			continue
+		}
+		if startsBlock(lines[i]) {
+			continue
+		}
+		var para []string
+		for ; i < len(lines) && !startsBlock(lines[i]); i++ {
+			para = append(para, strings.TrimSpace(lines[i]))
+		}
+		text := excerptImageRegex.ReplaceAllString(strings.Join(para, " "), "")
+		text = excerptMarkup.Replace(excerptLinkRegex.ReplaceAllString(text, "$1"))
+		if text = strings.Join(strings.Fields(text), " "); text != "" {
+			return truncateWords(text, maxRunes)
+		}
+	}
+	return ""
+}
+
+func truncateWords(s string, maxRunes int) string {
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	cut := string(runes[:maxRunes])
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+// maxQuoteDepth caps block quote nesting. Deeper quotes are rendered as a
+// paragraph, so hostile content cannot exhaust the stack.
+const maxQuoteDepth = 8
+
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			i++
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			i = renderFence(b, lines, i)
+		case headingLevel(line) > 0:
+			level := strconv.Itoa(headingLevel(line))
+			text := strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			text = strings.TrimSpace(strings.TrimRight(text, "#"))
+			b.WriteString("<h" + level + ">")
+			renderInline(b, text)
+			b.WriteString("</h" + level + ">\n")
+			i++
+		case hrRegex.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+			}
+			b.WriteString("<blockquote>\n")
+			if depth+1 < maxQuoteDepth {
+				renderBlocks(b, quoted, depth+1)
+			} else {
+				b.WriteString("<p>")
+				renderInline(b, strings.Join(quoted, "\n"))
+				b.WriteString("</p>\n")
+			}
+			b.WriteString("</blockquote>\n")
+		case bulletItemRegex.MatchString(line):
+			i = renderList(b, lines, i, bulletItemRegex, "ul")
+		case orderedRegex.MatchString(line):
+			i = renderList(b, lines, i, orderedRegex, "ol")
+		default:
+			var para []string
+			for ; i < len(lines) && !startsBlock(lines[i]); i++ {
+				para = append(para, strings.TrimSpace(lines[i]))
+			}
+			b.WriteString("<p>")
+			renderInline(b, strings.Join(para, "\n"))
+			b.WriteString("</p>\n")
+		}
+	}
+}
+
+// startsBlock reports whether line ends a paragraph.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" ||
+		strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") ||
+		strings.HasPrefix(trimmed, ">") ||
+		headingLevel(line) > 0 ||
+		hrRegex.MatchString(line) ||
+		bulletItemRegex.MatchString(line) ||
+		orderedRegex.MatchString(line)
+}
+
+// renderFence writes the fenced code block opening at lines[i] and returns
+// the index of the line after it. An unclosed fence runs to the end.
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	opener := strings.TrimSpace(lines[i])
+	fence := opener[:3]
+	lang, _, _ := strings.Cut(strings.TrimSpace(opener[3:]), " ")
+	if lang != "" {
+		b.WriteString(`<pre><code class="language-` + escapeHTML(lang) + `">`)
+	} else {
+		b.WriteString("<pre><code>")
+	}
+	for i++; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+			i++
+			break
+		}
+		b.WriteString(escapeHTML(lines[i]) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+// renderList writes consecutive items matching item as a tag list. Indented
+// lines continue the previous item.
+func renderList(b *strings.Builder, lines []string, i int, item *regexp.Regexp, tag string) int {
+	start := i
+	var items []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if m := item.FindStringSubmatch(line); m != nil {
+			items = append(items, m[len(m)-1])
+			continue
+		}
+		if strings.TrimSpace(line) == "" || !strings.HasPrefix(line, " ") || startsBlock(line) {
+			break
+		}
+		items[len(items)-1] += "\n" + strings.TrimSpace(line)
+	}
+	open := "<" + tag + ">\n"
+	if m := orderedRegex.FindStringSubmatch(lines[start]); tag == "ol" && m != nil && m[1] != "1" {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			open = `<ol start="` + strconv.Itoa(n) + `">` + "\n"
+		}
+	}
+	b.WriteString(open)
+	for _, text := range items {
+		b.WriteString("<li>")
+		renderInline(b, text)
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func renderInline(b *strings.Builder, s string) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			b.WriteString(escapeHTML(s[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				b.WriteString("<code>" + escapeHTML(s[i+1:i+1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+		case c == '!' && strings.HasPrefix(s[i+1:], "["):
+			if alt, url, n, ok := parseLink(s[i+1:]); ok {
+				b.WriteString(`<img src="` + escapeHTML(linkURL(url)) + `" alt="` + escapeHTML(alt) + `">`)
+				i += n + 1
+				continue
+			}
+		case c == '[':
+			if text, url, n, ok := parseLink(s[i:]); ok {
+				b.WriteString(`<a href="` + escapeHTML(linkURL(url)) + `">`)
+				renderInline(b, text)
+				b.WriteString("</a>")
+				i += n
+				continue
+			}
+		case strings.HasPrefix(s[i:], "**") || strings.HasPrefix(s[i:], "__"):
+			delim := s[i : i+2]
+			if end := strings.Index(s[i+2:], delim); end > 0 {
+				b.WriteString("<strong>")
+				renderInline(b, s[i+2:i+2+end])
+				b.WriteString("</strong>")
+				i += end + 4
+				continue
+			}
+		case c == '*' || (c == '_' && (i == 0 || !isWordByte(s[i-1]))):
+			if end := strings.IndexByte(s[i+1:], c); end > 0 {
+				b.WriteString("<em>")
+				renderInline(b, s[i+1:i+1+end])
+				b.WriteString("</em>")
+				i += end + 2
+				continue
+			}
+		}
+		b.WriteString(escapeHTML(s[i : i+1]))
+		i++
+	}
+}
+
+// parseLink parses "[text](url)" at the start of s and returns the text, the
+// URL and the number of bytes consumed. An optional title after the URL is
+// dropped. Neither the text nor the URL may contain "[" or a newline, so
+// scans started from different brackets never overlap and a paragraph is
+// parsed in linear time.
+func parseLink(s string) (text, url string, n int, ok bool) {
+	if !strings.HasPrefix(s, "[") {
+		return "", "", 0, false
+	}
+	closeText := strings.IndexAny(s[1:], "[]\n") + 1
+	if closeText == 0 || !strings.HasPrefix(s[closeText:], "](") {
+		return "", "", 0, false
+	}
+	closeURL := strings.IndexAny(s[closeText+2:], ")[\n")
+	if closeURL < 0 || s[closeText+2+closeURL] != ')' {
+		return "", "", 0, false
+	}
+	text = s[1:closeText]
+	url, _, _ = strings.Cut(strings.TrimSpace(s[closeText+2:closeText+2+closeURL]), " ")
+	url = strings.TrimSuffix(strings.TrimPrefix(url, "<"), ">")
+	return text, url, closeText + 3 + closeURL, true
+}
+
+// linkURL returns url if it is safe to emit, or "#".
+func linkURL(url string) string {
+	if safeURL(url) {
+		return url
+	}
+	return "#"
+}
+
+// safeURL accepts http, https and mailto URLs and relative references.
+func safeURL(url string) bool {
+	scheme, _, found := strings.Cut(url, ":")
+	if !found || strings.ContainsAny(scheme, "/?#") {
+		return true
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// isWordByte keeps underscores inside identifiers such as snake_case from
+// starting emphasis.
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+var htmlEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&#34;",
+	"'", "&#39;",
+)
+
+func escapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
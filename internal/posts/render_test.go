@@ -0,0 +1,94 @@
+package posts
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"heading", "## Title ##", "<h2>Title</h2>\n"},
+		{"paragraph", "one\ntwo\n\nthree", "<p>one\ntwo</p>\n<p>three</p>\n"},
+		{"inline", "a **b** *c* `<d>` snake_case_name", "<p>a <strong>b</strong> <em>c</em> <code>&lt;d&gt;</code> snake_case_name</p>\n"},
+		{"link", `[x](https://e.com "t")`, `<p><a href="https://e.com">x</a></p>` + "\n"},
+		{"unsafe link", "[x](javascript:alert(1))", `<p><a href="#">x</a>)</p>` + "\n"},
+		{"image", `![a "b"](/i.png)`, `<p><img src="/i.png" alt="a &#34;b&#34;"></p>` + "\n"},
+		{"raw html escaped", "<b onclick=x>hi</b>", "<p>&lt;b onclick=x&gt;hi&lt;/b&gt;</p>\n"},
+		{"fence", "```go\nif a < b {}\n```\nafter", `<pre><code class="language-go">if a &lt; b {}` + "\n</code></pre>\n<p>after</p>\n"},
+		{"bullets", "- a\n- b\n  more", "<ul>\n<li>a</li>\n<li>b\nmore</li>\n</ul>\n"},
+		{"ordered", "3. c\n4. d", "<ol start=\"3\">\n<li>c</li>\n<li>d</li>\n</ol>\n"},
+		{"quote", "> q\n> r", "<blockquote>\n<p>q\nr</p>\n</blockquote>\n"},
+		{"rule", "a\n\n---", "<p>a</p>\n<hr>\n"},
+		{"escape", `\*not em\*`, "<p>*not em*</p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderMarkdown(tt.in); got != tt.want {
+				t.Errorf("RenderMarkdown(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown_NestedQuotes(t *testing.T) {
+	got := RenderMarkdown(strings.Repeat(">", 12) + " deep")
+	if n := strings.Count(got, "<blockquote>"); n != maxQuoteDepth {
+		t.Errorf("rendered %d nested quotes, want %d:\n%s", n, maxQuoteDepth, got)
+	}
+	if !strings.Contains(got, "<p>&gt;&gt;&gt;&gt; deep</p>") {
+		t.Errorf("quotes past the limit should render as text:\n%s", got)
+	}
+}
+
+// TestRenderMarkdown_Hostile renders inputs that used to overflow the stack
+// at the content size limit, or take quadratic time.
+func TestRenderMarkdown_Hostile(t *testing.T) {
+	const size = 2 << 20
+	for name, in := range map[string]string{
+		"quotes":        strings.Repeat(">", 10<<20),
+		"quote lines":   strings.Repeat(">>>>>>>>>>\n", size/11),
+		"brackets":      strings.Repeat("[", size),
+		"open links":    strings.Repeat("[a](", size/4),
+		"bracket lines": strings.Repeat("[a]\n", size/4),
+		"images":        strings.Repeat("![", size/2),
+	} {
+		start := time.Now()
+		RenderMarkdown(in)
+		if d := time.Since(start); d > 10*time.Second {
+			t.Errorf("%s: rendering took %v", name, d)
+		}
+	}
+}
+
+func TestCoverImage(t *testing.T) {
+	content := "intro\n![x](javascript:alert(1))\n![cover](https://cdn.example.com/a.png)\n![b](/b.png)"
+	if got := CoverImage(content); got != "https://cdn.example.com/a.png" {
+		t.Errorf("CoverImage = %q", got)
+	}
+	if got := CoverImage("no images"); got != "" {
+		t.Errorf("CoverImage = %q, want empty", got)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name, in string
+		max      int
+		want     string
+	}{
+		{"first paragraph", "# Title\n\n![img](/a.png)\n\nFirst **para**\nwith a [link](/x).\n\nSecond.", 200, "First para with a link."},
+		{"skips code and lists", "```\ncode\n```\n- item\n\n> quote\n\nBody.", 200, "Body."},
+		{"truncates at word", "one two three four", 12, "one two…"},
+		{"empty", "# Only a heading", 200, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Excerpt(tt.in, tt.max); got != tt.want {
+				t.Errorf("Excerpt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,14 +4,32 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/outbox"
 )
 
+// NewEventFunc builds the outbox message for a post state change. It runs inside
+// the repository transaction so the event commits atomically with the change.
+type NewEventFunc func(post *Post) (outbox.Message, error)
+
 type Repository interface {
-	Create(ctx context.Context, title, slug, s3Key string) (*Post, error)
+	Create(ctx context.Context, params CreateParams) (*Post, error)
 	GetBySlug(ctx context.Context, slug string) (*Post, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*Post, error)
 	List(ctx context.Context, params ListParams) ([]*Post, error)
-	Count(ctx context.Context, status *Status) (int64, error)
-	Update(ctx context.Context, id uuid.UUID, title, slug, s3Key string) (*Post, error)
+	// Count counts posts with any of statuses, or all posts when empty.
+	Count(ctx context.Context, statuses []Status) (int64, error)
+	Update(ctx context.Context, params UpdateParams) (*Post, error)
 	Delete(ctx context.Context, slug string) error
-	Publish(ctx context.Context, slug string) (*Post, error)
+	Publish(ctx context.Context, slug string, newEvent NewEventFunc) (*Post, error)
+	// RevertPublish returns a published post to draft and drops its outbox
+	// message eventID if that has not been sent.
+	RevertPublish(ctx context.Context, id, eventID uuid.UUID) error
+	IncrementViews(ctx context.Context, id uuid.UUID, delta int64) error
+	ListTags(ctx context.Context, status *Status) ([]TagCount, error)
+	// ExistingSlugs returns those of slugs that belong to a post, in any
+	// order.
+	ExistingSlugs(ctx context.Context, slugs []string) ([]string, error)
+	// Adjacent returns the slugs of the published posts created immediately
+	// before and after post, or "" where there is none.
+	Adjacent(ctx context.Context, post *Post) (prev, next string, err error)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jeremyjsx/entries/internal/db"
@@ -13,19 +14,32 @@ import (
 var _ Repository = (*postgresRepository)(nil)
 
 type postgresRepository struct {
+	db      *sql.DB
 	queries *db.Queries
 }
 
 func NewPostgresRepository(sqlDB *sql.DB) Repository {
-	return &postgresRepository{queries: db.New(sqlDB)}
+	return &postgresRepository{db: sqlDB, queries: db.New(sqlDB)}
 }
 
-func (r *postgresRepository) Create(ctx context.Context, title, slug, s3Key string) (*Post, error) {
+func (r *postgresRepository) Create(ctx context.Context, params CreateParams) (*Post, error) {
+	if params.Format == "" {
+		params.Format = FormatMarkdown
+	}
+	if params.Tags == nil {
+		params.Tags = []string{}
+	}
 	dbPost, err := r.queries.CreatePost(ctx, db.CreatePostParams{
-		Title:  title,
-		Slug:   slug,
-		S3Key:  s3Key,
-		Status: string(Draft),
+		Title:            params.Title,
+		Slug:             params.Slug,
+		S3Key:            params.S3Key,
+		Status:           string(Draft),
+		ContentSha256:    params.ContentSHA256,
+		Format:           string(params.Format),
+		Tags:             params.Tags,
+		CanonicalUrl:     nullString(params.CanonicalURL),
+		CreatedAt:        nullTime(params.CreatedAt),
+		RawContentSha256: params.RawContentSHA256,
 	})
 	if err != nil {
 		var pqErr *pq.Error
@@ -48,17 +62,35 @@ func (r *postgresRepository) GetBySlug(ctx context.Context, slug string) (*Post,
 	return toPost(dbPost), nil
 }
 
-func (r *postgresRepository) List(ctx context.Context, params ListParams) ([]*Post, error) {
-	var status sql.NullString
-	if params.Status != nil {
-		status = sql.NullString{String: string(*params.Status), Valid: true}
+func (r *postgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*Post, error) {
+	dbPost, err := r.queries.GetPostByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
+	return toPost(dbPost), nil
+}
 
-	dbPosts, err := r.queries.ListPosts(ctx, db.ListPostsParams{
-		Limit:  int32(params.Limit),
-		Offset: int32(params.Offset),
-		Status: status,
-	})
+func (r *postgresRepository) List(ctx context.Context, params ListParams) ([]*Post, error) {
+	var (
+		dbPosts []db.Post
+		err     error
+	)
+	if params.Sort == SortViews {
+		// Its own query so the plan can walk idx_posts_published_views.
+		dbPosts, err = r.queries.ListPostsByViews(ctx, db.ListPostsByViewsParams{
+			Limit:  int32(params.Limit),
+			Offset: int32(params.Offset),
+		})
+	} else {
+		dbPosts, err = r.queries.ListPosts(ctx, db.ListPostsParams{
+			Limit:    int32(params.Limit),
+			Offset:   int32(params.Offset),
+			Statuses: statusStrings(params.Statuses),
+		})
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -70,23 +102,43 @@ func (r *postgresRepository) List(ctx context.Context, params ListParams) ([]*Po
 	return posts, nil
 }
 
-func (r *postgresRepository) Count(ctx context.Context, status *Status) (int64, error) {
-	var s sql.NullString
-	if status != nil {
-		s = sql.NullString{String: string(*status), Valid: true}
+func (r *postgresRepository) Count(ctx context.Context, statuses []Status) (int64, error) {
+	return r.queries.CountPosts(ctx, statusStrings(statuses))
+}
+
+// statusStrings converts a status filter for the queries, where nil (SQL
+// NULL) matches every status.
+func statusStrings(statuses []Status) []string {
+	if len(statuses) == 0 {
+		return nil
 	}
-	return r.queries.CountPosts(ctx, s)
+	out := make([]string, len(statuses))
+	for i, s := range statuses {
+		out[i] = string(s)
+	}
+	return out
 }
 
-func (r *postgresRepository) Update(ctx context.Context, id uuid.UUID, title, slug, s3Key string) (*Post, error) {
+func (r *postgresRepository) Update(ctx context.Context, params UpdateParams) (*Post, error) {
+	if params.Tags == nil {
+		params.Tags = []string{}
+	}
 	dbPost, err := r.queries.UpdatePost(ctx, db.UpdatePostParams{
-		ID:    id,
-		Title: title,
-		Slug:  slug,
-		S3Key: s3Key,
+		ID:                    params.ID,
+		Title:                 params.Title,
+		Slug:                  params.Slug,
+		S3Key:                 params.S3Key,
+		ContentSha256:         params.ContentSHA256,
+		Tags:                  params.Tags,
+		CanonicalUrl:          nullString(params.CanonicalURL),
+		RawContentSha256:      params.RawContentSHA256,
+		ExpectedContentSha256: nullString(params.ExpectedContentSHA256),
 	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			if params.ExpectedContentSHA256 != nil {
+				return nil, ErrPreconditionFailed
+			}
 			return nil, ErrNotFound
 		}
 		var pqErr *pq.Error
@@ -102,25 +154,132 @@ func (r *postgresRepository) Delete(ctx context.Context, slug string) error {
 	return r.queries.DeletePostBySlug(ctx, slug)
 }
 
-func (r *postgresRepository) Publish(ctx context.Context, slug string) (*Post, error) {
-	dbPost, err := r.queries.PublishPost(ctx, slug)
+func (r *postgresRepository) Publish(ctx context.Context, slug string, newEvent NewEventFunc) (*Post, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	q := r.queries.WithTx(tx)
+
+	dbPost, err := q.PublishPost(ctx, slug)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
-	return toPost(dbPost), nil
+	post := toPost(dbPost)
+	if newEvent != nil {
+		msg, err := newEvent(post)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.InsertOutboxEvent(ctx, db.InsertOutboxEventParams{
+			ID:        msg.ID,
+			EventType: msg.Type,
+			Payload:   msg.Payload,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return post, nil
 }
 
+func (r *postgresRepository) RevertPublish(ctx context.Context, id, eventID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	q := r.queries.WithTx(tx)
+
+	if err := q.RevertPublishPost(ctx, id); err != nil {
+		return err
+	}
+	if err := q.DeleteUnsentOutboxEvent(ctx, eventID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *postgresRepository) IncrementViews(ctx context.Context, id uuid.UUID, delta int64) error {
+	return r.queries.IncrementPostViews(ctx, db.IncrementPostViewsParams{Delta: delta, ID: id})
+}
+
+func (r *postgresRepository) ListTags(ctx context.Context, status *Status) ([]TagCount, error) {
+	var s sql.NullString
+	if status != nil {
+		s = sql.NullString{String: string(*status), Valid: true}
+	}
+	rows, err := r.queries.ListTagCounts(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]TagCount, len(rows))
+	for i, row := range rows {
+		tags[i] = TagCount{Tag: row.Tag, Count: row.Count}
+	}
+	return tags, nil
+}
+
+func (r *postgresRepository) ExistingSlugs(ctx context.Context, slugs []string) ([]string, error) {
+	return r.queries.ListExistingSlugs(ctx, slugs)
+}
+
+func (r *postgresRepository) Adjacent(ctx context.Context, post *Post) (string, string, error) {
+	prev, err := r.queries.GetPreviousPublishedSlug(ctx, db.GetPreviousPublishedSlugParams{CreatedAt: post.CreatedAt, ID: post.ID})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", "", err
+	}
+	next, err := r.queries.GetNextPublishedSlug(ctx, db.GetNextPublishedSlugParams{CreatedAt: post.CreatedAt, ID: post.ID})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", "", err
+	}
+	return prev, next, nil
+}
+
+// toPost converts a row to a Post. Timestamps are normalized to UTC so they
+// serialize with a "Z" suffix whatever the session time zone.
 func toPost(p db.Post) *Post {
+	tags := p.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	var canonicalURL *string
+	if p.CanonicalUrl.Valid {
+		canonicalURL = &p.CanonicalUrl.String
+	}
 	return &Post{
-		ID:        p.ID,
-		Title:     p.Title,
-		Slug:      p.Slug,
-		S3Key:     p.S3Key,
-		Status:    Status(p.Status),
-		CreatedAt: p.CreatedAt,
-		UpdatedAt: p.UpdatedAt,
+		ID:               p.ID,
+		Title:            p.Title,
+		Slug:             p.Slug,
+		S3Key:            p.S3Key,
+		Status:           Status(p.Status),
+		Format:           Format(p.Format),
+		Tags:             tags,
+		ContentSHA256:    p.ContentSha256,
+		CanonicalURL:     canonicalURL,
+		Views:            p.Views,
+		CreatedAt:        p.CreatedAt.UTC(),
+		UpdatedAt:        p.UpdatedAt.UTC(),
+		RawContentSHA256: p.RawContentSha256,
+	}
+}
+
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
 	}
+	return sql.NullTime{Time: *t, Valid: true}
 }
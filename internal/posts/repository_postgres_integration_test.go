@@ -0,0 +1,422 @@
+//go:build integration
+
+package posts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/outbox"
+	_ "github.com/lib/pq"
+)
+
+func testPostgresRepo(t *testing.T) Repository {
+	t.Helper()
+	return NewPostgresRepository(testPostgresDB(t))
+}
+
+// testPostgresDB opens TEST_DATABASE_URL with an empty posts table.
+func testPostgresDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	sqlDB, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if _, err := sqlDB.Exec("TRUNCATE posts"); err != nil {
+		t.Fatalf("truncate posts: %v", err)
+	}
+	return sqlDB
+}
+
+func TestPostgresRepository_ListSortByViews(t *testing.T) {
+	ctx := context.Background()
+	repo := testPostgresRepo(t)
+
+	views := map[string]int64{"cold": 1, "hot": 50, "warm": 7}
+	for _, slug := range []string{"cold", "hot", "warm"} {
+		created, err := repo.Create(ctx, CreateParams{Title: slug, Slug: slug, S3Key: "posts/" + slug + ".md"})
+		if err != nil {
+			t.Fatalf("Create %s: %v", slug, err)
+		}
+		if _, err := repo.Publish(ctx, slug, nil); err != nil {
+			t.Fatalf("Publish %s: %v", slug, err)
+		}
+		if err := repo.IncrementViews(ctx, created.ID, views[slug]); err != nil {
+			t.Fatalf("IncrementViews %s: %v", slug, err)
+		}
+	}
+
+	got, err := repo.List(ctx, ListParams{Limit: 10, Statuses: []Status{Published}, Sort: SortViews})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := []string{"hot", "warm", "cold"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d posts, want %d", len(got), len(want))
+	}
+	for i, p := range got {
+		if p.Slug != want[i] || p.Views != views[want[i]] {
+			t.Errorf("posts[%d] = %s (%d views), want %s (%d views)", i, p.Slug, p.Views, want[i], views[want[i]])
+		}
+	}
+}
+
+func TestPostgresRepository_ListStatuses(t *testing.T) {
+	ctx := context.Background()
+	repo := testPostgresRepo(t)
+
+	for _, slug := range []string{"draft", "live"} {
+		if _, err := repo.Create(ctx, CreateParams{Title: slug, Slug: slug, S3Key: "posts/" + slug + ".md"}); err != nil {
+			t.Fatalf("Create %s: %v", slug, err)
+		}
+	}
+	if _, err := repo.Publish(ctx, "live", nil); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	tests := []struct {
+		statuses []Status
+		want     int
+	}{
+		{nil, 2},
+		{[]Status{Draft}, 1},
+		{[]Status{Published}, 1},
+		{[]Status{Draft, Published}, 2},
+	}
+	for _, tt := range tests {
+		got, err := repo.List(ctx, ListParams{Limit: 10, Statuses: tt.statuses})
+		if err != nil {
+			t.Fatalf("List %v: %v", tt.statuses, err)
+		}
+		total, err := repo.Count(ctx, tt.statuses)
+		if err != nil {
+			t.Fatalf("Count %v: %v", tt.statuses, err)
+		}
+		if len(got) != tt.want || total != int64(tt.want) {
+			t.Errorf("%v: listed %d, counted %d, want %d", tt.statuses, len(got), total, tt.want)
+		}
+	}
+}
+
+func TestPostgresRepository_ListTiedCreatedAt(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := testPostgresDB(t)
+	repo := NewPostgresRepository(sqlDB)
+
+	for i := range 7 {
+		slug := fmt.Sprintf("batch-%d", i)
+		if _, err := repo.Create(ctx, CreateParams{Title: slug, Slug: slug, S3Key: "posts/" + slug + ".md"}); err != nil {
+			t.Fatalf("Create %s: %v", slug, err)
+		}
+	}
+	// A batch import can leave every row with the same timestamp.
+	if _, err := sqlDB.ExecContext(ctx, "UPDATE posts SET created_at = '2024-01-01T00:00:00Z'"); err != nil {
+		t.Fatalf("tie created_at: %v", err)
+	}
+
+	all, err := repo.List(ctx, ListParams{Limit: 10})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i-1].ID.String() < all[i].ID.String() {
+			t.Fatalf("ties not broken by id descending: %s before %s", all[i-1].ID, all[i].ID)
+		}
+	}
+	var paged []*Post
+	for offset := 0; offset < len(all); offset += 3 {
+		page, err := repo.List(ctx, ListParams{Limit: 3, Offset: offset})
+		if err != nil {
+			t.Fatalf("List offset %d: %v", offset, err)
+		}
+		paged = append(paged, page...)
+	}
+	if len(paged) != len(all) {
+		t.Fatalf("paged %d posts, want %d", len(paged), len(all))
+	}
+	for i := range all {
+		if paged[i].ID != all[i].ID {
+			t.Errorf("position %d: paged %s, want %s", i, paged[i].Slug, all[i].Slug)
+		}
+	}
+}
+
+func TestPostgresRepository_CreateWithCreatedAt(t *testing.T) {
+	ctx := context.Background()
+	repo := testPostgresRepo(t)
+
+	createdAt := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	old, err := repo.Create(ctx, CreateParams{Title: "old", Slug: "old", S3Key: "posts/old.md", CreatedAt: &createdAt})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !old.CreatedAt.Equal(createdAt) {
+		t.Errorf("CreatedAt = %v, want %v", old.CreatedAt, createdAt)
+	}
+
+	before := time.Now().Add(-time.Minute)
+	fresh, err := repo.Create(ctx, CreateParams{Title: "new", Slug: "new", S3Key: "posts/new.md"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if fresh.CreatedAt.Before(before) {
+		t.Errorf("CreatedAt = %v, want about now", fresh.CreatedAt)
+	}
+}
+
+func TestPostgresRepository_PublishEventErrorRollsBack(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := testPostgresDB(t)
+	repo := NewPostgresRepository(sqlDB)
+
+	if _, err := repo.Create(ctx, CreateParams{Title: "p", Slug: "p", S3Key: "posts/p.md"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	msgID := uuid.New()
+	_, err := repo.Publish(ctx, "p", func(*Post) (outbox.Message, error) {
+		return outbox.Message{ID: msgID, Type: "post.published", Payload: []byte(`{}`)}, ErrEventUndelivered
+	})
+	if !errors.Is(err, ErrEventUndelivered) {
+		t.Fatalf("Publish: err = %v, want ErrEventUndelivered", err)
+	}
+	post, err := repo.GetBySlug(ctx, "p")
+	if err != nil {
+		t.Fatalf("GetBySlug: %v", err)
+	}
+	if post.Status != Draft {
+		t.Errorf("Status = %s, want draft", post.Status)
+	}
+	var n int
+	if err := sqlDB.QueryRowContext(ctx, "SELECT count(*) FROM event_outbox WHERE id = $1", msgID).Scan(&n); err != nil {
+		t.Fatalf("count outbox: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("outbox has %d rows for the rolled-back publish", n)
+	}
+}
+
+func TestPostgresRepository_RevertPublish(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := testPostgresDB(t)
+	repo := NewPostgresRepository(sqlDB)
+
+	if _, err := repo.Create(ctx, CreateParams{Title: "p", Slug: "p", S3Key: "posts/p.md"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	msgID := uuid.New()
+	post, err := repo.Publish(ctx, "p", func(*Post) (outbox.Message, error) {
+		return outbox.Message{ID: msgID, Type: "post.published", Payload: []byte(`{}`)}, nil
+	})
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := repo.RevertPublish(ctx, post.ID, msgID); err != nil {
+		t.Fatalf("RevertPublish: %v", err)
+	}
+	got, err := repo.GetBySlug(ctx, "p")
+	if err != nil {
+		t.Fatalf("GetBySlug: %v", err)
+	}
+	if got.Status != Draft {
+		t.Errorf("Status = %s, want draft", got.Status)
+	}
+	var n int
+	if err := sqlDB.QueryRowContext(ctx, "SELECT count(*) FROM event_outbox WHERE id = $1", msgID).Scan(&n); err != nil {
+		t.Fatalf("count outbox: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("outbox still has the reverted publish's message")
+	}
+}
+
+func TestPostgresRepository_Tags(t *testing.T) {
+	ctx := context.Background()
+	repo := testPostgresRepo(t)
+
+	created, err := repo.Create(ctx, CreateParams{Title: "T", Slug: "tagged", S3Key: "posts/tagged.md", Tags: []string{"go", "web"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !slices.Equal(created.Tags, []string{"go", "web"}) {
+		t.Errorf("created tags %q", created.Tags)
+	}
+	updated, err := repo.Update(ctx, UpdateParams{ID: created.ID, Title: "T", Slug: "tagged", S3Key: created.S3Key})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Tags == nil || len(updated.Tags) != 0 {
+		t.Errorf("updated tags %q, want empty", updated.Tags)
+	}
+}
+
+func TestPostgresRepository_UpdateExpectedChecksum(t *testing.T) {
+	ctx := context.Background()
+	repo := testPostgresRepo(t)
+
+	created, err := repo.Create(ctx, CreateParams{Title: "T", Slug: "cas", S3Key: "posts/cas.md", ContentSHA256: "v1"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	params := UpdateParams{ID: created.ID, Title: "T", Slug: "cas", S3Key: created.S3Key, ContentSHA256: "v2", RawContentSHA256: "raw2"}
+	stale := "v0"
+	params.ExpectedContentSHA256 = &stale
+	if _, err := repo.Update(ctx, params); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("stale checksum: err = %v, want ErrPreconditionFailed", err)
+	}
+	current := "v1"
+	params.ExpectedContentSHA256 = &current
+	updated, err := repo.Update(ctx, params)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.ContentSHA256 != "v2" || updated.RawContentSHA256 != "raw2" {
+		t.Errorf("checksums = %q, %q, want v2, raw2", updated.ContentSHA256, updated.RawContentSHA256)
+	}
+}
+
+func TestPostgresRepository_GetByID(t *testing.T) {
+	ctx := context.Background()
+	repo := testPostgresRepo(t)
+
+	created, err := repo.Create(ctx, CreateParams{Title: "T", Slug: "by-id", S3Key: "posts/by-id.md"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	got, err := repo.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Slug != "by-id" {
+		t.Errorf("slug %q", got.Slug)
+	}
+	if _, err := repo.GetByID(ctx, uuid.New()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing id: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPostgresRepository_ListTags(t *testing.T) {
+	ctx := context.Background()
+	repo := testPostgresRepo(t)
+
+	seed := []struct {
+		slug    string
+		tags    []string
+		publish bool
+	}{
+		{"a", []string{"go", "web"}, true},
+		{"b", []string{"go"}, true},
+		{"c", []string{"go", "rust"}, true},
+		{"d", []string{"rust", "web"}, false},
+		{"e", nil, true},
+	}
+	for _, p := range seed {
+		if _, err := repo.Create(ctx, CreateParams{Title: p.slug, Slug: p.slug, S3Key: "posts/" + p.slug + ".md", Tags: p.tags}); err != nil {
+			t.Fatalf("Create %s: %v", p.slug, err)
+		}
+		if p.publish {
+			if _, err := repo.Publish(ctx, p.slug, nil); err != nil {
+				t.Fatalf("Publish %s: %v", p.slug, err)
+			}
+		}
+	}
+
+	published := Published
+	got, err := repo.ListTags(ctx, &published)
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	want := []TagCount{{"go", 3}, {"rust", 1}, {"web", 1}}
+	if !slices.Equal(got, want) {
+		t.Errorf("published = %+v, want %+v", got, want)
+	}
+
+	got, err = repo.ListTags(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	want = []TagCount{{"go", 3}, {"rust", 2}, {"web", 2}}
+	if !slices.Equal(got, want) {
+		t.Errorf("all = %+v, want %+v", got, want)
+	}
+}
+
+func TestPostgresRepository_Adjacent(t *testing.T) {
+	ctx := context.Background()
+	repo := testPostgresRepo(t)
+
+	created := make(map[string]*Post)
+	for _, p := range []struct {
+		slug    string
+		publish bool
+	}{{"first", true}, {"hidden-draft", false}, {"middle", true}, {"last", true}} {
+		post, err := repo.Create(ctx, CreateParams{Title: p.slug, Slug: p.slug, S3Key: "posts/" + p.slug + ".md"})
+		if err != nil {
+			t.Fatalf("Create %s: %v", p.slug, err)
+		}
+		if p.publish {
+			if _, err := repo.Publish(ctx, p.slug, nil); err != nil {
+				t.Fatalf("Publish %s: %v", p.slug, err)
+			}
+		}
+		created[p.slug] = post
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	tests := []struct{ slug, prev, next string }{
+		{"first", "", "middle"},
+		{"middle", "first", "last"},
+		{"last", "middle", ""},
+	}
+	for _, tt := range tests {
+		prev, next, err := repo.Adjacent(ctx, created[tt.slug])
+		if err != nil {
+			t.Fatalf("Adjacent %s: %v", tt.slug, err)
+		}
+		if prev != tt.prev || next != tt.next {
+			t.Errorf("Adjacent(%s) = %q, %q; want %q, %q", tt.slug, prev, next, tt.prev, tt.next)
+		}
+	}
+}
+
+func TestPostgresRepository_ExistingSlugs(t *testing.T) {
+	ctx := context.Background()
+	repo := testPostgresRepo(t)
+
+	for _, slug := range []string{"one", "two"} {
+		if _, err := repo.Create(ctx, CreateParams{Title: slug, Slug: slug, S3Key: "posts/" + slug + ".md"}); err != nil {
+			t.Fatalf("Create %s: %v", slug, err)
+		}
+	}
+	if _, err := repo.Publish(ctx, "one", nil); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	got, err := repo.ExistingSlugs(ctx, []string{"two", "missing", "one"})
+	if err != nil {
+		t.Fatalf("ExistingSlugs: %v", err)
+	}
+	slices.Sort(got)
+	if want := []string{"one", "two"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got, err = repo.ExistingSlugs(ctx, []string{"missing"})
+	if err != nil {
+		t.Fatalf("ExistingSlugs: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %q, want none", got)
+	}
+}
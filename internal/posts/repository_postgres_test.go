@@ -0,0 +1,30 @@
+package posts
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremyjsx/entries/internal/db"
+)
+
+func TestToPost_UTCTimestamps(t *testing.T) {
+	// A session with TimeZone=America/Bogota hands back local offsets.
+	bogota := time.FixedZone("COT", -5*60*60)
+	created := time.Date(2025, 3, 1, 7, 30, 0, 0, bogota)
+	post := toPost(db.Post{Slug: "a", CreatedAt: created, UpdatedAt: created.Add(time.Hour)})
+
+	if post.CreatedAt.Location() != time.UTC || !post.CreatedAt.Equal(created) {
+		t.Errorf("CreatedAt = %v, want %v in UTC", post.CreatedAt, created)
+	}
+	data, err := json.Marshal(post)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, want := range []string{`"created_at":"2025-03-01T12:30:00Z"`, `"updated_at":"2025-03-01T13:30:00Z"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON %s missing %s", data, want)
+		}
+	}
+}
@@ -2,37 +2,112 @@ package posts
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jeremyjsx/entries/internal/events"
+	"github.com/jeremyjsx/entries/internal/outbox"
 	"github.com/jeremyjsx/entries/internal/storage"
+	"golang.org/x/sync/singleflight"
 )
 
-const maxImageSize = 5 << 20
+const (
+	consistencyRetryBackoff = 50 * time.Millisecond
+	defaultMaxImageSize     = 5 << 20
+	defaultSignedURLTTL     = 15 * time.Minute
+	eventPublishTimeout     = 5 * time.Second
+	revertPublishTimeout    = 5 * time.Second
+	sharedDownloadTimeout   = 30 * time.Second
+)
 
-var dataURLImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\(data:image/([a-zA-Z]+);base64,([^)]+)\)`)
+var (
+	dataURLImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\(data:image/([a-zA-Z]+);base64,([^)]+)\)`)
+	// Matches any data-URL image, including types that are never uploaded.
+	anyDataURLImageRegex = regexp.MustCompile(`!\[[^\]]*\]\(\s*data:image/`)
+)
 
 type ServiceConfig struct {
 	S3Bucket        string
 	AWSRegion       string
 	S3PublicBaseURL string
+	// MaxImageBytes caps each embedded image's decoded size. Defaults to 5MiB.
+	MaxImageBytes int64
+	// MaxImageDimension caps embedded image width and height. Defaults to 10000.
+	MaxImageDimension int
+	// ImageURLSigner, when set, presigns image URLs as content is read.
+	ImageURLSigner storage.URLSigner
+	// SignedURLTTL is how long signed image URLs stay valid. Defaults to 15m.
+	SignedURLTTL time.Duration
+	// ContentCache keeps recently read post bodies in memory. Nil disables it.
+	ContentCache *ContentCache
+	// Views records reads of published post content. Nil disables counting.
+	Views *ViewCounter
+	// Outbox is marked when an event is published inline. Nil skips marking.
+	Outbox outbox.Store
+	// Now is the clock used for event timestamps. Defaults to time.Now.
+	Now func() time.Time
+	// StrictHeadings rejects markdown that fails ValidateHeadings.
+	StrictHeadings bool
+	// ImageEmbedMode controls data-URL images. Defaults to ImageEmbedUpload.
+	ImageEmbedMode ImageEmbedMode
+	// ImageFetcher, when set, rehosts external images on create and update.
+	ImageFetcher *ImageFetcher
+	// ConsistencyWindow retries missing content of recently updated posts.
+	ConsistencyWindow time.Duration
+	// PageOutOfRange defaults to PageOutOfRangeEmpty.
+	PageOutOfRange PageOutOfRangeMode
+	// ContentMissing defaults to ContentMissingFail.
+	ContentMissing ContentMissingMode
+	// ContentPlaceholder defaults to DefaultContentPlaceholder.
+	ContentPlaceholder string
+	// PostURL returns a post's public URL for events. Nil leaves it out.
+	PostURL func(slug string) string
+	// RequireEvent reverts a publish to draft when its event cannot be sent.
+	RequireEvent bool
 }
 
 type Service struct {
-	repo            Repository
-	storage         storage.Storage
-	publisher       events.Publisher
-	logger          *slog.Logger
-	s3Bucket        string
-	awsRegion       string
-	s3PublicBaseURL string
+	repo              Repository
+	storage           storage.Storage
+	publisher         events.Publisher
+	logger            *slog.Logger
+	s3Bucket          string
+	awsRegion         string
+	s3PublicBaseURL   string
+	maxImageBytes     int64
+	maxImageDim       int
+	imageURLSigner    storage.URLSigner
+	signedURLTTL      time.Duration
+	imageURLRegex     *regexp.Regexp
+	contentCache      *ContentCache
+	views             *ViewCounter
+	outbox            outbox.Store
+	now               func() time.Time
+	strictHeadings    bool
+	imageEmbedMode    ImageEmbedMode
+	imageFetcher      *ImageFetcher
+	pageOutOfRange    PageOutOfRangeMode
+	contentMissing    ContentMissingMode
+	placeholder       string
+	consistencyWindow time.Duration
+	postURL           func(slug string) string
+	requireEvent      bool
+	eventTimeout      time.Duration
+	// downloads coalesces concurrent downloads of the same object.
+	downloads singleflight.Group
 }
 
 func NewService(repo Repository, storage storage.Storage, publisher events.Publisher, logger *slog.Logger, opts ServiceConfig) *Service {
@@ -42,15 +117,52 @@ func NewService(repo Repository, storage storage.Storage, publisher events.Publi
 	if logger == nil {
 		logger = slog.Default()
 	}
-	return &Service{
-		repo:            repo,
-		storage:         storage,
-		publisher:       publisher,
-		logger:          logger,
-		s3Bucket:        opts.S3Bucket,
-		awsRegion:       opts.AWSRegion,
-		s3PublicBaseURL: opts.S3PublicBaseURL,
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
+	maxImageBytes := opts.MaxImageBytes
+	if maxImageBytes <= 0 {
+		maxImageBytes = defaultMaxImageSize
+	}
+	maxImageDim := opts.MaxImageDimension
+	if maxImageDim <= 0 {
+		maxImageDim = defaultMaxImageDimension
+	}
+	signedURLTTL := opts.SignedURLTTL
+	if signedURLTTL <= 0 {
+		signedURLTTL = defaultSignedURLTTL
 	}
+	svc := &Service{
+		repo:              repo,
+		storage:           storage,
+		publisher:         publisher,
+		logger:            logger,
+		s3Bucket:          opts.S3Bucket,
+		awsRegion:         opts.AWSRegion,
+		s3PublicBaseURL:   opts.S3PublicBaseURL,
+		maxImageBytes:     maxImageBytes,
+		maxImageDim:       maxImageDim,
+		imageURLSigner:    opts.ImageURLSigner,
+		signedURLTTL:      signedURLTTL,
+		contentCache:      opts.ContentCache,
+		views:             opts.Views,
+		outbox:            opts.Outbox,
+		now:               now,
+		strictHeadings:    opts.StrictHeadings,
+		imageEmbedMode:    cmp.Or(opts.ImageEmbedMode, ImageEmbedUpload),
+		consistencyWindow: opts.ConsistencyWindow,
+		imageFetcher:      opts.ImageFetcher,
+		pageOutOfRange:    cmp.Or(opts.PageOutOfRange, PageOutOfRangeEmpty),
+		contentMissing:    cmp.Or(opts.ContentMissing, ContentMissingFail),
+		placeholder:       cmp.Or(opts.ContentPlaceholder, DefaultContentPlaceholder),
+		postURL:           opts.PostURL,
+		requireEvent:      opts.RequireEvent,
+		eventTimeout:      eventPublishTimeout,
+	}
+	// Matches the URLs processMarkdownImages writes, capturing the object key.
+	svc.imageURLRegex = regexp.MustCompile(regexp.QuoteMeta(svc.s3PublicURL("")) + `(posts/[^\s()"'<>]+/images/[^\s()"'<>]+)`)
+	return svc
 }
 
 func (s *Service) s3PublicURL(key string) string {
@@ -60,7 +172,10 @@ func (s *Service) s3PublicURL(key string) string {
 	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.s3Bucket, s.awsRegion, key)
 }
 
-func (s *Service) processMarkdownImages(ctx context.Context, slug, content string) string {
+// processMarkdownImages uploads data-URL images in content to storage and
+// rewrites them to public URLs. It returns the rewritten content and the keys
+// of the uploaded images.
+func (s *Service) processMarkdownImages(ctx context.Context, slug, content string) (string, []string) {
 	allowedTypes := map[string]string{
 		"png":  "image/png",
 		"jpeg": "image/jpeg",
@@ -69,6 +184,7 @@ func (s *Service) processMarkdownImages(ctx context.Context, slug, content strin
 		"gif":  "image/gif",
 	}
 
+	var keys []string
 	result := dataURLImageRegex.ReplaceAllStringFunc(content, func(match string) string {
 		subs := dataURLImageRegex.FindStringSubmatch(match)
 		if len(subs) != 4 {
@@ -81,30 +197,116 @@ func (s *Service) processMarkdownImages(ctx context.Context, slug, content strin
 		}
 		b64Clean := strings.ReplaceAll(strings.ReplaceAll(b64, "\n", ""), "\r", "")
 		data, err := base64.StdEncoding.DecodeString(b64Clean)
-		if err != nil || len(data) > maxImageSize {
+		if err != nil || int64(len(data)) > s.maxImageBytes {
+			return match
+		}
+		if err := checkImageDimensions(data, s.maxImageDim); err != nil {
+			s.logger.Warn("embedded image left inline", "slug", slug, "error", err)
 			return match
 		}
 		key := fmt.Sprintf("posts/%s/images/%s.%s", slug, uuid.New().String(), ext)
 		if err := s.storage.Upload(ctx, key, strings.NewReader(string(data)), contentType); err != nil {
 			return match
 		}
+		keys = append(keys, key)
 		url := s.s3PublicURL(key)
 		return fmt.Sprintf("![%s](%s)", alt, url)
 	})
 
-	return result
+	return result, keys
 }
 
-func (s *Service) CreatePost(ctx context.Context, title, slug, content string) (*Post, error) {
-	s3Key := fmt.Sprintf("posts/%s.md", slug)
-	post, err := s.repo.Create(ctx, title, slug, s3Key)
+// embedImages applies the image embed mode, then external image rehosting,
+// to content about to be stored. It returns the content to store and the keys
+// of any images uploaded.
+func (s *Service) embedImages(ctx context.Context, format Format, slug, content string) (string, []string, error) {
+	if !format.isMarkdown() {
+		return content, nil, nil
+	}
+	var keys []string
+	switch s.imageEmbedMode {
+	case ImageEmbedReject:
+		if anyDataURLImageRegex.MatchString(content) {
+			return "", nil, ErrEmbeddedImages
+		}
+	case ImageEmbedIgnore:
+	default:
+		content, keys = s.processMarkdownImages(ctx, slug, content)
+	}
+	if s.imageFetcher != nil {
+		var rehosted []string
+		content, rehosted = s.rehostExternalImages(ctx, slug, content)
+		keys = append(keys, rehosted...)
+	}
+	return content, keys, nil
+}
+
+// deleteImages removes images uploaded for a write that did not go through.
+// Failures are logged; the objects are then orphaned but harmless.
+func (s *Service) deleteImages(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			s.logger.Warn("failed to delete orphaned image", "key", key, "error", err)
+		}
+	}
+}
+
+func contentChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkHeadings applies ValidateHeadings to markdown content in strict mode.
+func (s *Service) checkHeadings(format Format, content string) error {
+	if !s.strictHeadings || !format.isMarkdown() {
+		return nil
+	}
+	if problems := ValidateHeadings(content); len(problems) > 0 {
+		return &HeadingsError{Problems: problems}
+	}
+	return nil
+}
+
+// CreatePost stores a new draft. Embedded images are only extracted from
+// markdown content.
+func (s *Service) CreatePost(ctx context.Context, in CreatePostInput) (*Post, error) {
+	format := in.Format
+	if format == "" {
+		format = FormatMarkdown
+	}
+	if !format.Valid() {
+		return nil, ErrInvalidFormat
+	}
+	content := in.Content
+	if err := s.checkHeadings(format, content); err != nil {
+		return nil, err
+	}
+	s3Key := fmt.Sprintf("posts/%s.md", in.Slug)
+	content, images, err := s.embedImages(ctx, format, in.Slug, content)
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.repo.Create(ctx, CreateParams{
+		Title:            in.Title,
+		Slug:             in.Slug,
+		S3Key:            s3Key,
+		Format:           format,
+		Tags:             NormalizeTags(in.Tags),
+		ContentSHA256:    contentChecksum(content),
+		CanonicalURL:     in.CanonicalURL,
+		CreatedAt:        in.CreatedAt,
+		RawContentSHA256: contentChecksum(in.Content),
+	})
 	if err != nil {
+		// A concurrent create may have taken the slug; the images uploaded
+		// for this attempt belong to no post.
+		s.deleteImages(ctx, images)
 		return nil, err
 	}
 
-	content = s.processMarkdownImages(ctx, slug, content)
-	if err := s.storage.Upload(ctx, s3Key, strings.NewReader(content), "text/markdown"); err != nil {
-		_ = s.repo.Delete(ctx, slug)
+	if err := s.storage.Upload(ctx, s3Key, strings.NewReader(content), format.ContentType()); err != nil {
+		_ = s.repo.Delete(ctx, in.Slug)
+		s.deleteImages(ctx, images)
 		return nil, fmt.Errorf("upload to s3: %w", err)
 	}
 
@@ -115,12 +317,183 @@ func (s *Service) GetPostBySlug(ctx context.Context, slug string) (*Post, error)
 	return s.repo.GetBySlug(ctx, slug)
 }
 
-func (s *Service) GetPostContent(ctx context.Context, slug string) ([]byte, error) {
+func (s *Service) GetPostByID(ctx context.Context, id uuid.UUID) (*Post, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// GetPostWithNav returns a post along with its chronological published
+// neighbours.
+func (s *Service) GetPostWithNav(ctx context.Context, slug string) (*PostNav, error) {
+	post, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	prev, next, err := s.repo.Adjacent(ctx, post)
+	if err != nil {
+		return nil, err
+	}
+	return &PostNav{Post: post, PrevSlug: prev, NextSlug: next}, nil
+}
+
+// GetPostContent returns a post's content as served to readers, with image
+// URLs signed when an ImageURLSigner is configured.
+func (s *Service) GetPostContent(ctx context.Context, slug string) (*PostContent, error) {
+	post, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.rawContent(ctx, post)
+	if err == ErrNotFound {
+		if err := s.contentMissingFor(post); err != nil {
+			return nil, err
+		}
+		placeholder := []byte(s.placeholder)
+		return &PostContent{Post: post, Body: placeholder, ETag: ContentETag(placeholder), Placeholder: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.views != nil && post.Status == Published {
+		s.views.Record(post.ID)
+	}
+	return &PostContent{
+		Post: post,
+		Body: s.signImageURLs(ctx, data),
+		ETag: ContentETag(data),
+	}, nil
+}
+
+// GetPostContentInfo describes a post's content for HEAD requests. The ETag
+// comes from the stored checksum and the size from the storage backend, so
+// the body is only downloaded for posts saved before checksums were recorded.
+// Unlike GetPostContent, it does not count a view.
+func (s *Service) GetPostContentInfo(ctx context.Context, slug string) (*ContentInfo, error) {
+	post, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	info := &ContentInfo{Post: post, Size: -1}
+	var size int64
+	if post.ContentSHA256 == "" {
+		var data []byte
+		data, err = s.rawContent(ctx, post)
+		if err == nil {
+			info.ETag = ContentETag(data)
+			size = int64(len(data))
+		}
+	} else {
+		size, err = s.storage.Size(ctx, post.S3Key)
+		if err == storage.ErrNotFound {
+			err = ErrNotFound
+		} else if err != nil {
+			return nil, fmt.Errorf("stat content: %w", err)
+		}
+		info.ETag = `"` + post.ContentSHA256 + `"`
+	}
+	if err == ErrNotFound {
+		if err := s.contentMissingFor(post); err != nil {
+			return nil, err
+		}
+		return &ContentInfo{Post: post, ETag: ContentETag([]byte(s.placeholder)), Size: int64(len(s.placeholder)), Placeholder: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.imageURLSigner == nil {
+		info.Size = size
+	}
+	return info, nil
+}
+
+// contentMissingFor logs that post's content object is gone and returns
+// ErrNotFound unless a placeholder should be served instead.
+func (s *Service) contentMissingFor(post *Post) error {
+	s.logger.Warn("post content missing", "slug", post.Slug, "key", post.S3Key, "mode", s.contentMissing)
+	if s.contentMissing == ContentMissingPlaceholder {
+		return nil
+	}
+	return ErrNotFound
+}
+
+// excerptLength caps RenderedPost.Excerpt, around what link previews show.
+const excerptLength = 200
+
+// RenderPost returns a post's content rendered to HTML. Markdown is rendered
+// with RenderMarkdown; other formats are shown preformatted. Renderings are
+// cached unless image URLs are signed, since signed URLs expire.
+func (s *Service) RenderPost(ctx context.Context, slug string) (*RenderedPost, error) {
 	post, err := s.repo.GetBySlug(ctx, slug)
 	if err != nil {
 		return nil, err
 	}
+	data, err := s.rawContent(ctx, post)
+	if err != nil {
+		return nil, err
+	}
+	data = s.signImageURLs(ctx, data)
+	out := &RenderedPost{Post: post}
+	if post.Format.isMarkdown() {
+		out.CoverImage = CoverImage(string(data))
+		out.Excerpt = Excerpt(string(data), excerptLength)
+	}
+	cacheable := s.imageURLSigner == nil
+	if cached, ok := s.contentCache.get(contentKindHTML, post); ok && cacheable {
+		out.HTML = string(cached)
+		return out, nil
+	}
+	if post.Format.isMarkdown() {
+		out.HTML = RenderMarkdown(string(data))
+	} else {
+		out.HTML = RenderPlainText(string(data))
+	}
+	if cacheable {
+		s.contentCache.add(contentKindHTML, post, []byte(out.HTML))
+	}
+	return out, nil
+}
+
+// rawContent returns a post's stored content, from the content cache when
+// possible.
+func (s *Service) rawContent(ctx context.Context, post *Post) ([]byte, error) {
+	if data, ok := s.contentCache.get(contentKindRaw, post); ok {
+		return data, nil
+	}
+	// Concurrent requests for the same version of an object share one
+	// download. It runs without the first caller's cancellation, since other
+	// callers may still be waiting for it, but with its own deadline.
+	v, err, _ := s.downloads.Do(downloadKey(post), func() (any, error) {
+		dlCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedDownloadTimeout)
+		defer cancel()
+		data, err := s.downloadContent(dlCtx, post)
+		if err != nil {
+			return nil, err
+		}
+		s.contentCache.add(contentKindRaw, post, data)
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// downloadKey identifies the version of post's content a download is for, so
+// a caller that has seen an update never shares a download started before it.
+func downloadKey(post *Post) string {
+	return post.S3Key + "@" + post.ContentSHA256 + "@" + strconv.FormatInt(post.UpdatedAt.UnixNano(), 10)
+}
+
+func (s *Service) downloadContent(ctx context.Context, post *Post) ([]byte, error) {
 	body, err := s.storage.Download(ctx, post.S3Key)
+	for backoff := consistencyRetryBackoff; err == storage.ErrNotFound && s.now().Sub(post.UpdatedAt) < s.consistencyWindow; backoff *= 2 {
+		// The post was just written; its object may not be visible yet.
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		body, err = s.storage.Download(ctx, post.S3Key)
+	}
 	if err != nil {
 		if err == storage.ErrNotFound {
 			return nil, ErrNotFound
@@ -131,26 +504,38 @@ func (s *Service) GetPostContent(ctx context.Context, slug string) ([]byte, erro
 	return io.ReadAll(body)
 }
 
-func (s *Service) ListPosts(ctx context.Context, page, perPage int, status *Status) (*ListResult, error) {
+// signImageURLs rewrites stored image URLs to presigned ones. URLs that fail
+// to sign are left unchanged.
+func (s *Service) signImageURLs(ctx context.Context, content []byte) []byte {
+	if s.imageURLSigner == nil {
+		return content
+	}
+	return s.imageURLRegex.ReplaceAllFunc(content, func(match []byte) []byte {
+		key := string(s.imageURLRegex.FindSubmatch(match)[1])
+		signed, err := s.imageURLSigner.SignedURL(ctx, key, s.signedURLTTL)
+		if err != nil {
+			s.logger.Warn("failed to sign image URL", "key", key, "error", err)
+			return match
+		}
+		return []byte(signed)
+	})
+}
+
+// ListPosts returns a page of posts with any of statuses, or of every status
+// when empty. Sorting by views only considers published posts, regardless of
+// statuses.
+func (s *Service) ListPosts(ctx context.Context, page, perPage int, statuses []Status, sort Sort) (*ListResult, error) {
+	if sort == SortViews {
+		statuses = []Status{Published}
+	}
 	if page < 1 {
 		page = 1
 	}
-	if perPage < 1 || perPage > 100 {
-		perPage = 20
+	if perPage < 1 || perPage > MaxPerPage {
+		perPage = DefaultPerPage
 	}
 
-	offset := (page - 1) * perPage
-
-	posts, err := s.repo.List(ctx, ListParams{
-		Limit:  perPage,
-		Offset: offset,
-		Status: status,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	total, err := s.repo.Count(ctx, status)
+	total, err := s.repo.Count(ctx, statuses)
 	if err != nil {
 		return nil, err
 	}
@@ -159,6 +544,31 @@ func (s *Service) ListPosts(ctx context.Context, page, perPage int, status *Stat
 	if int(total)%perPage > 0 {
 		totalPages++
 	}
+	// An empty listing still has a first page.
+	if lastPage := max(totalPages, 1); page > lastPage {
+		switch s.pageOutOfRange {
+		case PageOutOfRangeClamp:
+			page = lastPage
+		case PageOutOfRangeFail:
+			return nil, &PageOutOfRangeError{Page: page, LastPage: lastPage}
+		}
+	}
+
+	offset := (page - 1) * perPage
+
+	posts, err := s.repo.List(ctx, ListParams{
+		Limit:    perPage,
+		Offset:   offset,
+		Statuses: statuses,
+		Sort:     sort,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if posts == nil {
+		// Serialize as "data": [] rather than null.
+		posts = []*Post{}
+	}
 
 	return &ListResult{
 		Posts:      posts,
@@ -166,14 +576,87 @@ func (s *Service) ListPosts(ctx context.Context, page, perPage int, status *Stat
 		Page:       page,
 		PerPage:    perPage,
 		TotalPages: totalPages,
+		HasNext:    page < totalPages,
+		HasPrev:    page > 1,
 	}, nil
 }
 
-func (s *Service) UpdatePost(ctx context.Context, currentSlug string, title, newSlug, content *string) (*Post, error) {
+// ListTags returns every tag with the number of posts using it, most used
+// first. A nil status counts posts of any status.
+func (s *Service) ListTags(ctx context.Context, status *Status) ([]TagCount, error) {
+	return s.repo.ListTags(ctx, status)
+}
+
+// SlugsExist reports for each of slugs whether a post uses it, with a single
+// repository query.
+func (s *Service) SlugsExist(ctx context.Context, slugs []string) (map[string]bool, error) {
+	found, err := s.repo.ExistingSlugs(ctx, slugs)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		exists[slug] = false
+	}
+	for _, slug := range found {
+		exists[slug] = true
+	}
+	return exists, nil
+}
+
+func (s *Service) UpdatePost(ctx context.Context, currentSlug string, in UpdatePostInput) (*UpdateResult, error) {
 	post, err := s.repo.GetBySlug(ctx, currentSlug)
 	if err != nil {
 		return nil, err
 	}
+	return s.updatePost(ctx, post, in, nil)
+}
+
+// UpdatePostByID is UpdatePost keyed by the post's stable ID, so a client
+// holding an ID is unaffected by concurrent slug renames.
+func (s *Service) UpdatePostByID(ctx context.Context, id uuid.UUID, in UpdatePostInput) (*UpdateResult, error) {
+	post, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.updatePost(ctx, post, in, nil)
+}
+
+// updatePost applies in to post. A non-nil expectSHA256 makes the write fail
+// with ErrPreconditionFailed if the stored checksum has changed since.
+func (s *Service) updatePost(ctx context.Context, post *Post, in UpdatePostInput, expectSHA256 *string) (*UpdateResult, error) {
+	currentSlug := post.Slug
+	title, newSlug, content := in.Title, in.Slug, in.Content
+	if content != nil {
+		if err := s.checkHeadings(post.Format, *content); err != nil {
+			return nil, err
+		}
+	}
+	tags := post.Tags
+	if in.Tags != nil {
+		tags = NormalizeTags(in.Tags)
+	}
+	canonicalURL := post.CanonicalURL
+	if in.CanonicalURL != nil {
+		canonicalURL = in.CanonicalURL
+		if *canonicalURL == "" {
+			canonicalURL = nil
+		}
+	}
+
+	// Autosaving editors resend unchanged content, either as stored or as they
+	// last sent it before image processing; skip the upload, and the write
+	// entirely when nothing else changed either.
+	rawChecksum := post.RawContentSHA256
+	if content != nil {
+		rawChecksum = contentChecksum(*content)
+	}
+	if content != nil && (rawChecksum == post.ContentSHA256 || rawChecksum == post.RawContentSHA256) {
+		content = nil
+		if (title == nil || *title == post.Title) && (newSlug == nil || *newSlug == post.Slug) && slices.Equal(tags, post.Tags) && sameString(canonicalURL, post.CanonicalURL) {
+			return &UpdateResult{Post: post, NotModified: true}, nil
+		}
+	}
 
 	titleToUse := post.Title
 	if title != nil {
@@ -183,12 +666,36 @@ func (s *Service) UpdatePost(ctx context.Context, currentSlug string, title, new
 	if newSlug != nil {
 		slugToUse = *newSlug
 	}
+	// The database rejects a taken slug only after content has moved; check
+	// the target key first so another post's content is never overwritten.
+	if slugToUse != currentSlug {
+		targetKey := fmt.Sprintf("posts/%s.md", slugToUse)
+		if targetKey != post.S3Key {
+			exists, err := s.storage.Exists(ctx, targetKey)
+			if err != nil {
+				return nil, fmt.Errorf("check content key: %w", err)
+			}
+			if exists {
+				return nil, fmt.Errorf("content key %s is in use: %w", targetKey, ErrSlugExists)
+			}
+		}
+	}
 
-	var s3Key string
+	var (
+		s3Key  string
+		images []string
+	)
+	checksum := post.ContentSHA256
 	if content != nil {
-		processed := s.processMarkdownImages(ctx, slugToUse, *content)
+		processed, uploaded, err := s.embedImages(ctx, post.Format, slugToUse, *content)
+		if err != nil {
+			return nil, err
+		}
+		images = uploaded
+		checksum = contentChecksum(processed)
 		s3Key = fmt.Sprintf("posts/%s.md", slugToUse)
-		if err := s.storage.Upload(ctx, s3Key, strings.NewReader(processed), "text/markdown"); err != nil {
+		if err := s.storage.Upload(ctx, s3Key, strings.NewReader(processed), post.Format.ContentType()); err != nil {
+			s.deleteImages(ctx, images)
 			return nil, fmt.Errorf("upload to s3: %w", err)
 		}
 		if currentSlug != slugToUse {
@@ -202,12 +709,14 @@ func (s *Service) UpdatePost(ctx context.Context, currentSlug string, title, new
 			if err != nil {
 				return nil, fmt.Errorf("download current content: %w", err)
 			}
-			defer body.Close()
+			// Close before uploading: a storage concurrency limit may hold
+			// a slot for the open body.
 			data, err := io.ReadAll(body)
+			body.Close()
 			if err != nil {
 				return nil, fmt.Errorf("read content: %w", err)
 			}
-			if err := s.storage.Upload(ctx, newKey, bytes.NewReader(data), "text/markdown"); err != nil {
+			if err := s.storage.Upload(ctx, newKey, bytes.NewReader(data), post.Format.ContentType()); err != nil {
 				return nil, fmt.Errorf("upload to s3: %w", err)
 			}
 			_ = s.storage.Delete(ctx, post.S3Key)
@@ -217,7 +726,163 @@ func (s *Service) UpdatePost(ctx context.Context, currentSlug string, title, new
 		}
 	}
 
-	return s.repo.Update(ctx, post.ID, titleToUse, slugToUse, s3Key)
+	updated, err := s.repo.Update(ctx, UpdateParams{
+		ID:                    post.ID,
+		Title:                 titleToUse,
+		Slug:                  slugToUse,
+		S3Key:                 s3Key,
+		Tags:                  tags,
+		ContentSHA256:         checksum,
+		CanonicalURL:          canonicalURL,
+		RawContentSHA256:      rawChecksum,
+		ExpectedContentSHA256: expectSHA256,
+	})
+	s.contentCache.invalidate(currentSlug)
+	s.contentCache.invalidate(slugToUse)
+	if err != nil {
+		// The row was not updated, so on a lost slug race or precondition the
+		// images uploaded for this attempt belong to no post.
+		if errors.Is(err, ErrSlugExists) || errors.Is(err, ErrPreconditionFailed) {
+			s.deleteImages(ctx, images)
+		}
+		return nil, err
+	}
+	return &UpdateResult{Post: updated}, nil
+}
+
+// sameString reports whether a and b are both nil or point at equal strings.
+func sameString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// ContentETag returns the strong ETag for a post's content.
+func ContentETag(content []byte) string {
+	return `"` + contentChecksum(string(content)) + `"`
+}
+
+// UpdatePostContent replaces a post's content if the stored content still
+// matches one of ifMatch (ETags from ContentETag). An empty ifMatch skips the
+// check.
+func (s *Service) UpdatePostContent(ctx context.Context, slug, content string, ifMatch []string) (*UpdateResult, error) {
+	in := UpdatePostInput{Content: &content}
+	if len(ifMatch) == 0 {
+		return s.UpdatePost(ctx, slug, in)
+	}
+	post, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	// GetBySlug may be served from cache; check against the stored row, and
+	// make the write itself conditional on that row's checksum.
+	post, err = s.repo.GetByID(ctx, post.ID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.contentETag(ctx, post)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(ifMatch, current) {
+		return nil, ErrPreconditionFailed
+	}
+	return s.updatePost(ctx, post, in, &post.ContentSHA256)
+}
+
+// contentETag uses the stored checksum, hashing the object itself for posts
+// saved before checksums were recorded.
+func (s *Service) contentETag(ctx context.Context, post *Post) (string, error) {
+	if post.ContentSHA256 != "" {
+		return `"` + post.ContentSHA256 + `"`, nil
+	}
+	body, err := s.storage.Download(ctx, post.S3Key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("download from s3: %w", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("read content: %w", err)
+	}
+	return ContentETag(data), nil
+}
+
+// ReprocessImages extracts data-URL images still embedded in a post's stored
+// content and re-uploads the content if any were replaced. It returns the
+// number of images extracted, which is always zero for non-markdown posts.
+func (s *Service) ReprocessImages(ctx context.Context, slug string) (int, error) {
+	post, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil {
+		return 0, err
+	}
+	if !post.Format.isMarkdown() {
+		return 0, nil
+	}
+	body, err := s.storage.Download(ctx, post.S3Key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return 0, ErrNotFound
+		}
+		return 0, fmt.Errorf("download from s3: %w", err)
+	}
+	// Closed before the uploads below, as in updatePost.
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return 0, fmt.Errorf("read content: %w", err)
+	}
+
+	processed, images := s.processMarkdownImages(ctx, post.Slug, string(data))
+	if len(images) == 0 {
+		return 0, nil
+	}
+	if err := s.storage.Upload(ctx, post.S3Key, strings.NewReader(processed), post.Format.ContentType()); err != nil {
+		return 0, fmt.Errorf("upload to s3: %w", err)
+	}
+	_, err = s.repo.Update(ctx, UpdateParams{
+		ID:               post.ID,
+		Title:            post.Title,
+		Slug:             post.Slug,
+		S3Key:            post.S3Key,
+		Tags:             post.Tags,
+		ContentSHA256:    contentChecksum(processed),
+		CanonicalURL:     post.CanonicalURL,
+		RawContentSHA256: post.RawContentSHA256,
+	})
+	s.contentCache.invalidate(post.Slug)
+	if err != nil {
+		return 0, err
+	}
+	return len(images), nil
+}
+
+// DeletePlan lists the objects deleting a post would remove.
+type DeletePlan struct {
+	Slug       string   `json:"slug"`
+	ContentKey string   `json:"content_key"`
+	ImageKeys  []string `json:"image_keys"`
+}
+
+// PlanDeletePost reports what DeletePost would remove for slug without
+// deleting anything.
+func (s *Service) PlanDeletePost(ctx context.Context, slug string) (*DeletePlan, error) {
+	post, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.storage.List(ctx, imagesPrefix(post.Slug))
+	if err != nil {
+		return nil, fmt.Errorf("list images: %w", err)
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	return &DeletePlan{Slug: post.Slug, ContentKey: post.S3Key, ImageKeys: keys}, nil
 }
 
 func (s *Service) DeletePost(ctx context.Context, slug string) error {
@@ -230,21 +895,139 @@ func (s *Service) DeletePost(ctx context.Context, slug string) error {
 			return fmt.Errorf("delete from s3: %w", delErr)
 		}
 	}
-	imagesPrefix := fmt.Sprintf("posts/%s/images/", post.Slug)
-	if delErr := s.storage.DeletePrefix(ctx, imagesPrefix); delErr != nil {
+	if delErr := s.storage.DeletePrefix(ctx, imagesPrefix(post.Slug)); delErr != nil {
 		return fmt.Errorf("delete images from s3: %w", delErr)
 	}
+	s.contentCache.invalidate(post.Slug)
 	return s.repo.Delete(ctx, slug)
 }
 
+// imagesPrefix is where a post's uploaded and rehosted images are stored.
+func imagesPrefix(slug string) string {
+	return "posts/" + slug + "/images/"
+}
+
+// PublishPostWithUpdate applies optional title and content edits to a draft and
+// publishes it. The status only flips once the edits are stored, so a failed
+// upload leaves the post an unpublished draft and emits no event. The two steps
+// are not atomic: a publish that fails after the edits were stored returns an
+// error wrapping both ErrEditsSaved and the cause.
+func (s *Service) PublishPostWithUpdate(ctx context.Context, slug string, title, content *string) (*Post, error) {
+	post, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if post.Status == Published {
+		return nil, ErrNotFound
+	}
+	if title == nil && content == nil {
+		return s.publish(ctx, post, nil)
+	}
+	updated, err := s.UpdatePost(ctx, slug, UpdatePostInput{Title: title, Content: content})
+	if err != nil {
+		return nil, err
+	}
+	published, err := s.publish(ctx, updated.Post, content)
+	if errors.Is(err, ErrRevertFailed) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEditsSaved, err)
+	}
+	return published, nil
+}
+
 func (s *Service) PublishPost(ctx context.Context, slug string) (*Post, error) {
-	post, err := s.repo.Publish(ctx, slug)
+	post, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if post.Status == Published {
+		return nil, ErrNotFound
+	}
+	return s.publish(ctx, post, nil)
+}
+
+// publish publishes the draft and sends its post.published event. content,
+// when the caller has it, is the post's markdown for the event excerpt.
+func (s *Service) publish(ctx context.Context, draft *Post, content *string) (*Post, error) {
+	var (
+		evt events.PostPublished
+		msg outbox.Message
+	)
+	// Read before Publish so storage is not waited on while the row is locked.
+	excerpt := s.publishExcerpt(ctx, draft, content)
+	post, err := s.repo.Publish(ctx, draft.Slug, func(p *Post) (outbox.Message, error) {
+		evt = events.NewPostPublished(events.PostPublishedPayload{
+			PostID:  p.ID,
+			Slug:    p.Slug,
+			Title:   p.Title,
+			URL:     s.publicPostURL(p),
+			Excerpt: excerpt,
+		}, s.now())
+		var err error
+		msg, err = outbox.NewMessage(events.TypePostPublished, evt)
+		return msg, err
+	})
 	if err != nil {
 		return nil, err
 	}
-	evt := events.NewPostPublished(post.ID, post.Slug, post.Title)
-	if err := s.publisher.PublishPostPublished(ctx, evt); err != nil {
-		s.logger.Warn("failed to publish post.published event", "slug", post.Slug, "error", err)
+	// The status change is already committed, so the event must not be tied to
+	// the caller's lifetime: a client disconnect would otherwise drop it.
+	pubCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.eventTimeout)
+	defer cancel()
+	if err := s.publisher.PublishPostPublished(pubCtx, evt); err != nil {
+		if !s.requireEvent {
+			// The outbox relay will retry from the committed row.
+			s.logger.Warn("failed to publish post.published event", "slug", post.Slug, "error", err)
+			return post, nil
+		}
+		// Readers may have seen the post published in between; consumers
+		// have not, as the relay waits out its grace period first. pubCtx
+		// may have expired with the publish, so the revert gets its own.
+		revertCtx, cancelRevert := context.WithTimeout(context.WithoutCancel(ctx), revertPublishTimeout)
+		defer cancelRevert()
+		if rerr := s.repo.RevertPublish(revertCtx, post.ID, msg.ID); rerr != nil {
+			s.logger.Error("failed to revert publish after undelivered event; post left published", "slug", post.Slug, "error", rerr)
+			return nil, fmt.Errorf("%w: %v: %w", ErrRevertFailed, err, rerr)
+		}
+		s.logger.Warn("failed to publish post.published event; post reverted to draft", "slug", post.Slug, "error", err)
+		return nil, fmt.Errorf("%w: %v", ErrEventUndelivered, err)
+	}
+	if s.outbox != nil {
+		if err := s.outbox.MarkSent(pubCtx, msg.ID); err != nil {
+			s.logger.Warn("failed to mark outbox event sent", "id", msg.ID, "error", err)
+		}
 	}
 	return post, nil
 }
+
+// publishExcerpt returns the excerpt sent in the post.published event for
+// post, from content when given. The event is still sent without one when
+// the content cannot be read.
+func (s *Service) publishExcerpt(ctx context.Context, post *Post, content *string) string {
+	if post.Status == Published || !post.Format.isMarkdown() {
+		return ""
+	}
+	if content != nil {
+		return Excerpt(*content, excerptLength)
+	}
+	data, err := s.rawContent(ctx, post)
+	if err != nil {
+		s.logger.Warn("failed to read content for event excerpt", "slug", post.Slug, "error", err)
+		return ""
+	}
+	return Excerpt(string(data), excerptLength)
+}
+
+// publicPostURL returns the post's canonical URL override, or its URL from
+// PostURL, or empty.
+func (s *Service) publicPostURL(p *Post) string {
+	if p.CanonicalURL != nil {
+		return *p.CanonicalURL
+	}
+	if s.postURL != nil {
+		return s.postURL(p.Slug)
+	}
+	return ""
+}
@@ -3,28 +3,44 @@ package posts
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/events"
+	"github.com/jeremyjsx/entries/internal/outbox"
 	"github.com/jeremyjsx/entries/internal/storage"
+	"golang.org/x/sync/semaphore"
 )
 
 type mockRepo struct {
-	create    func(ctx context.Context, title, slug, s3Key string) (*Post, error)
+	create    func(ctx context.Context, p CreateParams) (*Post, error)
 	getBySlug func(ctx context.Context, slug string) (*Post, error)
+	getByID   func(ctx context.Context, id uuid.UUID) (*Post, error)
 	list      func(ctx context.Context, params ListParams) ([]*Post, error)
-	count     func(ctx context.Context, status *Status) (int64, error)
-	update    func(ctx context.Context, id uuid.UUID, title, slug, s3Key string) (*Post, error)
+	count     func(ctx context.Context, statuses []Status) (int64, error)
+	update    func(ctx context.Context, p UpdateParams) (*Post, error)
 	delete    func(ctx context.Context, slug string) error
 	publish   func(ctx context.Context, slug string) (*Post, error)
+	revert    func(ctx context.Context, id, eventID uuid.UUID) error
+	incViews  func(ctx context.Context, id uuid.UUID, delta int64) error
+	listTags  func(ctx context.Context, status *Status) ([]TagCount, error)
+	adjacent  func(ctx context.Context, post *Post) (string, string, error)
+	existing  func(ctx context.Context, slugs []string) ([]string, error)
 }
 
-func (m *mockRepo) Create(ctx context.Context, title, slug, s3Key string) (*Post, error) {
+func (m *mockRepo) Create(ctx context.Context, p CreateParams) (*Post, error) {
 	if m.create != nil {
-		return m.create(ctx, title, slug, s3Key)
+		return m.create(ctx, p)
 	}
 	return nil, nil
 }
@@ -36,6 +52,13 @@ func (m *mockRepo) GetBySlug(ctx context.Context, slug string) (*Post, error) {
 	return nil, ErrNotFound
 }
 
+func (m *mockRepo) GetByID(ctx context.Context, id uuid.UUID) (*Post, error) {
+	if m.getByID != nil {
+		return m.getByID(ctx, id)
+	}
+	return nil, ErrNotFound
+}
+
 func (m *mockRepo) List(ctx context.Context, params ListParams) ([]*Post, error) {
 	if m.list != nil {
 		return m.list(ctx, params)
@@ -43,16 +66,16 @@ func (m *mockRepo) List(ctx context.Context, params ListParams) ([]*Post, error)
 	return nil, nil
 }
 
-func (m *mockRepo) Count(ctx context.Context, status *Status) (int64, error) {
+func (m *mockRepo) Count(ctx context.Context, statuses []Status) (int64, error) {
 	if m.count != nil {
-		return m.count(ctx, status)
+		return m.count(ctx, statuses)
 	}
 	return 0, nil
 }
 
-func (m *mockRepo) Update(ctx context.Context, id uuid.UUID, title, slug, s3Key string) (*Post, error) {
+func (m *mockRepo) Update(ctx context.Context, p UpdateParams) (*Post, error) {
 	if m.update != nil {
-		return m.update(ctx, id, title, slug, s3Key)
+		return m.update(ctx, p)
 	}
 	return nil, nil
 }
@@ -64,19 +87,63 @@ func (m *mockRepo) Delete(ctx context.Context, slug string) error {
 	return nil
 }
 
-func (m *mockRepo) Publish(ctx context.Context, slug string) (*Post, error) {
-	if m.publish != nil {
-		return m.publish(ctx, slug)
+func (m *mockRepo) Publish(ctx context.Context, slug string, newEvent NewEventFunc) (*Post, error) {
+	if m.publish == nil {
+		return nil, nil
+	}
+	post, err := m.publish(ctx, slug)
+	if err != nil || post == nil || newEvent == nil {
+		return post, err
+	}
+	if _, err := newEvent(post); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (m *mockRepo) RevertPublish(ctx context.Context, id, eventID uuid.UUID) error {
+	if m.revert != nil {
+		return m.revert(ctx, id, eventID)
+	}
+	return nil
+}
+
+func (m *mockRepo) IncrementViews(ctx context.Context, id uuid.UUID, delta int64) error {
+	if m.incViews != nil {
+		return m.incViews(ctx, id, delta)
+	}
+	return nil
+}
+
+func (m *mockRepo) ListTags(ctx context.Context, status *Status) ([]TagCount, error) {
+	if m.listTags != nil {
+		return m.listTags(ctx, status)
+	}
+	return nil, nil
+}
+
+func (m *mockRepo) ExistingSlugs(ctx context.Context, slugs []string) ([]string, error) {
+	if m.existing != nil {
+		return m.existing(ctx, slugs)
 	}
 	return nil, nil
 }
 
+func (m *mockRepo) Adjacent(ctx context.Context, post *Post) (string, string, error) {
+	if m.adjacent != nil {
+		return m.adjacent(ctx, post)
+	}
+	return "", "", nil
+}
+
 type mockStorage struct {
 	upload       func(ctx context.Context, key string, body io.Reader, contentType string) error
 	download     func(ctx context.Context, key string) (io.ReadCloser, error)
 	delete       func(ctx context.Context, key string) error
 	deletePrefix func(ctx context.Context, prefix string) error
+	list         func(ctx context.Context, prefix string) ([]string, error)
 	exists       func(ctx context.Context, key string) (bool, error)
+	size         func(ctx context.Context, key string) (int64, error)
 }
 
 func (m *mockStorage) Upload(ctx context.Context, key string, body io.Reader, contentType string) error {
@@ -107,6 +174,13 @@ func (m *mockStorage) DeletePrefix(ctx context.Context, prefix string) error {
 	return nil
 }
 
+func (m *mockStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	if m.list != nil {
+		return m.list(ctx, prefix)
+	}
+	return nil, nil
+}
+
 func (m *mockStorage) Exists(ctx context.Context, key string) (bool, error) {
 	if m.exists != nil {
 		return m.exists(ctx, key)
@@ -114,6 +188,45 @@ func (m *mockStorage) Exists(ctx context.Context, key string) (bool, error) {
 	return false, nil
 }
 
+func (m *mockStorage) Size(ctx context.Context, key string) (int64, error) {
+	if m.size != nil {
+		return m.size(ctx, key)
+	}
+	return 0, storage.ErrNotFound
+}
+
+type mockPublisher struct {
+	publishPostPublished func(ctx context.Context, e events.PostPublished) error
+}
+
+func (m *mockPublisher) PublishPostPublished(ctx context.Context, e events.PostPublished) error {
+	if m.publishPostPublished != nil {
+		return m.publishPostPublished(ctx, e)
+	}
+	return nil
+}
+
+type mockOutbox struct {
+	marked []uuid.UUID
+}
+
+func (m *mockOutbox) ListUnsent(context.Context, time.Time, int) ([]outbox.Message, error) {
+	return nil, nil
+}
+
+func (m *mockOutbox) MarkSent(_ context.Context, id uuid.UUID) error {
+	m.marked = append(m.marked, id)
+	return nil
+}
+
+func (m *mockOutbox) MarkDead(context.Context, uuid.UUID, string) error {
+	return nil
+}
+
+func (m *mockOutbox) DeleteSent(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
 func mustUUID(s string) uuid.UUID {
 	id, err := uuid.Parse(s)
 	if err != nil {
@@ -127,9 +240,9 @@ func TestService_CreatePost(t *testing.T) {
 		ctx := context.Background()
 		want := &Post{ID: mustUUID("00000000-0000-0000-0000-000000000001"), Title: "Hi", Slug: "hi", S3Key: "posts/hi.md", Status: Draft}
 		repo := &mockRepo{
-			create: func(ctx context.Context, title, slug, s3Key string) (*Post, error) {
-				if title != "Hi" || slug != "hi" || s3Key != "posts/hi.md" {
-					t.Errorf("Create got title=%q slug=%q s3Key=%q", title, slug, s3Key)
+			create: func(ctx context.Context, p CreateParams) (*Post, error) {
+				if p.Title != "Hi" || p.Slug != "hi" || p.S3Key != "posts/hi.md" {
+					t.Errorf("Create got title=%q slug=%q s3Key=%q", p.Title, p.Slug, p.S3Key)
 				}
 				return want, nil
 			},
@@ -149,7 +262,7 @@ func TestService_CreatePost(t *testing.T) {
 			},
 		}
 		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "us-east-1"})
-		got, err := svc.CreatePost(ctx, "Hi", "hi", "# Hello")
+		got, err := svc.CreatePost(ctx, CreatePostInput{Title: "Hi", Slug: "hi", Content: "# Hello"})
 		if err != nil {
 			t.Fatalf("CreatePost: %v", err)
 		}
@@ -163,24 +276,53 @@ func TestService_CreatePost(t *testing.T) {
 
 	t.Run("repo returns ErrSlugExists", func(t *testing.T) {
 		ctx := context.Background()
-		repo := &mockRepo{create: func(context.Context, string, string, string) (*Post, error) { return nil, ErrSlugExists }}
+		repo := &mockRepo{create: func(context.Context, CreateParams) (*Post, error) { return nil, ErrSlugExists }}
 		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		_, err := svc.CreatePost(ctx, "T", "t", "body")
+		_, err := svc.CreatePost(ctx, CreatePostInput{Title: "T", Slug: "t", Content: "body"})
 		if !errors.Is(err, ErrSlugExists) {
 			t.Errorf("got err %v", err)
 		}
 	})
 
+	t.Run("ErrSlugExists deletes uploaded images", func(t *testing.T) {
+		ctx := context.Background()
+		repo := &mockRepo{create: func(context.Context, CreateParams) (*Post, error) { return nil, ErrSlugExists }}
+		var uploaded, deleted []string
+		st := &mockStorage{
+			upload: func(_ context.Context, key string, _ io.Reader, _ string) error {
+				uploaded = append(uploaded, key)
+				return nil
+			},
+			delete: func(_ context.Context, key string) error {
+				deleted = append(deleted, key)
+				return nil
+			},
+		}
+		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		b64 := "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg=="
+		content := "![a](data:image/png;base64," + b64 + ")\n![b](data:image/png;base64," + b64 + ")"
+		_, err := svc.CreatePost(ctx, CreatePostInput{Title: "T", Slug: "t", Content: content})
+		if !errors.Is(err, ErrSlugExists) {
+			t.Fatalf("got err %v", err)
+		}
+		if len(uploaded) != 2 {
+			t.Fatalf("uploaded %v, want two images", uploaded)
+		}
+		if !slices.Equal(deleted, uploaded) {
+			t.Errorf("deleted %v, want %v", deleted, uploaded)
+		}
+	})
+
 	t.Run("storage upload fails", func(t *testing.T) {
 		ctx := context.Background()
-		repo := &mockRepo{create: func(context.Context, string, string, string) (*Post, error) {
+		repo := &mockRepo{create: func(context.Context, CreateParams) (*Post, error) {
 			return &Post{ID: uuid.New(), Slug: "x"}, nil
 		}}
 		st := &mockStorage{upload: func(context.Context, string, io.Reader, string) error {
 			return errors.New("upload failed")
 		}}
 		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		_, err := svc.CreatePost(ctx, "T", "x", "body")
+		_, err := svc.CreatePost(ctx, CreatePostInput{Title: "T", Slug: "x", Content: "body"})
 		if err == nil || !strings.Contains(err.Error(), "upload to s3") {
 			t.Errorf("got err %v", err)
 		}
@@ -223,12 +365,12 @@ func TestService_GetPostContent(t *testing.T) {
 			return io.NopCloser(strings.NewReader("markdown here")), nil
 		}}
 		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		body, err := svc.GetPostContent(ctx, "a")
+		got, err := svc.GetPostContent(ctx, "a")
 		if err != nil {
 			t.Fatalf("GetPostContent: %v", err)
 		}
-		if string(body) != "markdown here" {
-			t.Errorf("got body %q", body)
+		if string(got.Body) != "markdown here" {
+			t.Errorf("got body %q", got.Body)
 		}
 	})
 
@@ -258,16 +400,213 @@ func TestService_GetPostContent(t *testing.T) {
 	})
 }
 
+func TestService_GetPostContent_coalescesDownloads(t *testing.T) {
+	const n = 10
+	var fetched sync.WaitGroup
+	fetched.Add(n)
+	repo := &mockRepo{getBySlug: func(context.Context, string) (*Post, error) {
+		fetched.Done()
+		return &Post{Slug: "a", S3Key: "posts/a.md"}, nil
+	}}
+	release := make(chan struct{})
+	var downloads atomic.Int32
+	st := &mockStorage{download: func(context.Context, string) (io.ReadCloser, error) {
+		downloads.Add(1)
+		<-release
+		return io.NopCloser(strings.NewReader("viral")), nil
+	}}
+	svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := svc.GetPostContent(context.Background(), "a")
+			if err != nil {
+				t.Errorf("GetPostContent: %v", err)
+				return
+			}
+			bodies[i] = string(got.Body)
+		}()
+	}
+	// Every request has looked up the post; give them a moment to join the
+	// download in flight before letting it finish.
+	fetched.Wait()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := downloads.Load(); got != 1 {
+		t.Errorf("downloads = %d, want 1", got)
+	}
+	for i, body := range bodies {
+		if body != "viral" {
+			t.Errorf("request %d got body %q", i, body)
+		}
+	}
+}
+
+func TestService_GetPostContent_downloadPerVersion(t *testing.T) {
+	version := "v1"
+	var mu sync.Mutex
+	repo := &mockRepo{getBySlug: func(context.Context, string) (*Post, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return &Post{Slug: "a", S3Key: "posts/a.md", ContentSHA256: version}, nil
+	}}
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	var downloads atomic.Int32
+	st := &mockStorage{download: func(ctx context.Context, _ string) (io.ReadCloser, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("shared download has no deadline")
+		}
+		n := downloads.Add(1)
+		started <- struct{}{}
+		if n == 1 {
+			<-release
+			return io.NopCloser(strings.NewReader("old")), nil
+		}
+		return io.NopCloser(strings.NewReader("new")), nil
+	}}
+	svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+
+	done := make(chan string)
+	go func() {
+		got, err := svc.GetPostContent(context.Background(), "a")
+		if err != nil {
+			t.Errorf("GetPostContent: %v", err)
+		}
+		done <- string(got.Body)
+	}()
+	<-started
+	// The content changes while the first download is in flight; a reader of
+	// the new version must not be handed the old bytes.
+	mu.Lock()
+	version = "v2"
+	mu.Unlock()
+	got, err := svc.GetPostContent(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("GetPostContent: %v", err)
+	}
+	if string(got.Body) != "new" {
+		t.Errorf("body = %q, want new", got.Body)
+	}
+	close(release)
+	if old := <-done; old != "old" {
+		t.Errorf("first body = %q, want old", old)
+	}
+}
+
+func TestService_GetPostContent_consistencyWindow(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		updatedAt time.Time
+		window    time.Duration
+		wantErr   error
+		wantCalls int
+	}{
+		{"recent post retried", now.Add(-time.Second), time.Minute, nil, 2},
+		{"old post not retried", now.Add(-time.Hour), time.Minute, ErrNotFound, 1},
+		{"disabled", now, 0, ErrNotFound, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockRepo{getBySlug: func(context.Context, string) (*Post, error) {
+				return &Post{Slug: "a", S3Key: "posts/a.md", UpdatedAt: tt.updatedAt}, nil
+			}}
+			calls := 0
+			st := &mockStorage{download: func(context.Context, string) (io.ReadCloser, error) {
+				calls++
+				if calls == 1 {
+					return nil, storage.ErrNotFound
+				}
+				return io.NopCloser(strings.NewReader("fresh")), nil
+			}}
+			svc := NewService(repo, st, nil, nil, ServiceConfig{
+				S3Bucket:          "b",
+				AWSRegion:         "r",
+				ConsistencyWindow: tt.window,
+				Now:               func() time.Time { return now },
+			})
+			got, err := svc.GetPostContent(context.Background(), "a")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && string(got.Body) != "fresh" {
+				t.Errorf("body %q", got.Body)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("downloads = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+type fakeSigner struct {
+	ttls []time.Duration
+}
+
+func (f *fakeSigner) SignedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
+	f.ttls = append(f.ttls, ttl)
+	if strings.Contains(key, "broken") {
+		return "", errors.New("sign failed")
+	}
+	return "https://signed.example/" + key + "?sig=1", nil
+}
+
+func TestService_GetPostContent_signsImageURLs(t *testing.T) {
+	ctx := context.Background()
+	stored := "# Hi\n" +
+		"![a](https://b.s3.r.amazonaws.com/posts/a/images/one.png)\n" +
+		"![b](https://b.s3.r.amazonaws.com/posts/a/images/broken.png)\n" +
+		"![c](https://elsewhere.example/posts/a/images/two.png)\n"
+	repo := &mockRepo{getBySlug: func(context.Context, string) (*Post, error) {
+		return &Post{Slug: "a", S3Key: "posts/a.md"}, nil
+	}}
+	st := &mockStorage{download: func(context.Context, string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(stored)), nil
+	}}
+	signer := &fakeSigner{}
+	svc := NewService(repo, st, nil, nil, ServiceConfig{
+		S3Bucket:       "b",
+		AWSRegion:      "r",
+		ImageURLSigner: signer,
+		SignedURLTTL:   time.Hour,
+	})
+
+	got, err := svc.GetPostContent(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetPostContent: %v", err)
+	}
+	want := "# Hi\n" +
+		"![a](https://signed.example/posts/a/images/one.png?sig=1)\n" +
+		"![b](https://b.s3.r.amazonaws.com/posts/a/images/broken.png)\n" +
+		"![c](https://elsewhere.example/posts/a/images/two.png)\n"
+	if string(got.Body) != want {
+		t.Errorf("body =\n%s\nwant\n%s", got.Body, want)
+	}
+	if got.ETag != ContentETag([]byte(stored)) {
+		t.Errorf("ETag %s should be of the stored content", got.ETag)
+	}
+	if len(signer.ttls) != 2 || signer.ttls[0] != time.Hour {
+		t.Errorf("signer calls %v", signer.ttls)
+	}
+}
+
 func TestService_ListPosts(t *testing.T) {
 	t.Run("success and pagination", func(t *testing.T) {
 		ctx := context.Background()
 		posts := []*Post{{ID: uuid.New(), Slug: "one"}}
 		repo := &mockRepo{
 			list:  func(context.Context, ListParams) ([]*Post, error) { return posts, nil },
-			count: func(context.Context, *Status) (int64, error) { return 1, nil },
+			count: func(context.Context, []Status) (int64, error) { return 1, nil },
 		}
 		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		result, err := svc.ListPosts(ctx, 1, 10, nil)
+		result, err := svc.ListPosts(ctx, 1, 10, nil, SortNewest)
 		if err != nil {
 			t.Fatalf("ListPosts: %v", err)
 		}
@@ -285,10 +624,10 @@ func TestService_ListPosts(t *testing.T) {
 				}
 				return nil, nil
 			},
-			count: func(context.Context, *Status) (int64, error) { return 0, nil },
+			count: func(context.Context, []Status) (int64, error) { return 0, nil },
 		}
 		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		result, err := svc.ListPosts(ctx, 0, 0, nil)
+		result, err := svc.ListPosts(ctx, 0, 0, nil, SortNewest)
 		if err != nil {
 			t.Fatalf("ListPosts: %v", err)
 		}
@@ -298,6 +637,266 @@ func TestService_ListPosts(t *testing.T) {
 	})
 }
 
+func TestService_ListPosts_PageOutOfRange(t *testing.T) {
+	tests := []struct {
+		mode       PageOutOfRangeMode
+		total      int64
+		wantPage   int
+		wantOffset int
+		wantErr    *PageOutOfRangeError
+	}{
+		{mode: "", total: 15, wantPage: 9999, wantOffset: 99980},
+		{mode: PageOutOfRangeClamp, total: 15, wantPage: 2, wantOffset: 10},
+		{mode: PageOutOfRangeClamp, total: 0, wantPage: 1, wantOffset: 0},
+		{mode: PageOutOfRangeFail, total: 15, wantErr: &PageOutOfRangeError{Page: 9999, LastPage: 2}},
+		{mode: PageOutOfRangeFail, total: 0, wantErr: &PageOutOfRangeError{Page: 9999, LastPage: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.mode, tt.total), func(t *testing.T) {
+			repo := &mockRepo{
+				list: func(_ context.Context, p ListParams) ([]*Post, error) {
+					if tt.wantErr != nil {
+						t.Error("listed an out-of-range page")
+					}
+					if p.Offset != tt.wantOffset {
+						t.Errorf("offset %d, want %d", p.Offset, tt.wantOffset)
+					}
+					return nil, nil
+				},
+				count: func(context.Context, []Status) (int64, error) { return tt.total, nil },
+			}
+			svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r", PageOutOfRange: tt.mode})
+			result, err := svc.ListPosts(context.Background(), 9999, 10, nil, SortNewest)
+			if tt.wantErr != nil {
+				var got *PageOutOfRangeError
+				if !errors.As(err, &got) || *got != *tt.wantErr {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListPosts: %v", err)
+			}
+			if result.Page != tt.wantPage {
+				t.Errorf("page %d, want %d", result.Page, tt.wantPage)
+			}
+		})
+	}
+}
+
+func TestService_ListPosts_EmptyData(t *testing.T) {
+	repo := &mockRepo{
+		list:  func(context.Context, ListParams) ([]*Post, error) { return nil, nil },
+		count: func(context.Context, []Status) (int64, error) { return 0, nil },
+	}
+	svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+	result, err := svc.ListPosts(context.Background(), 1, 10, nil, SortNewest)
+	if err != nil {
+		t.Fatalf("ListPosts: %v", err)
+	}
+	out, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !strings.Contains(string(out), `"data":[]`) {
+		t.Errorf("got %s", out)
+	}
+}
+
+func TestService_ListPosts_HasNextHasPrev(t *testing.T) {
+	tests := []struct {
+		name               string
+		page               int
+		wantNext, wantPrev bool
+	}{
+		{"first", 1, true, false},
+		{"middle", 2, true, true},
+		{"last", 3, false, true},
+		{"past the end", 4, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockRepo{
+				list:  func(context.Context, ListParams) ([]*Post, error) { return nil, nil },
+				count: func(context.Context, []Status) (int64, error) { return 25, nil },
+			}
+			svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+			result, err := svc.ListPosts(context.Background(), tt.page, 10, nil, SortNewest)
+			if err != nil {
+				t.Fatalf("ListPosts: %v", err)
+			}
+			if result.HasNext != tt.wantNext || result.HasPrev != tt.wantPrev {
+				t.Errorf("has_next=%v has_prev=%v, want %v and %v", result.HasNext, result.HasPrev, tt.wantNext, tt.wantPrev)
+			}
+		})
+	}
+
+	t.Run("single page", func(t *testing.T) {
+		repo := &mockRepo{
+			list:  func(context.Context, ListParams) ([]*Post, error) { return nil, nil },
+			count: func(context.Context, []Status) (int64, error) { return 0, nil },
+		}
+		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		result, _ := svc.ListPosts(context.Background(), 1, 10, nil, SortNewest)
+		if result.HasNext || result.HasPrev {
+			t.Errorf("got %+v", result)
+		}
+	})
+}
+
+func TestService_UpdatePostByID(t *testing.T) {
+	postID := mustUUID("10000000-0000-0000-0000-000000000002")
+	// The post was renamed from "old" to "current" after the client read it;
+	// updating by ID must act on the current slug and key.
+	existing := &Post{ID: postID, Title: "T", Slug: "current", S3Key: "posts/current.md"}
+	var moved, deleted string
+	repo := &mockRepo{
+		getBySlug: func(context.Context, string) (*Post, error) {
+			t.Error("UpdatePostByID looked the post up by slug")
+			return nil, ErrNotFound
+		},
+		getByID: func(_ context.Context, id uuid.UUID) (*Post, error) {
+			if id != postID {
+				return nil, ErrNotFound
+			}
+			return existing, nil
+		},
+		update: func(_ context.Context, p UpdateParams) (*Post, error) {
+			if p.ID != postID || p.Slug != "renamed" || p.S3Key != "posts/renamed.md" {
+				t.Errorf("Update got id=%s slug=%q s3Key=%q", p.ID, p.Slug, p.S3Key)
+			}
+			return &Post{ID: p.ID, Title: p.Title, Slug: p.Slug, S3Key: p.S3Key}, nil
+		},
+	}
+	st := &mockStorage{
+		download: func(context.Context, string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("body")), nil
+		},
+		upload: func(_ context.Context, key string, _ io.Reader, _ string) error {
+			moved = key
+			return nil
+		},
+		delete: func(_ context.Context, key string) error {
+			deleted = key
+			return nil
+		},
+	}
+	svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+
+	slug := "renamed"
+	got, err := svc.UpdatePostByID(context.Background(), postID, UpdatePostInput{Slug: &slug})
+	if err != nil {
+		t.Fatalf("UpdatePostByID: %v", err)
+	}
+	if got.Slug != "renamed" || moved != "posts/renamed.md" || deleted != "posts/current.md" {
+		t.Errorf("slug %q, moved to %q, deleted %q", got.Slug, moved, deleted)
+	}
+
+	if _, err := svc.UpdatePostByID(context.Background(), uuid.New(), UpdatePostInput{Slug: &slug}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown id: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestService_UpdatePostContent_IfMatch(t *testing.T) {
+	postID := mustUUID("10000000-0000-0000-0000-000000000003")
+	// The cached row is stale; the check must use the stored one.
+	cached := &Post{ID: postID, Slug: "a", S3Key: "posts/a.md", ContentSHA256: "old"}
+	stored := &Post{ID: postID, Slug: "a", S3Key: "posts/a.md", ContentSHA256: "new"}
+	var expected *string
+	repo := &mockRepo{
+		getBySlug: func(context.Context, string) (*Post, error) { return cached, nil },
+		getByID:   func(context.Context, uuid.UUID) (*Post, error) { return stored, nil },
+		update: func(_ context.Context, p UpdateParams) (*Post, error) {
+			expected = p.ExpectedContentSHA256
+			return &Post{ID: p.ID, Slug: p.Slug, ContentSHA256: p.ContentSHA256}, nil
+		},
+	}
+	st := &mockStorage{upload: func(context.Context, string, io.Reader, string) error { return nil }}
+	svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+
+	if _, err := svc.UpdatePostContent(context.Background(), "a", "body", []string{`"old"`}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("stale etag: err = %v, want ErrPreconditionFailed", err)
+	}
+	if _, err := svc.UpdatePostContent(context.Background(), "a", "body", []string{`"new"`}); err != nil {
+		t.Fatalf("UpdatePostContent: %v", err)
+	}
+	if expected == nil || *expected != "new" {
+		t.Errorf("ExpectedContentSHA256 = %v, want new", expected)
+	}
+}
+
+func TestService_UpdatePost_targetKeyTaken(t *testing.T) {
+	for name, withContent := range map[string]bool{"rename": false, "rename with content": true} {
+		t.Run(name, func(t *testing.T) {
+			repo := &mockRepo{
+				getBySlug: func(context.Context, string) (*Post, error) {
+					return &Post{ID: uuid.New(), Title: "T", Slug: "old", S3Key: "posts/old.md"}, nil
+				},
+				update: func(context.Context, UpdateParams) (*Post, error) {
+					t.Error("repository updated despite the collision")
+					return nil, nil
+				},
+			}
+			st := &mockStorage{
+				exists: func(_ context.Context, key string) (bool, error) { return key == "posts/new.md", nil },
+				upload: func(_ context.Context, key string, _ io.Reader, _ string) error {
+					t.Errorf("uploaded %s despite the collision", key)
+					return nil
+				},
+				delete: func(_ context.Context, key string) error {
+					t.Errorf("deleted %s despite the collision", key)
+					return nil
+				},
+			}
+			svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+			slug, content := "new", "# New"
+			in := UpdatePostInput{Slug: &slug}
+			if withContent {
+				in.Content = &content
+			}
+			if _, err := svc.UpdatePost(context.Background(), "old", in); !errors.Is(err, ErrSlugExists) {
+				t.Fatalf("UpdatePost error = %v, want ErrSlugExists", err)
+			}
+		})
+	}
+}
+
+func TestService_UpdatePost_CanonicalURL(t *testing.T) {
+	original := "https://original.example/a"
+	replaced := "https://elsewhere.example/a"
+	empty := ""
+	tests := []struct {
+		name string
+		in   *string
+		want *string
+	}{
+		{"kept when absent", nil, &original},
+		{"replaced", &replaced, &replaced},
+		{"cleared by empty", &empty, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := &Post{ID: uuid.New(), Title: "T", Slug: "a", S3Key: "posts/a.md", CanonicalURL: &original}
+			var got *string
+			repo := &mockRepo{
+				getBySlug: func(context.Context, string) (*Post, error) { return existing, nil },
+				update: func(_ context.Context, p UpdateParams) (*Post, error) {
+					got = p.CanonicalURL
+					return &Post{ID: p.ID, Slug: p.Slug, CanonicalURL: p.CanonicalURL}, nil
+				},
+			}
+			svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+			title := "New"
+			if _, err := svc.UpdatePost(context.Background(), "a", UpdatePostInput{Title: &title, CanonicalURL: tt.in}); err != nil {
+				t.Fatalf("UpdatePost: %v", err)
+			}
+			if !sameString(got, tt.want) {
+				t.Errorf("canonical URL %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestService_UpdatePost(t *testing.T) {
 	postID := mustUUID("10000000-0000-0000-0000-000000000001")
 	existing := &Post{ID: postID, Title: "Old", Slug: "old", S3Key: "posts/old.md"}
@@ -307,15 +906,15 @@ func TestService_UpdatePost(t *testing.T) {
 		title := "New Title"
 		repo := &mockRepo{
 			getBySlug: func(context.Context, string) (*Post, error) { return existing, nil },
-			update: func(ctx context.Context, id uuid.UUID, title, slug, s3Key string) (*Post, error) {
-				if title != "New Title" || slug != "old" || s3Key != "posts/old.md" {
-					t.Errorf("Update got title=%q slug=%q s3Key=%q", title, slug, s3Key)
+			update: func(ctx context.Context, p UpdateParams) (*Post, error) {
+				if p.Title != "New Title" || p.Slug != "old" || p.S3Key != "posts/old.md" {
+					t.Errorf("Update got title=%q slug=%q s3Key=%q", p.Title, p.Slug, p.S3Key)
 				}
-				return &Post{ID: id, Title: title, Slug: slug, S3Key: s3Key}, nil
+				return &Post{ID: p.ID, Title: p.Title, Slug: p.Slug, S3Key: p.S3Key}, nil
 			},
 		}
 		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		got, err := svc.UpdatePost(ctx, "old", &title, nil, nil)
+		got, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Title: &title})
 		if err != nil {
 			t.Fatalf("UpdatePost: %v", err)
 		}
@@ -329,7 +928,7 @@ func TestService_UpdatePost(t *testing.T) {
 		repo := &mockRepo{getBySlug: func(context.Context, string) (*Post, error) { return nil, ErrNotFound }}
 		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
 		title := "X"
-		_, err := svc.UpdatePost(ctx, "x", &title, nil, nil)
+		_, err := svc.UpdatePost(ctx, "x", UpdatePostInput{Title: &title})
 		if !errors.Is(err, ErrNotFound) {
 			t.Errorf("got err %v", err)
 		}
@@ -344,11 +943,11 @@ func TestService_UpdatePost(t *testing.T) {
 		var uploadedContent []byte
 		repo := &mockRepo{
 			getBySlug: func(context.Context, string) (*Post, error) { return existing, nil },
-			update: func(ctx context.Context, id uuid.UUID, title, slug, s3Key string) (*Post, error) {
-				if title != "New" || slug != "new-slug" || s3Key != "posts/new-slug.md" {
-					t.Errorf("Update got title=%q slug=%q s3Key=%q", title, slug, s3Key)
+			update: func(ctx context.Context, p UpdateParams) (*Post, error) {
+				if p.Title != "New" || p.Slug != "new-slug" || p.S3Key != "posts/new-slug.md" {
+					t.Errorf("Update got title=%q slug=%q s3Key=%q", p.Title, p.Slug, p.S3Key)
 				}
-				return &Post{ID: id, Title: title, Slug: slug, S3Key: s3Key}, nil
+				return &Post{ID: p.ID, Title: p.Title, Slug: p.Slug, S3Key: p.S3Key}, nil
 			},
 		}
 		st := &mockStorage{
@@ -360,7 +959,7 @@ func TestService_UpdatePost(t *testing.T) {
 			delete: func(context.Context, string) error { return nil },
 		}
 		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		got, err := svc.UpdatePost(ctx, "old", &newTitle, &newSlug, &newContent)
+		got, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Title: &newTitle, Slug: &newSlug, Content: &newContent})
 		if err != nil {
 			t.Fatalf("UpdatePost: %v", err)
 		}
@@ -372,6 +971,109 @@ func TestService_UpdatePost(t *testing.T) {
 		}
 	})
 
+	t.Run("identical content is not re-uploaded", func(t *testing.T) {
+		ctx := context.Background()
+		content := "# Same"
+		stored := &Post{ID: postID, Title: "Old", Slug: "old", S3Key: "posts/old.md", ContentSHA256: contentChecksum(content)}
+		repo := &mockRepo{
+			getBySlug: func(context.Context, string) (*Post, error) { return stored, nil },
+			update: func(context.Context, UpdateParams) (*Post, error) {
+				t.Error("Update should not be called for an unchanged post")
+				return nil, nil
+			},
+		}
+		st := &mockStorage{upload: func(context.Context, string, io.Reader, string) error {
+			t.Error("Upload should not be called for unchanged content")
+			return nil
+		}}
+		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		got, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Content: &content})
+		if err != nil {
+			t.Fatalf("UpdatePost: %v", err)
+		}
+		if !got.NotModified || got.Post != stored {
+			t.Errorf("got %+v", got)
+		}
+	})
+
+	t.Run("content resent as last sent is not re-processed", func(t *testing.T) {
+		ctx := context.Background()
+		// The stored content has the image extracted; the editor resends its
+		// own copy with the data URL still in it.
+		content := "![a](data:image/png;base64,iVBORw0KGgo=)"
+		stored := &Post{ID: postID, Title: "Old", Slug: "old", S3Key: "posts/old.md",
+			ContentSHA256: contentChecksum("![a](https://cdn.example.com/posts/old/images/a.png)"), RawContentSHA256: contentChecksum(content)}
+		repo := &mockRepo{
+			getBySlug: func(context.Context, string) (*Post, error) { return stored, nil },
+			update: func(context.Context, UpdateParams) (*Post, error) {
+				t.Error("Update should not be called for an unchanged post")
+				return nil, nil
+			},
+		}
+		st := &mockStorage{upload: func(context.Context, string, io.Reader, string) error {
+			t.Error("Upload should not be called for unchanged content")
+			return nil
+		}}
+		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		got, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Content: &content})
+		if err != nil {
+			t.Fatalf("UpdatePost: %v", err)
+		}
+		if !got.NotModified {
+			t.Errorf("got %+v", got)
+		}
+	})
+
+	t.Run("tags replaced and normalized, title kept", func(t *testing.T) {
+		ctx := context.Background()
+		content := "# Same"
+		stored := &Post{ID: postID, Title: "Old", Slug: "old", S3Key: "posts/old.md", Tags: []string{"go"}, ContentSHA256: contentChecksum(content)}
+		var gotTags []string
+		repo := &mockRepo{
+			getBySlug: func(context.Context, string) (*Post, error) { return stored, nil },
+			update: func(_ context.Context, p UpdateParams) (*Post, error) {
+				gotTags = p.Tags
+				return &Post{ID: p.ID, Title: p.Title, Slug: p.Slug, Tags: p.Tags}, nil
+			},
+		}
+		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		got, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Content: &content, Tags: []string{"Web", "web", " api "}})
+		if err != nil {
+			t.Fatalf("UpdatePost: %v", err)
+		}
+		if got.NotModified || got.Title != "Old" || !slices.Equal(gotTags, []string{"web", "api"}) {
+			t.Errorf("got %+v, tags %q", got, gotTags)
+		}
+	})
+
+	t.Run("identical content with new title skips upload only", func(t *testing.T) {
+		ctx := context.Background()
+		content := "# Same"
+		title := "Renamed"
+		stored := &Post{ID: postID, Title: "Old", Slug: "old", S3Key: "posts/old.md", ContentSHA256: contentChecksum(content)}
+		repo := &mockRepo{
+			getBySlug: func(context.Context, string) (*Post, error) { return stored, nil },
+			update: func(_ context.Context, p UpdateParams) (*Post, error) {
+				if p.ContentSHA256 != stored.ContentSHA256 || p.S3Key != "posts/old.md" {
+					t.Errorf("Update got %+v", p)
+				}
+				return &Post{ID: p.ID, Title: p.Title, Slug: p.Slug, S3Key: p.S3Key, ContentSHA256: p.ContentSHA256}, nil
+			},
+		}
+		st := &mockStorage{upload: func(context.Context, string, io.Reader, string) error {
+			t.Error("Upload should not be called for unchanged content")
+			return nil
+		}}
+		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		got, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Title: &title, Content: &content})
+		if err != nil {
+			t.Fatalf("UpdatePost: %v", err)
+		}
+		if got.NotModified || got.Title != "Renamed" {
+			t.Errorf("got %+v", got)
+		}
+	})
+
 	t.Run("content upload fails", func(t *testing.T) {
 		ctx := context.Background()
 		content := "x"
@@ -380,12 +1082,73 @@ func TestService_UpdatePost(t *testing.T) {
 			return errors.New("upload failed")
 		}}
 		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		_, err := svc.UpdatePost(ctx, "old", nil, nil, &content)
+		_, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Content: &content})
 		if err == nil || !strings.Contains(err.Error(), "upload to s3") {
 			t.Errorf("got err %v", err)
 		}
 	})
 
+	t.Run("content upload fails deletes uploaded images", func(t *testing.T) {
+		ctx := context.Background()
+		content := "![a](data:image/png;base64,iVBORw0KGgo=)"
+		repo := &mockRepo{getBySlug: func(context.Context, string) (*Post, error) { return existing, nil }}
+		var images, deleted []string
+		st := &mockStorage{
+			upload: func(_ context.Context, key string, _ io.Reader, _ string) error {
+				if strings.HasPrefix(key, "posts/old/images/") {
+					images = append(images, key)
+					return nil
+				}
+				return errors.New("upload failed")
+			},
+			delete: func(_ context.Context, key string) error {
+				deleted = append(deleted, key)
+				return nil
+			},
+		}
+		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		if _, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Content: &content}); err == nil {
+			t.Fatal("expected error")
+		}
+		if len(images) != 1 || !slices.Equal(deleted, images) {
+			t.Errorf("uploaded %v, deleted %v", images, deleted)
+		}
+	})
+
+	t.Run("lost slug race deletes uploaded images", func(t *testing.T) {
+		ctx := context.Background()
+		content := "![a](data:image/png;base64,iVBORw0KGgo=)"
+		repo := &mockRepo{
+			getBySlug: func(context.Context, string) (*Post, error) { return existing, nil },
+			update: func(_ context.Context, p UpdateParams) (*Post, error) {
+				if p.RawContentSHA256 != contentChecksum(content) {
+					t.Errorf("RawContentSHA256 = %q, want the checksum of the content sent", p.RawContentSHA256)
+				}
+				return nil, ErrSlugExists
+			},
+		}
+		var images, deleted []string
+		st := &mockStorage{
+			upload: func(_ context.Context, key string, _ io.Reader, _ string) error {
+				if strings.HasPrefix(key, "posts/old/images/") {
+					images = append(images, key)
+				}
+				return nil
+			},
+			delete: func(_ context.Context, key string) error {
+				deleted = append(deleted, key)
+				return nil
+			},
+		}
+		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		if _, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Content: &content}); !errors.Is(err, ErrSlugExists) {
+			t.Fatalf("got err %v, want ErrSlugExists", err)
+		}
+		if len(images) != 1 || !slices.Equal(deleted, images) {
+			t.Errorf("uploaded %v, deleted %v", images, deleted)
+		}
+	})
+
 	t.Run("no content, slug change (move content)", func(t *testing.T) {
 		ctx := context.Background()
 		newSlug := "new-slug"
@@ -393,11 +1156,11 @@ func TestService_UpdatePost(t *testing.T) {
 		var uploadedKey string
 		repo := &mockRepo{
 			getBySlug: func(context.Context, string) (*Post, error) { return existing, nil },
-			update: func(ctx context.Context, id uuid.UUID, title, slug, s3Key string) (*Post, error) {
-				if s3Key != "posts/new-slug.md" {
-					t.Errorf("Update s3Key=%q", s3Key)
+			update: func(ctx context.Context, p UpdateParams) (*Post, error) {
+				if p.S3Key != "posts/new-slug.md" {
+					t.Errorf("Update s3Key=%q", p.S3Key)
 				}
-				return &Post{ID: id, Slug: slug, S3Key: s3Key}, nil
+				return &Post{ID: p.ID, Slug: p.Slug, S3Key: p.S3Key}, nil
 			},
 		}
 		st := &mockStorage{
@@ -414,7 +1177,7 @@ func TestService_UpdatePost(t *testing.T) {
 			delete: func(context.Context, string) error { return nil },
 		}
 		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		got, err := svc.UpdatePost(ctx, "old", nil, &newSlug, nil)
+		got, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Slug: &newSlug})
 		if err != nil {
 			t.Fatalf("UpdatePost: %v", err)
 		}
@@ -434,7 +1197,7 @@ func TestService_UpdatePost(t *testing.T) {
 			return nil, errors.New("download failed")
 		}}
 		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		_, err := svc.UpdatePost(ctx, "old", nil, &newSlug, nil)
+		_, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Slug: &newSlug})
 		if err == nil || !strings.Contains(err.Error(), "download current content") {
 			t.Errorf("got err %v", err)
 		}
@@ -444,15 +1207,15 @@ func TestService_UpdatePost(t *testing.T) {
 		ctx := context.Background()
 		repo := &mockRepo{
 			getBySlug: func(context.Context, string) (*Post, error) { return existing, nil },
-			update: func(ctx context.Context, id uuid.UUID, title, slug, s3Key string) (*Post, error) {
-				if s3Key != "posts/old.md" {
-					t.Errorf("expected s3Key posts/old.md, got %q", s3Key)
+			update: func(ctx context.Context, p UpdateParams) (*Post, error) {
+				if p.S3Key != "posts/old.md" {
+					t.Errorf("expected s3Key posts/old.md, got %q", p.S3Key)
 				}
-				return &Post{S3Key: s3Key}, nil
+				return &Post{S3Key: p.S3Key}, nil
 			},
 		}
 		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		got, err := svc.UpdatePost(ctx, "old", nil, nil, nil)
+		got, err := svc.UpdatePost(ctx, "old", UpdatePostInput{})
 		if err != nil {
 			t.Fatalf("UpdatePost: %v", err)
 		}
@@ -466,10 +1229,10 @@ func TestService_UpdatePost(t *testing.T) {
 		title := "X"
 		repo := &mockRepo{
 			getBySlug: func(context.Context, string) (*Post, error) { return existing, nil },
-			update:    func(context.Context, uuid.UUID, string, string, string) (*Post, error) { return nil, ErrSlugExists },
+			update:    func(context.Context, UpdateParams) (*Post, error) { return nil, ErrSlugExists },
 		}
 		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
-		_, err := svc.UpdatePost(ctx, "old", &title, nil, nil)
+		_, err := svc.UpdatePost(ctx, "old", UpdatePostInput{Title: &title})
 		if !errors.Is(err, ErrSlugExists) {
 			t.Errorf("got err %v", err)
 		}
@@ -507,11 +1270,150 @@ func TestService_DeletePost(t *testing.T) {
 	})
 }
 
+func TestService_ReprocessImages(t *testing.T) {
+	t.Run("extracts embedded images", func(t *testing.T) {
+		ctx := context.Background()
+		b64 := "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg=="
+		stored := "# Post\n\n![alt](data:image/png;base64," + b64 + ")"
+		var updated UpdateParams
+		repo := &mockRepo{
+			getBySlug: func(context.Context, string) (*Post, error) {
+				return &Post{ID: uuid.New(), Title: "Img", Slug: "img", S3Key: "posts/img.md"}, nil
+			},
+			update: func(_ context.Context, p UpdateParams) (*Post, error) {
+				updated = p
+				return &Post{ID: p.ID, Slug: p.Slug}, nil
+			},
+		}
+		uploaded := make(map[string][]byte)
+		st := &mockStorage{
+			download: func(context.Context, string) (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader(stored)), nil
+			},
+			upload: func(_ context.Context, key string, body io.Reader, _ string) error {
+				data, _ := io.ReadAll(body)
+				uploaded[key] = data
+				return nil
+			},
+		}
+		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		n, err := svc.ReprocessImages(ctx, "img")
+		if err != nil {
+			t.Fatalf("ReprocessImages: %v", err)
+		}
+		if n != 1 {
+			t.Errorf("extracted = %d, want 1", n)
+		}
+		markdown := string(uploaded["posts/img.md"])
+		if markdown == "" || strings.Contains(markdown, "data:image") {
+			t.Errorf("expected re-uploaded content without data URL, got %q", markdown)
+		}
+		if updated.ContentSHA256 != contentChecksum(markdown) {
+			t.Errorf("checksum not updated to match new content")
+		}
+	})
+
+	t.Run("nothing to extract", func(t *testing.T) {
+		ctx := context.Background()
+		repo := &mockRepo{
+			getBySlug: func(context.Context, string) (*Post, error) {
+				return &Post{Slug: "a", S3Key: "posts/a.md"}, nil
+			},
+			update: func(context.Context, UpdateParams) (*Post, error) {
+				t.Fatal("unexpected Update")
+				return nil, nil
+			},
+		}
+		st := &mockStorage{
+			download: func(context.Context, string) (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader("# plain")), nil
+			},
+			upload: func(context.Context, string, io.Reader, string) error {
+				t.Fatal("unexpected Upload")
+				return nil
+			},
+		}
+		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		n, err := svc.ReprocessImages(ctx, "a")
+		if err != nil || n != 0 {
+			t.Errorf("got (%d, %v), want (0, nil)", n, err)
+		}
+	})
+}
+
+// slotStorage allows one storage call at a time and holds the slot of a
+// download until its body is closed, like a limited S3 client.
+func slotStorage(sem *semaphore.Weighted, stored string) *mockStorage {
+	return &mockStorage{
+		download: func(ctx context.Context, _ string) (io.ReadCloser, error) {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return nil, err
+			}
+			return &releasingReader{Reader: strings.NewReader(stored), release: func() { sem.Release(1) }}, nil
+		},
+		upload: func(ctx context.Context, _ string, body io.Reader, _ string) error {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+			_, err := io.Copy(io.Discard, body)
+			return err
+		},
+	}
+}
+
+type releasingReader struct {
+	io.Reader
+	release func()
+}
+
+func (r *releasingReader) Close() error {
+	r.release()
+	return nil
+}
+
+func TestService_UploadsAfterDownloadWithOneSlot(t *testing.T) {
+	b64 := "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg=="
+	stored := "# Post\n\n![alt](data:image/png;base64," + b64 + ")"
+	repo := &mockRepo{
+		getBySlug: func(_ context.Context, slug string) (*Post, error) {
+			return &Post{ID: uuid.New(), Title: "Img", Slug: slug, S3Key: "posts/" + slug + ".md", Status: Draft}, nil
+		},
+		update: func(_ context.Context, p UpdateParams) (*Post, error) {
+			return &Post{ID: p.ID, Title: p.Title, Slug: p.Slug, S3Key: p.S3Key}, nil
+		},
+	}
+
+	t.Run("rename", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		svc := NewService(repo, slotStorage(semaphore.NewWeighted(1), stored), nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		slug := "renamed"
+		if _, err := svc.UpdatePost(ctx, "img", UpdatePostInput{Slug: &slug}); err != nil {
+			t.Fatalf("UpdatePost: %v", err)
+		}
+	})
+
+	t.Run("reprocess images", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		svc := NewService(repo, slotStorage(semaphore.NewWeighted(1), stored), nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		if n, err := svc.ReprocessImages(ctx, "img"); err != nil || n != 1 {
+			t.Fatalf("ReprocessImages = %d, %v", n, err)
+		}
+	})
+}
+
+// draftBySlug is a GetBySlug stub returning a markdown draft with no content.
+func draftBySlug(_ context.Context, slug string) (*Post, error) {
+	return &Post{ID: uuid.New(), Slug: slug, Status: Draft}, nil
+}
+
 func TestService_PublishPost(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		ctx := context.Background()
 		want := &Post{ID: uuid.New(), Slug: "p", Status: Published}
-		repo := &mockRepo{publish: func(context.Context, string) (*Post, error) { return want, nil }}
+		repo := &mockRepo{getBySlug: draftBySlug, publish: func(context.Context, string) (*Post, error) { return want, nil }}
 		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
 		got, err := svc.PublishPost(ctx, "p")
 		if err != nil {
@@ -522,6 +1424,241 @@ func TestService_PublishPost(t *testing.T) {
 		}
 	})
 
+	t.Run("cancelled request still publishes event", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		repo := &mockRepo{getBySlug: draftBySlug, publish: func(context.Context, string) (*Post, error) {
+			cancel()
+			return &Post{ID: uuid.New(), Slug: "p", Title: "P", Status: Published}, nil
+		}}
+		var published []events.PostPublished
+		pub := &mockPublisher{publishPostPublished: func(ctx context.Context, e events.PostPublished) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("expected publish context to carry a deadline")
+			}
+			published = append(published, e)
+			return nil
+		}}
+		svc := NewService(repo, &mockStorage{}, pub, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		if _, err := svc.PublishPost(ctx, "p"); err != nil {
+			t.Fatalf("PublishPost: %v", err)
+		}
+		if len(published) != 1 || published[0].Payload.Slug != "p" {
+			t.Errorf("published events = %+v", published)
+		}
+	})
+
+	t.Run("event timestamp uses injected clock", func(t *testing.T) {
+		ctx := context.Background()
+		fixed := time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("UTC-5", -5*60*60))
+		repo := &mockRepo{getBySlug: draftBySlug, publish: func(context.Context, string) (*Post, error) {
+			return &Post{ID: uuid.New(), Slug: "p", Status: Published}, nil
+		}}
+		var got events.PostPublished
+		pub := &mockPublisher{publishPostPublished: func(_ context.Context, e events.PostPublished) error {
+			got = e
+			return nil
+		}}
+		svc := NewService(repo, &mockStorage{}, pub, nil, ServiceConfig{
+			S3Bucket: "b", AWSRegion: "r",
+			Now: func() time.Time { return fixed },
+		})
+		if _, err := svc.PublishPost(ctx, "p"); err != nil {
+			t.Fatalf("PublishPost: %v", err)
+		}
+		if !got.Timestamp.Equal(fixed) || got.Timestamp.Location() != time.UTC {
+			t.Errorf("event timestamp = %v, want %v in UTC", got.Timestamp, fixed.UTC())
+		}
+	})
+
+	t.Run("event carries URL and excerpt", func(t *testing.T) {
+		ctx := context.Background()
+		canonical := "https://blog.example.com/canonical"
+		for _, tc := range []struct {
+			name        string
+			post        Post
+			readFails   bool
+			wantURL     string
+			wantExcerpt string
+		}{
+			{"site URL", Post{Slug: "p", S3Key: "posts/p.md"}, false, "https://example.com/posts/p/preview", "First paragraph."},
+			{"canonical URL", Post{Slug: "p", S3Key: "posts/p.md", CanonicalURL: &canonical}, false, canonical, "First paragraph."},
+			{"not markdown", Post{Slug: "p", S3Key: "posts/p.adoc", Format: FormatAsciiDoc}, false, "https://example.com/posts/p/preview", ""},
+			{"content unreadable", Post{Slug: "p", S3Key: "posts/p.md"}, true, "https://example.com/posts/p/preview", ""},
+		} {
+			post := tc.post
+			post.ID = uuid.New()
+			repo := &mockRepo{
+				getBySlug: func(context.Context, string) (*Post, error) { return &post, nil },
+				publish: func(context.Context, string) (*Post, error) {
+					published := post
+					published.Status = Published
+					return &published, nil
+				},
+			}
+			st := &mockStorage{download: func(context.Context, string) (io.ReadCloser, error) {
+				if tc.readFails {
+					return nil, errors.New("s3 down")
+				}
+				return io.NopCloser(strings.NewReader("# Title\n\nFirst paragraph.\n\nSecond.")), nil
+			}}
+			var got events.PostPublished
+			pub := &mockPublisher{publishPostPublished: func(_ context.Context, e events.PostPublished) error {
+				got = e
+				return nil
+			}}
+			svc := NewService(repo, st, pub, nil, ServiceConfig{
+				S3Bucket: "b", AWSRegion: "r",
+				PostURL: func(slug string) string { return "https://example.com/posts/" + slug + "/preview" },
+			})
+			if _, err := svc.PublishPost(ctx, "p"); err != nil {
+				t.Fatalf("%s: PublishPost: %v", tc.name, err)
+			}
+			if got.Payload.URL != tc.wantURL || got.Payload.Excerpt != tc.wantExcerpt {
+				t.Errorf("%s: url=%q excerpt=%q, want %q, %q", tc.name, got.Payload.URL, got.Payload.Excerpt, tc.wantURL, tc.wantExcerpt)
+			}
+		}
+	})
+
+	t.Run("required event", func(t *testing.T) {
+		ctx := context.Background()
+		for _, tc := range []struct {
+			name         string
+			requireEvent bool
+			publishErr   error
+			revertErr    error
+			wantErr      error
+			wantReverted bool
+			wantMarked   bool
+		}{
+			{"lenient, event sent", false, nil, nil, nil, false, true},
+			{"lenient, event failed", false, errors.New("broker down"), nil, nil, false, false},
+			{"strict, event sent", true, nil, nil, nil, false, true},
+			{"strict, event failed", true, errors.New("broker down"), nil, ErrEventUndelivered, true, false},
+			{"strict, revert failed", true, errors.New("broker down"), errors.New("db down"), ErrRevertFailed, true, false},
+		} {
+			postID := uuid.New()
+			committed, reverted := false, false
+			var eventID uuid.UUID
+			repo := &mockRepo{
+				getBySlug: draftBySlug,
+				publish: func(context.Context, string) (*Post, error) {
+					committed = true
+					return &Post{ID: postID, Slug: "p", Status: Published}, nil
+				},
+				revert: func(_ context.Context, id, evID uuid.UUID) error {
+					if id != postID {
+						t.Errorf("%s: reverted post %s, want %s", tc.name, id, postID)
+					}
+					reverted, eventID = true, evID
+					return tc.revertErr
+				},
+			}
+			pub := &mockPublisher{publishPostPublished: func(context.Context, events.PostPublished) error {
+				if !committed {
+					t.Errorf("%s: event published before commit", tc.name)
+				}
+				return tc.publishErr
+			}}
+			store := &mockOutbox{}
+			svc := NewService(repo, &mockStorage{}, pub, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r", Outbox: store, RequireEvent: tc.requireEvent})
+			post, err := svc.PublishPost(ctx, "p")
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) || post != nil {
+					t.Errorf("%s: got %+v, %v, want %v", tc.name, post, err, tc.wantErr)
+				}
+			} else if err != nil || post == nil {
+				t.Errorf("%s: got %+v, %v", tc.name, post, err)
+			}
+			if reverted != tc.wantReverted {
+				t.Errorf("%s: reverted = %v, want %v", tc.name, reverted, tc.wantReverted)
+			}
+			if reverted && eventID == uuid.Nil {
+				t.Errorf("%s: reverted without the outbox message ID", tc.name)
+			}
+			if (len(store.marked) == 1) != tc.wantMarked {
+				t.Errorf("%s: marked %v", tc.name, store.marked)
+			}
+		}
+	})
+
+	t.Run("required event timed out", func(t *testing.T) {
+		ctx := context.Background()
+		reverted := false
+		repo := &mockRepo{
+			getBySlug: draftBySlug,
+			publish: func(context.Context, string) (*Post, error) {
+				return &Post{ID: uuid.New(), Slug: "p", Status: Published}, nil
+			},
+			revert: func(ctx context.Context, _, _ uuid.UUID) error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				reverted = true
+				return nil
+			},
+		}
+		pub := &mockPublisher{publishPostPublished: func(ctx context.Context, _ events.PostPublished) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}}
+		svc := NewService(repo, &mockStorage{}, pub, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r", RequireEvent: true})
+		svc.eventTimeout = 10 * time.Millisecond
+		post, err := svc.PublishPost(ctx, "p")
+		if !errors.Is(err, ErrEventUndelivered) || post != nil {
+			t.Errorf("got %+v, %v, want ErrEventUndelivered", post, err)
+		}
+		if !reverted {
+			t.Error("publish not reverted after the event timed out")
+		}
+	})
+
+	t.Run("inline publish marks outbox message sent", func(t *testing.T) {
+		for _, fail := range []bool{false, true} {
+			ctx := context.Background()
+			repo := &mockRepo{getBySlug: draftBySlug, publish: func(context.Context, string) (*Post, error) {
+				return &Post{ID: uuid.New(), Slug: "p", Status: Published}, nil
+			}}
+			pub := &mockPublisher{publishPostPublished: func(context.Context, events.PostPublished) error {
+				if fail {
+					return errors.New("broker down")
+				}
+				return nil
+			}}
+			store := &mockOutbox{}
+			svc := NewService(repo, &mockStorage{}, pub, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r", Outbox: store})
+			if _, err := svc.PublishPost(ctx, "p"); err != nil {
+				t.Fatalf("PublishPost: %v", err)
+			}
+			if wantMarked := !fail; (len(store.marked) == 1) != wantMarked {
+				t.Errorf("publish failed=%v: marked %v", fail, store.marked)
+			}
+		}
+	})
+
+	t.Run("already published", func(t *testing.T) {
+		ctx := context.Background()
+		repo := &mockRepo{
+			getBySlug: func(context.Context, string) (*Post, error) {
+				return &Post{ID: uuid.New(), Slug: "p", S3Key: "posts/p.md", Status: Published}, nil
+			},
+			publish: func(context.Context, string) (*Post, error) {
+				t.Error("unexpected Publish")
+				return nil, ErrNotFound
+			},
+		}
+		st := &mockStorage{download: func(context.Context, string) (io.ReadCloser, error) {
+			t.Error("content read for an already published post")
+			return nil, errors.New("unexpected download")
+		}}
+		svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		if _, err := svc.PublishPost(ctx, "p"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got err %v, want ErrNotFound", err)
+		}
+	})
+
 	t.Run("not found", func(t *testing.T) {
 		ctx := context.Background()
 		repo := &mockRepo{publish: func(context.Context, string) (*Post, error) { return nil, ErrNotFound }}
@@ -533,6 +1670,113 @@ func TestService_PublishPost(t *testing.T) {
 	})
 }
 
+func TestService_PublishPostWithUpdate(t *testing.T) {
+	draft := &Post{ID: uuid.New(), Title: "Draft", Slug: "p", S3Key: "posts/p.md", Status: Draft}
+
+	t.Run("applies edits then publishes", func(t *testing.T) {
+		ctx := context.Background()
+		var steps []string
+		repo := &mockRepo{
+			getBySlug: func(context.Context, string) (*Post, error) { return draft, nil },
+			update: func(_ context.Context, p UpdateParams) (*Post, error) {
+				steps = append(steps, "update:"+p.Title)
+				return &Post{ID: p.ID, Title: p.Title, Slug: p.Slug, S3Key: p.S3Key}, nil
+			},
+			publish: func(context.Context, string) (*Post, error) {
+				steps = append(steps, "publish")
+				return &Post{ID: draft.ID, Title: "Final", Slug: "p", Status: Published}, nil
+			},
+		}
+		st := &mockStorage{
+			upload: func(_ context.Context, key string, _ io.Reader, _ string) error {
+				steps = append(steps, "upload:"+key)
+				return nil
+			},
+			download: func(context.Context, string) (io.ReadCloser, error) {
+				t.Error("excerpt should come from the submitted content")
+				return nil, errors.New("unexpected download")
+			},
+		}
+		var published []events.PostPublished
+		pub := &mockPublisher{publishPostPublished: func(_ context.Context, e events.PostPublished) error {
+			steps = append(steps, "event")
+			published = append(published, e)
+			return nil
+		}}
+		svc := NewService(repo, st, pub, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		title, content := "Final", "# Final\n\nNew body."
+		got, err := svc.PublishPostWithUpdate(ctx, "p", &title, &content)
+		if err != nil {
+			t.Fatalf("PublishPostWithUpdate: %v", err)
+		}
+		if got.Status != Published {
+			t.Errorf("status = %s", got.Status)
+		}
+		want := []string{"upload:posts/p.md", "update:Final", "publish", "event"}
+		if strings.Join(steps, ",") != strings.Join(want, ",") {
+			t.Errorf("steps = %v, want %v", steps, want)
+		}
+		if len(published) != 1 || published[0].Payload.Title != "Final" || published[0].Payload.Excerpt != "New body." {
+			t.Errorf("published = %+v", published)
+		}
+	})
+
+	t.Run("upload failure leaves draft unpublished", func(t *testing.T) {
+		ctx := context.Background()
+		repo := &mockRepo{
+			getBySlug: func(context.Context, string) (*Post, error) { return draft, nil },
+			update: func(context.Context, UpdateParams) (*Post, error) {
+				t.Error("unexpected Update")
+				return nil, nil
+			},
+			publish: func(context.Context, string) (*Post, error) {
+				t.Error("status must not flip when the upload fails")
+				return nil, nil
+			},
+		}
+		st := &mockStorage{upload: func(context.Context, string, io.Reader, string) error {
+			return errors.New("upload failed")
+		}}
+		pub := &mockPublisher{publishPostPublished: func(context.Context, events.PostPublished) error {
+			t.Error("unexpected event")
+			return nil
+		}}
+		svc := NewService(repo, st, pub, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		content := "# Final"
+		if _, err := svc.PublishPostWithUpdate(ctx, "p", nil, &content); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("publish failure after update", func(t *testing.T) {
+		ctx := context.Background()
+		repo := &mockRepo{
+			getBySlug: func(context.Context, string) (*Post, error) { return draft, nil },
+			update: func(_ context.Context, p UpdateParams) (*Post, error) {
+				return &Post{ID: p.ID, Title: p.Title, Slug: p.Slug, S3Key: p.S3Key}, nil
+			},
+			publish: func(context.Context, string) (*Post, error) { return nil, errors.New("db down") },
+		}
+		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		title := "Final"
+		if _, err := svc.PublishPostWithUpdate(ctx, "p", &title, nil); !errors.Is(err, ErrEditsSaved) {
+			t.Errorf("got err %v, want ErrEditsSaved", err)
+		}
+	})
+
+	t.Run("already published", func(t *testing.T) {
+		ctx := context.Background()
+		repo := &mockRepo{getBySlug: func(context.Context, string) (*Post, error) {
+			return &Post{Slug: "p", Status: Published}, nil
+		}}
+		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+		content := "# Final"
+		if _, err := svc.PublishPostWithUpdate(ctx, "p", nil, &content); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got err %v", err)
+		}
+	})
+}
+
 func TestService_s3PublicURL(t *testing.T) {
 	repo := &mockRepo{}
 	st := &mockStorage{}
@@ -551,7 +1795,7 @@ func TestService_s3PublicURL(t *testing.T) {
 func TestService_processMarkdownImages(t *testing.T) {
 	ctx := context.Background()
 	uploaded := make(map[string][]byte)
-	repo := &mockRepo{create: func(context.Context, string, string, string) (*Post, error) {
+	repo := &mockRepo{create: func(context.Context, CreateParams) (*Post, error) {
 		return &Post{ID: uuid.New(), Slug: "img"}, nil
 	}}
 	st := &mockStorage{
@@ -564,7 +1808,7 @@ func TestService_processMarkdownImages(t *testing.T) {
 	svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
 	b64 := "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg=="
 	content := "# Post\n\n![alt](data:image/png;base64," + b64 + ")"
-	_, err := svc.CreatePost(ctx, "Img", "img", content)
+	_, err := svc.CreatePost(ctx, CreatePostInput{Title: "Img", Slug: "img", Content: content})
 	if err != nil {
 		t.Fatalf("CreatePost: %v", err)
 	}
@@ -577,10 +1821,138 @@ func TestService_processMarkdownImages(t *testing.T) {
 	}
 }
 
+func TestService_processMarkdownImages_returnsUploadedKeys(t *testing.T) {
+	var uploaded []string
+	st := &mockStorage{
+		upload: func(_ context.Context, key string, _ io.Reader, _ string) error {
+			uploaded = append(uploaded, key)
+			return nil
+		},
+	}
+	svc := NewService(&mockRepo{}, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
+	b64 := "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg=="
+	content := "![a](data:image/png;base64," + b64 + ")\n![b](data:image/svg+xml;base64,PHN2Zy8+)\n![c](data:image/gif;base64," + b64 + ")"
+	out, keys := svc.processMarkdownImages(context.Background(), "img", content)
+	if len(keys) != 2 || !slices.Equal(keys, uploaded) {
+		t.Fatalf("keys %v, uploads %v", keys, uploaded)
+	}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "posts/img/images/") || !strings.Contains(out, key) {
+			t.Errorf("key %q not under the post or not referenced in %q", key, out)
+		}
+	}
+}
+
+func TestService_ImageEmbedMode(t *testing.T) {
+	b64 := "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg=="
+	content := "# Post\n\n![alt](data:image/png;base64," + b64 + ")"
+	existing := &Post{ID: uuid.New(), Slug: "img", S3Key: "posts/img.md"}
+
+	tests := []struct {
+		mode       ImageEmbedMode
+		wantErr    error
+		wantImages int
+		keepsData  bool
+	}{
+		{"", nil, 1, false},
+		{ImageEmbedUpload, nil, 1, false},
+		{ImageEmbedReject, ErrEmbeddedImages, 0, false},
+		{ImageEmbedIgnore, nil, 0, true},
+	}
+	for _, tt := range tests {
+		for _, op := range []string{"create", "update"} {
+			t.Run(string(tt.mode)+" "+op, func(t *testing.T) {
+				ctx := context.Background()
+				repo := &mockRepo{
+					create:    func(context.Context, CreateParams) (*Post, error) { return existing, nil },
+					getBySlug: func(context.Context, string) (*Post, error) { return existing, nil },
+					update:    func(context.Context, UpdateParams) (*Post, error) { return existing, nil },
+				}
+				var images int
+				var stored string
+				st := &mockStorage{upload: func(_ context.Context, key string, body io.Reader, _ string) error {
+					data, _ := io.ReadAll(body)
+					if strings.HasPrefix(key, "posts/img/images/") {
+						images++
+					} else {
+						stored = string(data)
+					}
+					return nil
+				}}
+				svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r", ImageEmbedMode: tt.mode})
+				var err error
+				if op == "create" {
+					_, err = svc.CreatePost(ctx, CreatePostInput{Title: "Img", Slug: "img", Content: content})
+				} else {
+					_, err = svc.UpdatePost(ctx, "img", UpdatePostInput{Content: &content})
+				}
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if images != tt.wantImages {
+					t.Errorf("image uploads = %d, want %d", images, tt.wantImages)
+				}
+				if tt.wantErr == nil && strings.Contains(stored, "data:image") != tt.keepsData {
+					t.Errorf("stored content %q", stored)
+				}
+			})
+		}
+	}
+
+	t.Run("reject ignores non-markdown", func(t *testing.T) {
+		repo := &mockRepo{create: func(context.Context, CreateParams) (*Post, error) { return existing, nil }}
+		svc := NewService(repo, &mockStorage{}, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r", ImageEmbedMode: ImageEmbedReject})
+		_, err := svc.CreatePost(context.Background(), CreatePostInput{Title: "T", Slug: "t", Content: content, Format: FormatRST})
+		if err != nil {
+			t.Errorf("CreatePost: %v", err)
+		}
+	})
+}
+
+func TestService_processMarkdownImages_maxImageBytes(t *testing.T) {
+	const limit = 64
+	tests := []struct {
+		name      string
+		size      int
+		extracted bool
+	}{
+		{"just under limit", limit - 1, true},
+		{"at limit", limit, true},
+		{"just over limit", limit + 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			var imageUploads int
+			st := &mockStorage{
+				upload: func(_ context.Context, key string, _ io.Reader, _ string) error {
+					if strings.HasPrefix(key, "posts/img/images/") {
+						imageUploads++
+					}
+					return nil
+				},
+			}
+			svc := NewService(&mockRepo{}, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r", MaxImageBytes: limit})
+			b64 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xAB}, tt.size))
+			content := "![alt](data:image/png;base64," + b64 + ")"
+			out, keys := svc.processMarkdownImages(ctx, "img", content)
+			if got := len(keys) == 1; got != tt.extracted {
+				t.Fatalf("extracted = %v, want extracted=%v", keys, tt.extracted)
+			}
+			if tt.extracted != (imageUploads == 1) {
+				t.Errorf("image uploads = %d", imageUploads)
+			}
+			if !tt.extracted && out != content {
+				t.Errorf("oversized image should be left as data URL")
+			}
+		})
+	}
+}
+
 func TestService_processMarkdownImages_disallowedType(t *testing.T) {
 	ctx := context.Background()
 	uploaded := make(map[string][]byte)
-	repo := &mockRepo{create: func(context.Context, string, string, string) (*Post, error) {
+	repo := &mockRepo{create: func(context.Context, CreateParams) (*Post, error) {
 		return &Post{ID: uuid.New(), Slug: "img"}, nil
 	}}
 	st := &mockStorage{
@@ -592,7 +1964,7 @@ func TestService_processMarkdownImages_disallowedType(t *testing.T) {
 	}
 	svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
 	content := "# Post\n\n![alt](data:image/svg+xml;base64,PHN2Zy8+)"
-	_, err := svc.CreatePost(ctx, "Img", "img", content)
+	_, err := svc.CreatePost(ctx, CreatePostInput{Title: "Img", Slug: "img", Content: content})
 	if err != nil {
 		t.Fatalf("CreatePost: %v", err)
 	}
@@ -605,7 +1977,7 @@ func TestService_processMarkdownImages_disallowedType(t *testing.T) {
 func TestService_processMarkdownImages_invalidBase64(t *testing.T) {
 	ctx := context.Background()
 	uploaded := make(map[string][]byte)
-	repo := &mockRepo{create: func(context.Context, string, string, string) (*Post, error) {
+	repo := &mockRepo{create: func(context.Context, CreateParams) (*Post, error) {
 		return &Post{ID: uuid.New(), Slug: "img"}, nil
 	}}
 	st := &mockStorage{
@@ -617,7 +1989,7 @@ func TestService_processMarkdownImages_invalidBase64(t *testing.T) {
 	}
 	svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
 	content := "# Post\n\n![alt](data:image/png;base64,not-valid-base64!!)"
-	_, err := svc.CreatePost(ctx, "Img", "img", content)
+	_, err := svc.CreatePost(ctx, CreatePostInput{Title: "Img", Slug: "img", Content: content})
 	if err != nil {
 		t.Fatalf("CreatePost: %v", err)
 	}
@@ -630,7 +2002,7 @@ func TestService_processMarkdownImages_invalidBase64(t *testing.T) {
 func TestService_processMarkdownImages_uploadFails(t *testing.T) {
 	ctx := context.Background()
 	uploadCount := 0
-	repo := &mockRepo{create: func(context.Context, string, string, string) (*Post, error) {
+	repo := &mockRepo{create: func(context.Context, CreateParams) (*Post, error) {
 		return &Post{ID: uuid.New(), Slug: "img"}, nil
 	}}
 	st := &mockStorage{
@@ -645,7 +2017,7 @@ func TestService_processMarkdownImages_uploadFails(t *testing.T) {
 	svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r"})
 	b64 := "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg=="
 	content := "# Post\n\n![alt](data:image/png;base64," + b64 + ")"
-	_, err := svc.CreatePost(ctx, "Img", "img", content)
+	_, err := svc.CreatePost(ctx, CreatePostInput{Title: "Img", Slug: "img", Content: content})
 	if err != nil {
 		t.Fatalf("CreatePost: %v", err)
 	}
@@ -0,0 +1,106 @@
+package posts
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/middleware"
+)
+
+var _ Repository = (*slowQueryRepository)(nil)
+
+// slowQueryRepository times every repository call and logs a warning for those
+// that take longer than threshold.
+type slowQueryRepository struct {
+	next      Repository
+	threshold time.Duration
+	logger    *slog.Logger
+}
+
+// NewSlowQueryRepository wraps repo so calls slower than threshold are logged
+// with the operation, its duration and the request ID. A threshold of zero or
+// less returns repo as is.
+func NewSlowQueryRepository(repo Repository, threshold time.Duration, logger *slog.Logger) Repository {
+	if threshold <= 0 {
+		return repo
+	}
+	return &slowQueryRepository{next: repo, threshold: threshold, logger: logger}
+}
+
+func (r *slowQueryRepository) observe(ctx context.Context, op string, start time.Time) {
+	if d := time.Since(start); d > r.threshold {
+		r.logger.Warn("slow query",
+			"op", op,
+			"duration_ms", d.Milliseconds(),
+			"threshold_ms", r.threshold.Milliseconds(),
+			"request_id", middleware.GetRequestID(ctx),
+		)
+	}
+}
+
+func (r *slowQueryRepository) Create(ctx context.Context, params CreateParams) (*Post, error) {
+	defer r.observe(ctx, "Create", time.Now())
+	return r.next.Create(ctx, params)
+}
+
+func (r *slowQueryRepository) GetBySlug(ctx context.Context, slug string) (*Post, error) {
+	defer r.observe(ctx, "GetBySlug", time.Now())
+	return r.next.GetBySlug(ctx, slug)
+}
+
+func (r *slowQueryRepository) GetByID(ctx context.Context, id uuid.UUID) (*Post, error) {
+	defer r.observe(ctx, "GetByID", time.Now())
+	return r.next.GetByID(ctx, id)
+}
+
+func (r *slowQueryRepository) List(ctx context.Context, params ListParams) ([]*Post, error) {
+	defer r.observe(ctx, "List", time.Now())
+	return r.next.List(ctx, params)
+}
+
+func (r *slowQueryRepository) Count(ctx context.Context, statuses []Status) (int64, error) {
+	defer r.observe(ctx, "Count", time.Now())
+	return r.next.Count(ctx, statuses)
+}
+
+func (r *slowQueryRepository) Update(ctx context.Context, params UpdateParams) (*Post, error) {
+	defer r.observe(ctx, "Update", time.Now())
+	return r.next.Update(ctx, params)
+}
+
+func (r *slowQueryRepository) Delete(ctx context.Context, slug string) error {
+	defer r.observe(ctx, "Delete", time.Now())
+	return r.next.Delete(ctx, slug)
+}
+
+func (r *slowQueryRepository) Publish(ctx context.Context, slug string, newEvent NewEventFunc) (*Post, error) {
+	defer r.observe(ctx, "Publish", time.Now())
+	return r.next.Publish(ctx, slug, newEvent)
+}
+
+func (r *slowQueryRepository) RevertPublish(ctx context.Context, id, eventID uuid.UUID) error {
+	defer r.observe(ctx, "RevertPublish", time.Now())
+	return r.next.RevertPublish(ctx, id, eventID)
+}
+
+func (r *slowQueryRepository) IncrementViews(ctx context.Context, id uuid.UUID, delta int64) error {
+	defer r.observe(ctx, "IncrementViews", time.Now())
+	return r.next.IncrementViews(ctx, id, delta)
+}
+
+func (r *slowQueryRepository) ListTags(ctx context.Context, status *Status) ([]TagCount, error) {
+	defer r.observe(ctx, "ListTags", time.Now())
+	return r.next.ListTags(ctx, status)
+}
+
+func (r *slowQueryRepository) ExistingSlugs(ctx context.Context, slugs []string) ([]string, error) {
+	defer r.observe(ctx, "ExistingSlugs", time.Now())
+	return r.next.ExistingSlugs(ctx, slugs)
+}
+
+func (r *slowQueryRepository) Adjacent(ctx context.Context, post *Post) (string, string, error) {
+	defer r.observe(ctx, "Adjacent", time.Now())
+	return r.next.Adjacent(ctx, post)
+}
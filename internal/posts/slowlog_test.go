@@ -0,0 +1,58 @@
+package posts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jeremyjsx/entries/internal/middleware"
+)
+
+func TestSlowQueryRepository(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	inner := &mockRepo{
+		getBySlug: func(context.Context, string) (*Post, error) {
+			time.Sleep(20 * time.Millisecond)
+			return &Post{Slug: "slow"}, nil
+		},
+		count: func(context.Context, []Status) (int64, error) { return 1, nil },
+	}
+	repo := NewSlowQueryRepository(inner, 5*time.Millisecond, logger)
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-1")
+
+	if _, err := repo.Count(ctx, nil); err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("fast query logged: %s", logs.String())
+	}
+
+	post, err := repo.GetBySlug(ctx, "slow")
+	if err != nil || post.Slug != "slow" {
+		t.Fatalf("GetBySlug = %v, %v", post, err)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log %q: %v", logs.String(), err)
+	}
+	if entry["level"] != "WARN" || entry["msg"] != "slow query" {
+		t.Errorf("entry %v", entry)
+	}
+	if entry["op"] != "GetBySlug" || entry["request_id"] != "req-1" {
+		t.Errorf("op %v, request_id %v", entry["op"], entry["request_id"])
+	}
+	if ms, _ := entry["duration_ms"].(float64); ms < 20 {
+		t.Errorf("duration_ms %v", entry["duration_ms"])
+	}
+}
+
+func TestNewSlowQueryRepository_Disabled(t *testing.T) {
+	inner := &mockRepo{}
+	if repo := NewSlowQueryRepository(inner, 0, slog.Default()); repo != Repository(inner) {
+		t.Errorf("got %T, want the repository unwrapped", repo)
+	}
+}
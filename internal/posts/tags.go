@@ -0,0 +1,22 @@
+package posts
+
+import "strings"
+
+// NormalizeTags trims and lowercases tags and drops empty and duplicate
+// entries, keeping the first occurrence's position. The result is never nil.
+func NormalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
@@ -0,0 +1,27 @@
+package posts
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{name: "nil", in: nil, want: []string{}},
+		{name: "trim and lowercase", in: []string{"  Go ", "WEB-Dev"}, want: []string{"go", "web-dev"}},
+		{name: "dedupe keeps first", in: []string{"go", "rust", "Go", " go", "rust"}, want: []string{"go", "rust"}},
+		{name: "drops empty", in: []string{"", "  ", "go"}, want: []string{"go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NormalizeTags(tt.in)
+			if got == nil || !slices.Equal(got, tt.want) {
+				t.Errorf("NormalizeTags(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
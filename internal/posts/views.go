@@ -0,0 +1,91 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultViewFlushInterval = 30 * time.Second
+	viewFlushTimeout         = 10 * time.Second
+)
+
+// ViewCounter batches post view increments in memory and writes them to the
+// repository periodically, so reads don't cost a write each.
+type ViewCounter struct {
+	repo     Repository
+	logger   *slog.Logger
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[uuid.UUID]int64
+}
+
+func NewViewCounter(repo Repository, logger *slog.Logger, interval time.Duration) *ViewCounter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if interval <= 0 {
+		interval = defaultViewFlushInterval
+	}
+	return &ViewCounter{
+		repo:     repo,
+		logger:   logger,
+		interval: interval,
+		pending:  make(map[uuid.UUID]int64),
+	}
+}
+
+// Record counts one view of the post with id. Counting by ID keeps views
+// recorded before a rename. It never blocks on the database.
+func (c *ViewCounter) Record(id uuid.UUID) {
+	c.mu.Lock()
+	c.pending[id]++
+	c.mu.Unlock()
+}
+
+// Flush writes all pending increments. Counts that fail to write are kept for
+// the next flush.
+func (c *ViewCounter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = make(map[uuid.UUID]int64)
+	c.mu.Unlock()
+
+	var errs []error
+	for id, delta := range batch {
+		if err := c.repo.IncrementViews(ctx, id, delta); err != nil {
+			errs = append(errs, err)
+			c.mu.Lock()
+			c.pending[id] += delta
+			c.mu.Unlock()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run flushes on every interval until ctx is done, then flushes once more.
+func (c *ViewCounter) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				c.logger.Warn("flush post views failed", "error", err)
+			}
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), viewFlushTimeout)
+			if err := c.Flush(flushCtx); err != nil {
+				c.logger.Warn("final flush of post views failed", "error", err)
+			}
+			cancel()
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type viewRecorder struct {
+	mu     sync.Mutex
+	calls  int
+	counts map[uuid.UUID]int64
+	err    error
+}
+
+func (r *viewRecorder) repo() *mockRepo {
+	r.counts = make(map[uuid.UUID]int64)
+	return &mockRepo{incViews: func(_ context.Context, id uuid.UUID, delta int64) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.calls++
+		if r.err != nil {
+			return r.err
+		}
+		r.counts[id] += delta
+		return nil
+	}}
+}
+
+func TestService_GetPostContent_countsViews(t *testing.T) {
+	ctx := context.Background()
+	rec := &viewRecorder{}
+	repo := rec.repo()
+	status := Published
+	ids := map[string]uuid.UUID{"a": uuid.New(), "draft": uuid.New()}
+	repo.getBySlug = func(_ context.Context, slug string) (*Post, error) {
+		return &Post{ID: ids[slug], Slug: slug, S3Key: "posts/" + slug + ".md", Status: status}, nil
+	}
+	st := &mockStorage{download: func(context.Context, string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("# hi")), nil
+	}}
+	views := NewViewCounter(repo, nil, time.Hour)
+	svc := NewService(repo, st, nil, nil, ServiceConfig{S3Bucket: "b", AWSRegion: "r", Views: views})
+
+	for range 3 {
+		if _, err := svc.GetPostContent(ctx, "a"); err != nil {
+			t.Fatalf("GetPostContent: %v", err)
+		}
+	}
+	status = Draft
+	if _, err := svc.GetPostContent(ctx, "draft"); err != nil {
+		t.Fatalf("GetPostContent: %v", err)
+	}
+	if rec.calls != 0 {
+		t.Fatalf("views written before flush: %d calls", rec.calls)
+	}
+
+	if err := views.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if rec.calls != 1 {
+		t.Errorf("IncrementViews calls = %d, want 1 batched call", rec.calls)
+	}
+	if rec.counts[ids["a"]] != 3 {
+		t.Errorf("views[a] = %d, want 3", rec.counts[ids["a"]])
+	}
+	if _, ok := rec.counts[ids["draft"]]; ok {
+		t.Errorf("draft views should not be counted")
+	}
+}
+
+func TestViewCounter_FlushKeepsFailedCounts(t *testing.T) {
+	ctx := context.Background()
+	rec := &viewRecorder{}
+	views := NewViewCounter(rec.repo(), nil, time.Hour)
+	id := uuid.New()
+	views.Record(id)
+	views.Record(id)
+
+	rec.err = errors.New("db down")
+	if err := views.Flush(ctx); err == nil {
+		t.Fatal("expected flush error")
+	}
+	rec.err = nil
+	if err := views.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if rec.counts[id] != 2 {
+		t.Errorf("views = %d, want 2 after retry", rec.counts[id])
+	}
+}
+
+func TestViewCounter_RunFlushesOnShutdown(t *testing.T) {
+	rec := &viewRecorder{}
+	views := NewViewCounter(rec.repo(), nil, time.Hour)
+	id := uuid.New()
+	views.Record(id)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		views.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.counts[id] != 1 {
+		t.Errorf("views = %d, want 1 after shutdown flush", rec.counts[id])
+	}
+}
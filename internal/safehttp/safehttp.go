@@ -0,0 +1,148 @@
+// Package safehttp fetches user-supplied URLs without exposing internal
+// services. Connections are only made to public addresses, checked on the
+// resolved IP of every dial, so neither DNS names that point inward nor
+// redirects can reach private, loopback or link-local hosts. Responses are
+// bounded in time and size.
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultTimeout      = 10 * time.Second
+	defaultMaxBytes     = 5 << 20
+	defaultMaxRedirects = 3
+)
+
+var (
+	// ErrBlockedAddress is returned when a URL resolves to an address that is
+	// not publicly routable.
+	ErrBlockedAddress = errors.New("address is not public")
+	// ErrTooLarge is returned when a response body exceeds the size limit.
+	ErrTooLarge = errors.New("response body too large")
+)
+
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+type Config struct {
+	// Timeout bounds the whole request, including reading the body.
+	// Defaults to 10s.
+	Timeout time.Duration
+	// MaxBytes caps response bodies. Defaults to 5MiB.
+	MaxBytes int64
+	// MaxRedirects caps followed redirects. Defaults to 3.
+	MaxRedirects int
+	// AllowAddr decides which resolved addresses may be dialed. Nil means
+	// IsPublic; tests use it to reach local servers.
+	AllowAddr func(netip.Addr) bool
+}
+
+// Client is an HTTP client for user-supplied URLs.
+type Client struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+func NewClient(cfg Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultMaxBytes
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = defaultMaxRedirects
+	}
+	allow := cfg.AllowAddr
+	if allow == nil {
+		allow = IsPublic
+	}
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		// Control runs after resolution, on the address actually dialed.
+		Control: func(_, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil || !allow(ap.Addr().Unmap()) {
+				return fmt.Errorf("dial %s: %w", address, ErrBlockedAddress)
+			}
+			return nil
+		},
+	}
+	return &Client{
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// No proxy: the address check must see the real destination.
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= cfg.MaxRedirects {
+					return errors.New("too many redirects")
+				}
+				return checkScheme(req)
+			},
+		},
+		maxBytes: cfg.MaxBytes,
+	}
+}
+
+// IsPublic reports whether a is a globally routable unicast address.
+func IsPublic(a netip.Addr) bool {
+	a = a.Unmap()
+	return a.IsGlobalUnicast() && !a.IsPrivate() && !cgnatPrefix.Contains(a)
+}
+
+func checkScheme(req *http.Request) error {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", req.URL.Scheme)
+	}
+	return nil
+}
+
+// Do sends req. Responses that declare a body over the limit fail with
+// ErrTooLarge; for the rest, reading past the limit fails with ErrTooLarge.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if err := checkScheme(req); err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ContentLength > c.maxBytes {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrTooLarge, resp.ContentLength, c.maxBytes)
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: c.maxBytes}
+	return resp, nil
+}
+
+// limitedBody fails with ErrTooLarge once more than the limit has been read,
+// unlike io.LimitReader, which would silently truncate.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		var probe [1]byte
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, ErrTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
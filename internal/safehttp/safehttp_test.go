@@ -0,0 +1,153 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":      true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"10.1.2.3":           false,
+		"172.16.0.1":         false,
+		"192.168.1.1":        false,
+		"169.254.169.254":    false,
+		"100.64.0.1":         false,
+		"0.0.0.0":            false,
+		"255.255.255.255":    false,
+		"::1":                false,
+		"::":                 false,
+		"fc00::1":            false,
+		"fd12:3456::1":       false,
+		"fe80::1":            false,
+		"ff02::1":            false,
+		"::ffff:127.0.0.1":   false,
+		"::ffff:192.168.0.1": false,
+	}
+	for addr, want := range tests {
+		if got := IsPublic(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPublic(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func get(c *Client, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func allowAll(netip.Addr) bool { return true }
+
+func TestClient_BlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	c := NewClient(Config{})
+	for _, target := range []string{
+		srv.URL,
+		// A name is checked by the address it resolves to, so a DNS record
+		// pointing inward cannot slip past.
+		"http://localhost:" + u.Port(),
+		"http://[::1]:" + u.Port(),
+	} {
+		if _, err := get(c, target); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("GET %s: err = %v, want ErrBlockedAddress", target, err)
+		}
+	}
+}
+
+func TestClient_ChecksRedirectTargets(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("cannot listen on 127.0.0.2: %v", err)
+	}
+	internal := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	internal.Listener.Close()
+	internal.Listener = ln
+	internal.Start()
+	defer internal.Close()
+	redirector := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusFound))
+	defer redirector.Close()
+
+	// 127.0.0.1 stands in for a public host that redirects to an internal
+	// one at 127.0.0.2.
+	onlyRedirector := func(a netip.Addr) bool { return a == netip.MustParseAddr("127.0.0.1") }
+	c := NewClient(Config{AllowAddr: onlyRedirector})
+	if _, err := get(c, redirector.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("err = %v, want ErrBlockedAddress", err)
+	}
+
+	c = NewClient(Config{AllowAddr: allowAll})
+	if body, err := get(c, redirector.URL); err != nil || string(body) != "internal" {
+		t.Errorf("with both allowed: %q, %v", body, err)
+	}
+}
+
+func TestClient_RejectsOtherSchemes(t *testing.T) {
+	c := NewClient(Config{AllowAddr: allowAll})
+	if _, err := get(c, "file:///etc/passwd"); err == nil {
+		t.Error("expected error for file URL")
+	}
+}
+
+func TestClient_MaxBytes(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("chunked") {
+			// No Content-Length, so only the read limit can catch it.
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		max     int64
+		query   string
+		wantErr bool
+	}{
+		{"under limit", 100, "", false},
+		{"declared over limit", 99, "", true},
+		{"streamed over limit", 99, "?chunked", true},
+		{"streamed at limit", 100, "?chunked", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(Config{MaxBytes: tt.max, AllowAddr: allowAll})
+			got, err := get(c, srv.URL+tt.query)
+			if tt.wantErr {
+				if !errors.Is(err, ErrTooLarge) {
+					t.Errorf("err = %v, want ErrTooLarge", err)
+				}
+				return
+			}
+			if err != nil || string(got) != body {
+				t.Errorf("got %d bytes, err %v", len(got), err)
+			}
+		})
+	}
+}
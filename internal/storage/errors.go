@@ -2,4 +2,7 @@ package storage
 
 import "errors"
 
-var ErrNotFound = errors.New("object not found")
+var (
+	ErrNotFound       = errors.New("object not found")
+	ErrBucketNotFound = errors.New("bucket not found")
+)
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	BackendS3         = "s3"
+	BackendFilesystem = "filesystem"
+
+	// FilesPath is where the API serves filesystem-backend objects.
+	FilesPath = "/files/"
+)
+
+// Config selects and configures a storage backend.
+type Config struct {
+	Backend string
+
+	// S3 backend.
+	S3Bucket   string
+	AWSRegion  string
+	S3Endpoint string // custom endpoint, e.g. LocalStack; enables path-style
+	S3Options  S3Options
+
+	// Filesystem backend.
+	Dir string
+}
+
+// New builds the backend named by cfg.Backend. AWS config is only loaded for
+// the s3 backend.
+func New(ctx context.Context, cfg Config) (Storage, error) {
+	switch cfg.Backend {
+	case BackendS3:
+		if cfg.S3Bucket == "" {
+			return nil, errors.New("S3_BUCKET is required")
+		}
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.AWSRegion))
+		if err != nil {
+			return nil, fmt.Errorf("load AWS config: %w", err)
+		}
+		client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+			if cfg.S3Endpoint != "" {
+				o.BaseEndpoint = aws.String(cfg.S3Endpoint)
+				o.UsePathStyle = true
+			}
+		})
+		return NewS3Storage(client, cfg.S3Bucket, cfg.S3Options), nil
+	case BackendFilesystem:
+		return NewFilesystemStorage(cfg.Dir)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", cfg.Backend)
+	}
+}
+
+// PublicBaseURL is the URL prefix objects are publicly served from. Empty means
+// the default virtual-hosted AWS URL for the bucket.
+func (cfg Config) PublicBaseURL() string {
+	switch cfg.Backend {
+	case BackendFilesystem:
+		return strings.TrimSuffix(FilesPath, "/")
+	case BackendS3:
+		if cfg.S3Endpoint != "" {
+			return strings.TrimSuffix(cfg.S3Endpoint, "/") + "/" + cfg.S3Bucket
+		}
+	}
+	return ""
+}
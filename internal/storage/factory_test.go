@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("filesystem needs no AWS config", func(t *testing.T) {
+		t.Setenv("AWS_REGION", "")
+		t.Setenv("AWS_ACCESS_KEY_ID", "")
+		dir := t.TempDir()
+		cfg := Config{Backend: BackendFilesystem, Dir: dir}
+		store, err := New(ctx, cfg)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		fsStore, ok := store.(*FilesystemStorage)
+		if !ok {
+			t.Fatalf("got %T, want *FilesystemStorage", store)
+		}
+		if fsStore.Root() != dir {
+			t.Errorf("root = %q", fsStore.Root())
+		}
+		if got := cfg.PublicBaseURL(); got != "/files" {
+			t.Errorf("PublicBaseURL = %q", got)
+		}
+	})
+
+	t.Run("s3", func(t *testing.T) {
+		cfg := Config{Backend: BackendS3, S3Bucket: "bucket", AWSRegion: "us-east-1"}
+		store, err := New(ctx, cfg)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if _, ok := store.(*S3Storage); !ok {
+			t.Fatalf("got %T, want *S3Storage", store)
+		}
+		if got := cfg.PublicBaseURL(); got != "" {
+			t.Errorf("PublicBaseURL = %q, want default AWS URL", got)
+		}
+	})
+
+	t.Run("s3 with custom endpoint", func(t *testing.T) {
+		cfg := Config{Backend: BackendS3, S3Bucket: "bucket", AWSRegion: "us-east-1", S3Endpoint: "http://localhost:4566/"}
+		store, err := New(ctx, cfg)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		opts := store.(*S3Storage).client.(*s3.Client).Options()
+		if !opts.UsePathStyle || aws.ToString(opts.BaseEndpoint) != "http://localhost:4566/" {
+			t.Errorf("path-style=%v endpoint=%q", opts.UsePathStyle, aws.ToString(opts.BaseEndpoint))
+		}
+		if got := cfg.PublicBaseURL(); got != "http://localhost:4566/bucket" {
+			t.Errorf("PublicBaseURL = %q", got)
+		}
+	})
+
+	t.Run("s3 without bucket", func(t *testing.T) {
+		if _, err := New(ctx, Config{Backend: BackendS3}); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	for _, name := range []string{"", "gcs", "S3"} {
+		t.Run("invalid backend "+name, func(t *testing.T) {
+			if _, err := New(ctx, Config{Backend: name}); err == nil {
+				t.Errorf("expected error for backend %q", name)
+			}
+		})
+	}
+}
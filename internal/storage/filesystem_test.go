@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFilesystemStorage(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFilesystemStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage: %v", err)
+	}
+
+	if err := s.Upload(ctx, "posts/a.md", strings.NewReader("# A"), "text/markdown"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if err := s.Upload(ctx, "posts/a/images/1.png", strings.NewReader("png"), "image/png"); err != nil {
+		t.Fatalf("Upload image: %v", err)
+	}
+
+	body, err := s.Download(ctx, "posts/a.md")
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	_ = body.Close()
+	if string(data) != "# A" {
+		t.Errorf("content = %q", data)
+	}
+
+	if ok, err := s.Exists(ctx, "posts/a.md"); err != nil || !ok {
+		t.Errorf("Exists = %v, %v", ok, err)
+	}
+	if n, err := s.Size(ctx, "posts/a.md"); err != nil || n != 3 {
+		t.Errorf("Size = %d, %v", n, err)
+	}
+	if _, err := s.Size(ctx, "posts/missing.md"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Size of missing object = %v, want ErrNotFound", err)
+	}
+
+	if keys, err := s.List(ctx, "posts/a"); err != nil || !slices.Equal(keys, []string{"posts/a.md", "posts/a/images/1.png"}) {
+		t.Errorf("List = %q, %v", keys, err)
+	}
+	if keys, err := s.List(ctx, "posts/b/"); err != nil || len(keys) != 0 {
+		t.Errorf("List of empty prefix = %q, %v", keys, err)
+	}
+
+	if err := s.DeletePrefix(ctx, "posts/a/"); err != nil {
+		t.Fatalf("DeletePrefix: %v", err)
+	}
+	if ok, _ := s.Exists(ctx, "posts/a/images/1.png"); ok {
+		t.Error("image should be deleted by prefix")
+	}
+	if ok, _ := s.Exists(ctx, "posts/a.md"); !ok {
+		t.Error("content outside prefix should remain")
+	}
+
+	if err := s.Delete(ctx, "posts/a.md"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Download(ctx, "posts/a.md"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Download after delete = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "posts/a.md"); err != nil {
+		t.Errorf("Delete missing: %v", err)
+	}
+
+	if err := s.Upload(ctx, "../escape.md", strings.NewReader("x"), "text/markdown"); err == nil {
+		t.Error("expected error for key outside root")
+	}
+}
@@ -1,37 +1,136 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/sync/semaphore"
 )
 
+// s3API is the subset of *s3.Client used by S3Storage.
+type s3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	s3.ListObjectsV2APIClient
+}
+
+// s3Uploader is the subset of *manager.Uploader used for large objects.
+type s3Uploader interface {
+	Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error)
+}
+
+// s3Presigner is the subset of *s3.PresignClient used by S3Storage.
+type s3Presigner interface {
+	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+// S3Options tunes how objects are written.
+type S3Options struct {
+	// PublicRead sets the public-read ACL on image uploads so their public
+	// URLs resolve. The bucket must allow ACLs (Object Ownership not set to
+	// "Bucket owner enforced") and must not block public ACLs.
+	PublicRead bool
+	// PublicReadContent extends PublicRead to all uploads, including
+	// markdown. It has no effect without PublicRead.
+	PublicReadContent bool
+	// MultipartThreshold is the size above which uploads go through the
+	// upload manager, in concurrent multipart chunks, instead of a single
+	// PutObject. Zero or negative always uses PutObject.
+	MultipartThreshold int64
+	// MaxConcurrency bounds the S3 calls in flight across the process; calls
+	// over the limit wait for a slot or for their context to end. Zero or
+	// negative means no limit.
+	MaxConcurrency int64
+}
+
 type S3Storage struct {
-	client *s3.Client
-	bucket string
+	client    s3API
+	presigner s3Presigner
+	uploader  s3Uploader
+	bucket    string
+	region    string
+	opts      S3Options
 }
 
-func NewS3Storage(client *s3.Client, bucket string) *S3Storage {
+var (
+	_ URLSigner     = (*S3Storage)(nil)
+	_ BucketChecker = (*S3Storage)(nil)
+)
+
+func NewS3Storage(client *s3.Client, bucket string, opts S3Options) *S3Storage {
+	var (
+		api       s3API                   = client
+		uploadAPI manager.UploadAPIClient = client
+	)
+	if opts.MaxConcurrency > 0 {
+		sem := semaphore.NewWeighted(opts.MaxConcurrency)
+		api = &limitedS3{s3API: client, sem: sem}
+		uploadAPI = &limitedUploadAPI{UploadAPIClient: client, sem: sem}
+	}
+	s := newS3Storage(api, bucket, opts)
+	s.presigner = s3.NewPresignClient(client)
+	s.uploader = manager.NewUploader(uploadAPI)
+	s.region = client.Options().Region
+	return s
+}
+
+func newS3Storage(client s3API, bucket string, opts S3Options) *S3Storage {
 	return &S3Storage{
 		client: client,
 		bucket: bucket,
+		opts:   opts,
 	}
 }
 
 func (s *S3Storage) Upload(ctx context.Context, key string, body io.Reader, contentType string) error {
-	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+	input := &s3.PutObjectInput{
 		Bucket:      aws.String(s.bucket),
 		Key:         aws.String(key),
 		Body:        body,
 		ContentType: aws.String(contentType),
-	})
+	}
+	if s.publicRead(contentType) {
+		input.ACL = types.ObjectCannedACLPublicRead
+	}
+	if s.opts.MultipartThreshold <= 0 || s.uploader == nil {
+		_, err := s.client.PutObject(ctx, input)
+		return err
+	}
+	// Read up to the threshold to learn whether the body is small enough for
+	// a single PutObject.
+	head, err := io.ReadAll(io.LimitReader(body, s.opts.MultipartThreshold+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(head)) <= s.opts.MultipartThreshold {
+		input.Body = bytes.NewReader(head)
+		_, err := s.client.PutObject(ctx, input)
+		return err
+	}
+	input.Body = io.MultiReader(bytes.NewReader(head), body)
+	_, err = s.uploader.Upload(ctx, input)
 	return err
 }
 
+func (s *S3Storage) publicRead(contentType string) bool {
+	return s.opts.PublicRead && (s.opts.PublicReadContent || strings.HasPrefix(contentType, "image/"))
+}
+
 func (s *S3Storage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
 	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
@@ -47,6 +146,21 @@ func (s *S3Storage) Download(ctx context.Context, key string) (io.ReadCloser, er
 	return output.Body, nil
 }
 
+// SignedURL returns a presigned GET URL for key that expires after ttl.
+func (s *S3Storage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if s.presigner == nil {
+		return "", errors.New("s3 storage has no presigner")
+	}
+	req, err := s.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
 func (s *S3Storage) Delete(ctx context.Context, key string) error {
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
@@ -55,32 +169,59 @@ func (s *S3Storage) Delete(ctx context.Context, key string) error {
 	return err
 }
 
+// maxDeleteBatch is the most keys S3 accepts in one DeleteObjects call.
+const maxDeleteBatch = 1000
+
+// DeletePrefix removes every object under prefix, paging through the listing
+// and deleting in batches of at most 1000 keys. Per-key failures reported by
+// DeleteObjects do not stop the sweep; they are returned together at the end.
 func (s *S3Storage) DeletePrefix(ctx context.Context, prefix string) error {
 	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.bucket),
 		Prefix: aws.String(prefix),
 	})
+	var errs []error
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return err
+			return errors.Join(append(errs, err)...)
 		}
-		if len(page.Contents) == 0 {
-			continue
+		for batch := range slices.Chunk(page.Contents, maxDeleteBatch) {
+			ids := make([]types.ObjectIdentifier, len(batch))
+			for i, obj := range batch {
+				ids[i] = types.ObjectIdentifier{Key: obj.Key}
+			}
+			out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+				Bucket: aws.String(s.bucket),
+				Delete: &types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+			})
+			if err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+			for _, e := range out.Errors {
+				errs = append(errs, fmt.Errorf("delete %s: %s: %s", aws.ToString(e.Key), aws.ToString(e.Code), aws.ToString(e.Message)))
+			}
 		}
-		ids := make([]types.ObjectIdentifier, len(page.Contents))
-		for i, obj := range page.Contents {
-			ids[i] = types.ObjectIdentifier{Key: obj.Key}
-		}
-		_, err = s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
-			Bucket: aws.String(s.bucket),
-			Delete: &types.Delete{Objects: ids},
-		})
+	}
+	return errors.Join(errs...)
+}
+
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]string, error) {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	var keys []string
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
 		}
 	}
-	return nil
+	return keys, nil
 }
 
 func (s *S3Storage) Exists(ctx context.Context, key string) (bool, error) {
@@ -97,3 +238,33 @@ func (s *S3Storage) Exists(ctx context.Context, key string) (bool, error) {
 	}
 	return true, nil
 }
+
+func (s *S3Storage) Size(ctx context.Context, key string) (int64, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	return aws.ToInt64(out.ContentLength), nil
+}
+
+// CheckBucket confirms the bucket exists and is reachable with the current
+// credentials.
+func (s *S3Storage) CheckBucket(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	if err != nil {
+		var notFound *types.NotFound
+		var noSuchBucket *types.NoSuchBucket
+		if errors.As(err, &notFound) || errors.As(err, &noSuchBucket) {
+			return fmt.Errorf("bucket %q does not exist in region %q: %w", s.bucket, s.region, ErrBucketNotFound)
+		}
+		return fmt.Errorf("head bucket %q in region %q: %w", s.bucket, s.region, err)
+	}
+	return nil
+}
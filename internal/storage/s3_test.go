@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type fakeS3 struct {
+	s3API
+	puts []*s3.PutObjectInput
+}
+
+func (f *fakeS3) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.puts = append(f.puts, in)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestS3Storage_UploadACL(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        S3Options
+		contentType string
+		want        types.ObjectCannedACL
+	}{
+		{"disabled by default", S3Options{}, "image/png", ""},
+		{"public read image", S3Options{PublicRead: true}, "image/png", types.ObjectCannedACLPublicRead},
+		{"public read skips content", S3Options{PublicRead: true}, "text/markdown", ""},
+		{"public read content", S3Options{PublicRead: true, PublicReadContent: true}, "text/markdown", types.ObjectCannedACLPublicRead},
+		{"content alone is not public", S3Options{PublicReadContent: true}, "text/markdown", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeS3{}
+			s := newS3Storage(fake, "bucket", tt.opts)
+			if err := s.Upload(context.Background(), "k", strings.NewReader("x"), tt.contentType); err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+			if len(fake.puts) != 1 {
+				t.Fatalf("PutObject calls = %d", len(fake.puts))
+			}
+			if got := fake.puts[0].ACL; got != tt.want {
+				t.Errorf("ACL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeUploader struct {
+	uploads []string
+}
+
+func (f *fakeUploader) Upload(_ context.Context, in *s3.PutObjectInput, _ ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.uploads = append(f.uploads, string(data))
+	return &manager.UploadOutput{}, nil
+}
+
+func TestS3Storage_UploadMultipartThreshold(t *testing.T) {
+	tests := []struct {
+		name          string
+		threshold     int64
+		body          string
+		wantMultipart bool
+	}{
+		{"disabled", 0, "0123456789", false},
+		{"at threshold", 10, "0123456789", false},
+		{"above threshold", 10, "0123456789a", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeS3{}
+			uploader := &fakeUploader{}
+			s := newS3Storage(fake, "bucket", S3Options{MultipartThreshold: tt.threshold})
+			s.uploader = uploader
+			if err := s.Upload(context.Background(), "k", strings.NewReader(tt.body), "text/markdown"); err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+			if tt.wantMultipart {
+				if len(uploader.uploads) != 1 || len(fake.puts) != 0 {
+					t.Fatalf("uploader calls = %d, PutObject calls = %d", len(uploader.uploads), len(fake.puts))
+				}
+				if uploader.uploads[0] != tt.body {
+					t.Errorf("uploaded %q, want %q", uploader.uploads[0], tt.body)
+				}
+				return
+			}
+			if len(fake.puts) != 1 || len(uploader.uploads) != 0 {
+				t.Fatalf("PutObject calls = %d, uploader calls = %d", len(fake.puts), len(uploader.uploads))
+			}
+			data, _ := io.ReadAll(fake.puts[0].Body)
+			if string(data) != tt.body {
+				t.Errorf("put %q, want %q", data, tt.body)
+			}
+		})
+	}
+}
+
+func TestS3Storage_SignedURL(t *testing.T) {
+	client := s3.New(s3.Options{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		}),
+	})
+	s := NewS3Storage(client, "bucket", S3Options{})
+
+	got, err := s.SignedURL(context.Background(), "posts/a/images/x.png", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	u, err := url.Parse(got)
+	if err != nil {
+		t.Fatalf("parse %q: %v", got, err)
+	}
+	if u.Host != "bucket.s3.us-east-1.amazonaws.com" || u.Path != "/posts/a/images/x.png" {
+		t.Errorf("URL %q", got)
+	}
+	q := u.Query()
+	if q.Get("X-Amz-Expires") != "900" || q.Get("X-Amz-Signature") == "" {
+		t.Errorf("query %v", q)
+	}
+}
+
+type pagedS3 struct {
+	s3API
+	keys     []string
+	pageSize int
+	failKey  string
+	batches  []int
+	deleted  int
+}
+
+func (f *pagedS3) ListObjectsV2(_ context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	start := 0
+	if in.ContinuationToken != nil {
+		start, _ = strconv.Atoi(*in.ContinuationToken)
+	}
+	end := min(start+f.pageSize, len(f.keys))
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(end < len(f.keys))}
+	for _, k := range f.keys[start:end] {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(k)})
+	}
+	if end < len(f.keys) {
+		out.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+	return out, nil
+}
+
+func (f *pagedS3) DeleteObjects(_ context.Context, in *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	f.batches = append(f.batches, len(in.Delete.Objects))
+	out := &s3.DeleteObjectsOutput{}
+	for _, obj := range in.Delete.Objects {
+		if aws.ToString(obj.Key) == f.failKey {
+			out.Errors = append(out.Errors, types.Error{Key: obj.Key, Code: aws.String("AccessDenied"), Message: aws.String("Access Denied")})
+			continue
+		}
+		f.deleted++
+	}
+	return out, nil
+}
+
+func TestS3Storage_List(t *testing.T) {
+	fake := &pagedS3{pageSize: 2}
+	for i := range 5 {
+		fake.keys = append(fake.keys, "posts/a/images/"+strconv.Itoa(i)+".png")
+	}
+	s := newS3Storage(fake, "bucket", S3Options{})
+
+	keys, err := s.List(context.Background(), "posts/a/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !slices.Equal(keys, fake.keys) {
+		t.Errorf("keys = %q, want %q", keys, fake.keys)
+	}
+	if fake.deleted != 0 || len(fake.batches) != 0 {
+		t.Errorf("List deleted objects")
+	}
+}
+
+func TestS3Storage_DeletePrefix_Batches(t *testing.T) {
+	fake := &pagedS3{pageSize: 1200, failKey: "posts/a/images/1500.png"}
+	for i := range 2500 {
+		fake.keys = append(fake.keys, "posts/a/images/"+strconv.Itoa(i)+".png")
+	}
+	s := newS3Storage(fake, "bucket", S3Options{})
+
+	err := s.DeletePrefix(context.Background(), "posts/a/")
+	if err == nil || !strings.Contains(err.Error(), fake.failKey) || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Fatalf("DeletePrefix error = %v, want the failed key reported", err)
+	}
+	for _, n := range fake.batches {
+		if n > maxDeleteBatch {
+			t.Errorf("batch of %d keys exceeds %d", n, maxDeleteBatch)
+		}
+	}
+	if want := []int{1000, 200, 1000, 200, 100}; !slices.Equal(fake.batches, want) {
+		t.Errorf("batches = %v, want %v", fake.batches, want)
+	}
+	if fake.deleted != 2499 {
+		t.Errorf("deleted = %d, want 2499", fake.deleted)
+	}
+}
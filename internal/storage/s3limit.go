@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/sync/semaphore"
+)
+
+// limitedS3 holds a slot of a shared semaphore for the duration of every S3
+// call, so calls beyond the limit wait, or fail with the context's error,
+// instead of opening another connection. GetObject keeps its slot until the
+// body is read to the end or closed, since the connection stays busy while it
+// is read.
+type limitedS3 struct {
+	s3API
+	sem *semaphore.Weighted
+}
+
+func (c *limitedS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.sem.Release(1)
+	return c.s3API.PutObject(ctx, params, optFns...)
+}
+
+func (c *limitedS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	out, err := c.s3API.GetObject(ctx, params, optFns...)
+	if err != nil {
+		c.sem.Release(1)
+		return nil, err
+	}
+	out.Body = &releasingBody{ReadCloser: out.Body, release: func() { c.sem.Release(1) }}
+	return out, nil
+}
+
+func (c *limitedS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.sem.Release(1)
+	return c.s3API.DeleteObject(ctx, params, optFns...)
+}
+
+func (c *limitedS3) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.sem.Release(1)
+	return c.s3API.DeleteObjects(ctx, params, optFns...)
+}
+
+func (c *limitedS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.sem.Release(1)
+	return c.s3API.HeadObject(ctx, params, optFns...)
+}
+
+func (c *limitedS3) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.sem.Release(1)
+	return c.s3API.HeadBucket(ctx, params, optFns...)
+}
+
+func (c *limitedS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.sem.Release(1)
+	return c.s3API.ListObjectsV2(ctx, params, optFns...)
+}
+
+// limitedUploadAPI applies the same semaphore to the calls the upload manager
+// makes, so each multipart part takes a slot of its own.
+type limitedUploadAPI struct {
+	manager.UploadAPIClient
+	sem *semaphore.Weighted
+}
+
+func (c *limitedUploadAPI) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.sem.Release(1)
+	return c.UploadAPIClient.PutObject(ctx, params, optFns...)
+}
+
+func (c *limitedUploadAPI) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.sem.Release(1)
+	return c.UploadAPIClient.UploadPart(ctx, params, optFns...)
+}
+
+func (c *limitedUploadAPI) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.sem.Release(1)
+	return c.UploadAPIClient.CreateMultipartUpload(ctx, params, optFns...)
+}
+
+func (c *limitedUploadAPI) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.sem.Release(1)
+	return c.UploadAPIClient.CompleteMultipartUpload(ctx, params, optFns...)
+}
+
+func (c *limitedUploadAPI) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.sem.Release(1)
+	return c.UploadAPIClient.AbortMultipartUpload(ctx, params, optFns...)
+}
+
+// releasingBody calls release once, at EOF or on the first Close.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/sync/semaphore"
+)
+
+// slowS3 records the most HeadObject calls it saw at once.
+type slowS3 struct {
+	s3API
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (f *slowS3) HeadObject(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		p := f.peak.Load()
+		if n <= p || f.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return &s3.HeadObjectOutput{}, nil
+}
+
+func (f *slowS3) GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("body"))}, nil
+}
+
+func TestLimitedS3(t *testing.T) {
+	t.Run("bounds concurrent calls", func(t *testing.T) {
+		fake := &slowS3{}
+		s := newS3Storage(&limitedS3{s3API: fake, sem: semaphore.NewWeighted(3)}, "bucket", S3Options{})
+
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := s.Exists(context.Background(), "k"); err != nil {
+					t.Errorf("Exists: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if peak := fake.peak.Load(); peak != 3 {
+			t.Errorf("peak concurrency = %d, want 3", peak)
+		}
+	})
+
+	t.Run("downloads hold a slot until closed", func(t *testing.T) {
+		sem := semaphore.NewWeighted(1)
+		s := newS3Storage(&limitedS3{s3API: &slowS3{}, sem: sem}, "bucket", S3Options{})
+
+		body, err := s.Download(context.Background(), "k")
+		if err != nil {
+			t.Fatalf("Download: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := s.Exists(ctx, "k"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Exists with an open body = %v, want DeadlineExceeded", err)
+		}
+
+		_ = body.Close()
+		_ = body.Close()
+		if !sem.TryAcquire(1) {
+			t.Fatal("slot not released by Close")
+		}
+		if sem.TryAcquire(1) {
+			t.Error("second Close released another slot")
+		}
+	})
+
+	t.Run("downloads release their slot at EOF", func(t *testing.T) {
+		sem := semaphore.NewWeighted(1)
+		s := newS3Storage(&limitedS3{s3API: &slowS3{}, sem: sem}, "bucket", S3Options{})
+
+		body, err := s.Download(context.Background(), "k")
+		if err != nil {
+			t.Fatalf("Download: %v", err)
+		}
+		if _, err := io.ReadAll(body); err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+		if !sem.TryAcquire(1) {
+			t.Fatal("slot not released at EOF")
+		}
+		_ = body.Close()
+		if sem.TryAcquire(1) {
+			t.Error("Close after EOF released another slot")
+		}
+	})
+}
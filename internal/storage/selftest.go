@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const selfTestPrefix = "__selftest__/"
+
+// SelfTest uploads, reads back and deletes a small temporary object, so a
+// bucket that is reachable but not writable or readable is caught at startup
+// instead of on the first request. Backends implementing BucketChecker first
+// confirm the bucket exists, which gives a clearer error than a failed upload.
+func SelfTest(ctx context.Context, s Storage) error {
+	if bc, ok := s.(BucketChecker); ok {
+		if err := bc.CheckBucket(ctx); err != nil {
+			return fmt.Errorf("self-test: %w", err)
+		}
+	}
+	key := selfTestPrefix + strconv.FormatInt(time.Now().UnixNano(), 10)
+	payload := []byte("entries storage self-test")
+
+	if err := s.Upload(ctx, key, bytes.NewReader(payload), "text/plain"); err != nil {
+		return fmt.Errorf("self-test upload %s: %w", key, err)
+	}
+	rc, err := s.Download(ctx, key)
+	if err != nil {
+		return fmt.Errorf("self-test download %s: %w", key, err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return fmt.Errorf("self-test read %s: %w", key, err)
+	}
+	if !bytes.Equal(got, payload) {
+		return fmt.Errorf("self-test read %s: content mismatch", key)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		return fmt.Errorf("self-test delete %s: %w", key, err)
+	}
+	return nil
+}
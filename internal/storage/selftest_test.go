@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type headBucketS3 struct {
+	s3API
+	err  error
+	puts int
+}
+
+func (f *headBucketS3) HeadBucket(context.Context, *s3.HeadBucketInput, ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	return nil, f.err
+}
+
+func (f *headBucketS3) PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.puts++
+	return &s3.PutObjectOutput{}, nil
+}
+
+type failingUploadStorage struct {
+	Storage
+	err error
+}
+
+func (s failingUploadStorage) Upload(context.Context, string, io.Reader, string) error {
+	return s.err
+}
+
+func TestSelfTest(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("usable storage", func(t *testing.T) {
+		fs, err := NewFilesystemStorage(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewFilesystemStorage: %v", err)
+		}
+		if err := SelfTest(ctx, fs); err != nil {
+			t.Fatalf("SelfTest: %v", err)
+		}
+	})
+
+	t.Run("upload fails", func(t *testing.T) {
+		denied := errors.New("access denied")
+		err := SelfTest(ctx, failingUploadStorage{err: denied})
+		if !errors.Is(err, denied) {
+			t.Fatalf("SelfTest error = %v, want %v", err, denied)
+		}
+	})
+
+	t.Run("bucket missing", func(t *testing.T) {
+		fake := &headBucketS3{err: &types.NoSuchBucket{Message: aws.String("The specified bucket does not exist")}}
+		s := newS3Storage(fake, "missing-bucket", S3Options{})
+		s.region = "eu-west-1"
+		err := SelfTest(ctx, s)
+		if !errors.Is(err, ErrBucketNotFound) {
+			t.Fatalf("SelfTest error = %v, want %v", err, ErrBucketNotFound)
+		}
+		for _, want := range []string{"missing-bucket", "eu-west-1"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not name %q", err, want)
+			}
+		}
+		if fake.puts != 0 {
+			t.Errorf("PutObject called %d times after HeadBucket failed", fake.puts)
+		}
+	})
+}
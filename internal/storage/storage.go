@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"io"
+	"time"
 )
 
 type Storage interface {
@@ -10,5 +11,22 @@ type Storage interface {
 	Download(ctx context.Context, key string) (io.ReadCloser, error)
 	Delete(ctx context.Context, key string) error
 	DeletePrefix(ctx context.Context, prefix string) error
+	// List returns the keys of all objects under prefix in lexical order.
+	List(ctx context.Context, prefix string) ([]string, error)
 	Exists(ctx context.Context, key string) (bool, error)
+	// Size returns the length of the object at key without reading it, or
+	// ErrNotFound.
+	Size(ctx context.Context, key string) (int64, error)
+}
+
+// URLSigner is implemented by backends that can issue time-limited GET URLs
+// for objects in a private bucket.
+type URLSigner interface {
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// BucketChecker is implemented by backends that can confirm their bucket
+// exists before any object is touched.
+type BucketChecker interface {
+	CheckBucket(ctx context.Context) error
 }
@@ -0,0 +1,179 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jeremyjsx/entries/internal/events"
+)
+
+const (
+	ActionLog       = "log"
+	ActionHTTP      = "http"
+	ActionRepublish = "republish"
+	ActionEmail     = "email"
+)
+
+const (
+	defaultForwardAttempts = 3
+	defaultForwardBackoff  = 500 * time.Millisecond
+	defaultForwardTimeout  = 10 * time.Second
+	defaultMaxRetryAfter   = 5 * time.Second
+)
+
+// TransientError marks a failure the downstream expects to clear, such as a
+// 429. The worker requeues the message after RetryAfter instead of dropping it.
+type TransientError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *TransientError) Error() string { return e.Err.Error() }
+
+func (e *TransientError) Unwrap() error { return e.Err }
+
+// Action is what the worker does with each post.published event it consumes.
+type Action interface {
+	Handle(ctx context.Context, e events.PostPublished) error
+}
+
+// LogAction writes the event as structured log fields.
+type LogAction struct {
+	Logger *slog.Logger
+}
+
+func (a LogAction) Handle(_ context.Context, e events.PostPublished) error {
+	a.Logger.Info("post published event received",
+		"event_id", e.ID,
+		"schema_version", e.SchemaVersion,
+		"type", e.Type,
+		"timestamp", e.Timestamp,
+		"post_id", e.Payload.PostID,
+		"slug", e.Payload.Slug,
+		"title", e.Payload.Title,
+		"url", e.Payload.URL,
+		"excerpt", e.Payload.Excerpt,
+	)
+	return nil
+}
+
+// HTTPForwardAction POSTs the event as JSON to URL, retrying on transport
+// errors, 5xx and 429 responses. A 429 waits for its Retry-After when that is
+// at most MaxRetryAfter; longer waits, or a 429 on the last attempt, return a
+// *TransientError so the message is requeued.
+type HTTPForwardAction struct {
+	URL           string
+	Client        *http.Client
+	MaxAttempts   int
+	Backoff       time.Duration
+	MaxRetryAfter time.Duration
+}
+
+func NewHTTPForwardAction(url string) *HTTPForwardAction {
+	return &HTTPForwardAction{
+		URL:           url,
+		Client:        &http.Client{Timeout: defaultForwardTimeout},
+		MaxAttempts:   defaultForwardAttempts,
+		Backoff:       defaultForwardBackoff,
+		MaxRetryAfter: defaultMaxRetryAfter,
+	}
+}
+
+func (a *HTTPForwardAction) Handle(ctx context.Context, e events.PostPublished) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	attempts := max(a.MaxAttempts, 1)
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			delay := a.Backoff * time.Duration(attempt-1)
+			var throttled *TransientError
+			if errors.As(lastErr, &throttled) && throttled.RetryAfter > 0 {
+				delay = throttled.RetryAfter
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		retry, err := a.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		var throttled *TransientError
+		if errors.As(err, &throttled) && throttled.RetryAfter > a.MaxRetryAfter {
+			break
+		}
+		if !retry {
+			break
+		}
+	}
+	var throttled *TransientError
+	if errors.As(lastErr, &throttled) {
+		return &TransientError{Err: fmt.Errorf("forward event: %w", throttled.Err), RetryAfter: throttled.RetryAfter}
+	}
+	return fmt.Errorf("forward event: %w", lastErr)
+}
+
+// post sends one request and reports whether a failure is worth retrying. A
+// 429 is returned as a *TransientError carrying the response's Retry-After.
+func (a *HTTPForwardAction) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true, &TransientError{
+			Err:        fmt.Errorf("unexpected status %d", resp.StatusCode),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	if resp.StatusCode >= 500 {
+		return true, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if resp.StatusCode >= 300 {
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// parseRetryAfter reads a Retry-After value in seconds or as an HTTP date,
+// returning zero when it is missing, invalid or already past.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+// RepublishAction hands the event to another publisher, e.g. a different queue.
+type RepublishAction struct {
+	Publisher events.Publisher
+}
+
+func (a RepublishAction) Handle(ctx context.Context, e events.PostPublished) error {
+	return a.Publisher.PublishPostPublished(ctx, e)
+}
@@ -0,0 +1,172 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/events"
+)
+
+func TestHTTPForwardAction(t *testing.T) {
+	evt := events.NewPostPublished(events.PostPublishedPayload{PostID: uuid.New(), Slug: "hello", Title: "Hello"}, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	t.Run("retries server errors then succeeds", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := calls.Add(1)
+			if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			var got events.PostPublished
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("decode body: %v", err)
+			}
+			if got.Payload.Slug != "hello" || got.Type != events.TypePostPublished {
+				t.Errorf("got event %+v", got)
+			}
+			if n < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer srv.Close()
+
+		a := NewHTTPForwardAction(srv.URL)
+		a.Backoff = time.Millisecond
+		if err := a.Handle(context.Background(), evt); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+		if calls.Load() != 3 {
+			t.Errorf("calls = %d, want 3", calls.Load())
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		a := NewHTTPForwardAction(srv.URL)
+		a.Backoff = time.Millisecond
+		if err := a.Handle(context.Background(), evt); err == nil {
+			t.Fatal("expected error")
+		}
+		if calls.Load() != int32(a.MaxAttempts) {
+			t.Errorf("calls = %d, want %d", calls.Load(), a.MaxAttempts)
+		}
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		a := NewHTTPForwardAction(srv.URL)
+		a.Backoff = time.Millisecond
+		if err := a.Handle(context.Background(), evt); err == nil {
+			t.Fatal("expected error")
+		}
+		if calls.Load() != 1 {
+			t.Errorf("calls = %d, want 1", calls.Load())
+		}
+	})
+
+	t.Run("honors retry-after on 429 then succeeds", func(t *testing.T) {
+		var calls atomic.Int32
+		var first time.Time
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				first = time.Now()
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			if waited := time.Since(first); waited < time.Second {
+				t.Errorf("retried after %v, want at least 1s", waited)
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+
+		a := NewHTTPForwardAction(srv.URL)
+		a.Backoff = time.Millisecond
+		if err := a.Handle(context.Background(), evt); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+		if calls.Load() != 2 {
+			t.Errorf("calls = %d, want 2", calls.Load())
+		}
+	})
+
+	t.Run("long retry-after is left to requeue", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer srv.Close()
+
+		a := NewHTTPForwardAction(srv.URL)
+		a.Backoff = time.Millisecond
+		err := a.Handle(context.Background(), evt)
+		var transient *TransientError
+		if !errors.As(err, &transient) {
+			t.Fatalf("Handle error = %v, want *TransientError", err)
+		}
+		if transient.RetryAfter != 2*time.Minute {
+			t.Errorf("RetryAfter = %v, want 2m", transient.RetryAfter)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("calls = %d, want 1", calls.Load())
+		}
+	})
+
+	t.Run("429 on every attempt is transient", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer srv.Close()
+
+		a := NewHTTPForwardAction(srv.URL)
+		a.Backoff = time.Millisecond
+		var transient *TransientError
+		if err := a.Handle(context.Background(), evt); !errors.As(err, &transient) {
+			t.Fatalf("Handle error = %v, want *TransientError", err)
+		}
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"-5", 0},
+		{"soon", 0},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
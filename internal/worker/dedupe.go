@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/events"
+)
+
+const defaultSeenCapacity = 10000
+
+// SeenSet remembers the most recent event keys, evicting the oldest once full.
+type SeenSet struct {
+	mu    sync.Mutex
+	keys  map[string]struct{}
+	order []string
+	next  int
+}
+
+func NewSeenSet(capacity int) *SeenSet {
+	if capacity <= 0 {
+		capacity = defaultSeenCapacity
+	}
+	return &SeenSet{
+		keys:  make(map[string]struct{}, capacity),
+		order: make([]string, 0, capacity),
+	}
+}
+
+func (s *SeenSet) Contains(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[key]
+	return ok
+}
+
+func (s *SeenSet) Add(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[key]; ok {
+		return
+	}
+	if len(s.order) < cap(s.order) {
+		s.order = append(s.order, key)
+	} else {
+		delete(s.keys, s.order[s.next])
+		s.order[s.next] = key
+		s.next = (s.next + 1) % len(s.order)
+	}
+	s.keys[key] = struct{}{}
+}
+
+// Dedupe wraps action so an event already handled successfully is skipped.
+// Failed events are not recorded and run again on redelivery.
+func Dedupe(action Action, seen *SeenSet) Action {
+	return dedupeAction{action: action, seen: seen}
+}
+
+type dedupeAction struct {
+	action Action
+	seen   *SeenSet
+}
+
+func (a dedupeAction) Handle(ctx context.Context, e events.PostPublished) error {
+	key := eventKey(e)
+	if a.seen.Contains(key) {
+		return nil
+	}
+	if err := a.action.Handle(ctx, e); err != nil {
+		return err
+	}
+	a.seen.Add(key)
+	return nil
+}
+
+// eventKey identifies an event, falling back to post ID and timestamp for
+// messages published before events carried an ID.
+func eventKey(e events.PostPublished) string {
+	if e.ID != uuid.Nil {
+		return e.ID.String()
+	}
+	return e.Payload.PostID.String() + "@" + e.Timestamp.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/events"
+)
+
+type countingAction struct {
+	calls int
+	err   error
+}
+
+func (a *countingAction) Handle(context.Context, events.PostPublished) error {
+	a.calls++
+	return a.err
+}
+
+func TestDedupe(t *testing.T) {
+	ctx := context.Background()
+	evt := events.NewPostPublished(events.PostPublishedPayload{PostID: uuid.New(), Slug: "hello", Title: "Hello"}, time.Now())
+
+	t.Run("same event delivered twice runs once", func(t *testing.T) {
+		inner := &countingAction{}
+		a := Dedupe(inner, NewSeenSet(0))
+		for range 2 {
+			if err := a.Handle(ctx, evt); err != nil {
+				t.Fatalf("Handle: %v", err)
+			}
+		}
+		if inner.calls != 1 {
+			t.Errorf("calls = %d, want 1", inner.calls)
+		}
+		other := events.NewPostPublished(events.PostPublishedPayload{PostID: evt.Payload.PostID, Slug: "hello", Title: "Hello"}, evt.Timestamp)
+		if err := a.Handle(ctx, other); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+		if inner.calls != 2 {
+			t.Errorf("distinct event ID should run again, calls = %d", inner.calls)
+		}
+	})
+
+	t.Run("failed event is retried", func(t *testing.T) {
+		inner := &countingAction{err: errors.New("sink down")}
+		a := Dedupe(inner, NewSeenSet(0))
+		_ = a.Handle(ctx, evt)
+		inner.err = nil
+		if err := a.Handle(ctx, evt); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+		if inner.calls != 2 {
+			t.Errorf("calls = %d, want 2", inner.calls)
+		}
+	})
+
+	t.Run("legacy events without ID dedupe on post and timestamp", func(t *testing.T) {
+		inner := &countingAction{}
+		a := Dedupe(inner, NewSeenSet(0))
+		legacy := evt
+		legacy.ID = uuid.Nil
+		_ = a.Handle(ctx, legacy)
+		_ = a.Handle(ctx, legacy)
+		if inner.calls != 1 {
+			t.Errorf("calls = %d, want 1", inner.calls)
+		}
+	})
+}
+
+func TestSeenSet_EvictsOldest(t *testing.T) {
+	s := NewSeenSet(2)
+	s.Add("a")
+	s.Add("b")
+	s.Add("c")
+	if s.Contains("a") || !s.Contains("b") || !s.Contains("c") {
+		t.Errorf("expected a evicted, b and c kept")
+	}
+}
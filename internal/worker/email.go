@@ -0,0 +1,211 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jeremyjsx/entries/internal/events"
+)
+
+const (
+	defaultEmailRetryAfter = 30 * time.Second
+	defaultSMTPTimeout     = 30 * time.Second
+)
+
+// EmailData is what the email subject and body templates are executed with.
+type EmailData struct {
+	Title       string
+	Slug        string
+	URL         string
+	PublishedAt time.Time
+}
+
+var (
+	// DefaultEmailSubject is the subject line of publish notifications.
+	DefaultEmailSubject = template.Must(template.New("subject").Parse(`New post: {{.Title}}`))
+	// DefaultEmailBody is the plain-text body of publish notifications.
+	DefaultEmailBody = template.Must(template.New("body").Parse(`"{{.Title}}" was published.
+
+Read it at {{.URL}}
+`))
+)
+
+// MailSender delivers one message to the envelope recipients in to.
+type MailSender interface {
+	Send(ctx context.Context, from string, to []string, msg []byte) error
+}
+
+// EmailAction mails a notification for each published post to a fixed list
+// of recipients. Failures the server or network report as temporary (4xx
+// replies, connection errors) return a *TransientError so the message is
+// requeued after RetryAfter; 5xx replies and anything else are permanent.
+type EmailAction struct {
+	Sender MailSender
+	From   *mail.Address
+	To     []*mail.Address
+	// PostURL returns the public URL of the post with slug.
+	PostURL    func(slug string) string
+	Subject    *template.Template
+	Body       *template.Template
+	RetryAfter time.Duration
+}
+
+func NewEmailAction(sender MailSender, from *mail.Address, to []*mail.Address, postURL func(slug string) string) *EmailAction {
+	return &EmailAction{
+		Sender:     sender,
+		From:       from,
+		To:         to,
+		PostURL:    postURL,
+		Subject:    DefaultEmailSubject,
+		Body:       DefaultEmailBody,
+		RetryAfter: defaultEmailRetryAfter,
+	}
+}
+
+func (a *EmailAction) Handle(ctx context.Context, e events.PostPublished) error {
+	msg, err := a.message(e)
+	if err != nil {
+		return fmt.Errorf("build email: %w", err)
+	}
+	to := make([]string, len(a.To))
+	for i, addr := range a.To {
+		to[i] = addr.Address
+	}
+	if err := a.Sender.Send(ctx, a.From.Address, to, msg); err != nil {
+		if transientMailError(err) {
+			return &TransientError{Err: fmt.Errorf("send email: %w", err), RetryAfter: a.RetryAfter}
+		}
+		return fmt.Errorf("send email: %w", err)
+	}
+	return nil
+}
+
+// message renders the templates into an RFC 5322 message with CRLF line
+// endings. The subject is Q-encoded, so a title cannot inject headers.
+func (a *EmailAction) message(e events.PostPublished) ([]byte, error) {
+	data := EmailData{
+		Title:       e.Payload.Title,
+		Slug:        e.Payload.Slug,
+		URL:         a.PostURL(e.Payload.Slug),
+		PublishedAt: e.Timestamp,
+	}
+	var subject, body bytes.Buffer
+	if err := a.Subject.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+	if err := a.Body.Execute(&body, data); err != nil {
+		return nil, err
+	}
+	to := make([]string, len(a.To))
+	for i, addr := range a.To {
+		to[i] = addr.String()
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", a.From.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(&msg, "Date: %s\r\n", e.Timestamp.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body.String(), "\r\n", "\n"), "\n", "\r\n"))
+	return msg.Bytes(), nil
+}
+
+// transientMailError reports whether err is worth retrying later: a 4xx SMTP
+// reply, a network failure or an interrupted send.
+func transientMailError(err error) bool {
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code >= 400 && reply.Code < 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// SMTPSender sends mail through an SMTP server, upgrading to TLS when the
+// server offers STARTTLS and authenticating when Username is set.
+type SMTPSender struct {
+	// Addr is the server's host:port.
+	Addr     string
+	Username string
+	Password string
+	// Timeout bounds the whole exchange. Defaults to 30s.
+	Timeout time.Duration
+}
+
+func (s *SMTPSender) Send(ctx context.Context, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultSMTPTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(deadline)
+	// net/smtp takes no context; closing the connection aborts the exchange.
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
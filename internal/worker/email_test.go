@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremyjsx/entries/internal/events"
+)
+
+type fakeSender struct {
+	err  error
+	from string
+	to   []string
+	msg  string
+}
+
+func (s *fakeSender) Send(_ context.Context, from string, to []string, msg []byte) error {
+	s.from, s.to, s.msg = from, to, string(msg)
+	return s.err
+}
+
+func testEmailAction(t *testing.T, sender MailSender) *EmailAction {
+	t.Helper()
+	to, err := mail.ParseAddressList("Ana <ana@example.com>, ops@example.com")
+	if err != nil {
+		t.Fatalf("ParseAddressList: %v", err)
+	}
+	return NewEmailAction(sender, &mail.Address{Name: "Entries", Address: "noreply@example.com"}, to,
+		func(slug string) string { return "https://blog.example.com/posts/" + slug })
+}
+
+func TestEmailAction(t *testing.T) {
+	evt := events.NewPostPublished(events.PostPublishedPayload{PostID: uuid.New(), Slug: "hello", Title: "Hello\r\nBcc: evil@example.com"}, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	t.Run("sends templated message", func(t *testing.T) {
+		sender := &fakeSender{}
+		if err := testEmailAction(t, sender).Handle(context.Background(), evt); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+		if sender.from != "noreply@example.com" {
+			t.Errorf("envelope from %q", sender.from)
+		}
+		if want := []string{"ana@example.com", "ops@example.com"}; strings.Join(sender.to, ",") != strings.Join(want, ",") {
+			t.Errorf("envelope to %q, want %q", sender.to, want)
+		}
+		header, body, ok := strings.Cut(sender.msg, "\r\n\r\n")
+		if !ok {
+			t.Fatalf("no header separator in %q", sender.msg)
+		}
+		for _, want := range []string{
+			`From: "Entries" <noreply@example.com>`,
+			`To: "Ana" <ana@example.com>, <ops@example.com>`,
+			"Subject: =?utf-8?q?",
+			"Content-Type: text/plain; charset=utf-8",
+		} {
+			if !strings.Contains(header, want) {
+				t.Errorf("header missing %q:\n%s", want, header)
+			}
+		}
+		if strings.Contains(header, "\r\nBcc:") {
+			t.Errorf("title injected a header:\n%s", header)
+		}
+		if !strings.Contains(body, "https://blog.example.com/posts/hello") {
+			t.Errorf("body missing post URL:\n%s", body)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"4xx reply is transient", &textproto.Error{Code: 451, Msg: "try again later"}, true},
+		{"network error is transient", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"5xx reply is permanent", &textproto.Error{Code: 550, Msg: "mailbox unavailable"}, false},
+		{"other errors are permanent", errors.New("unencrypted connection"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testEmailAction(t, &fakeSender{err: tt.err})
+			err := a.Handle(context.Background(), evt)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Handle error = %v, want it to wrap %v", err, tt.err)
+			}
+			var transient *TransientError
+			if got := errors.As(err, &transient); got != tt.transient {
+				t.Errorf("transient = %v, want %v", got, tt.transient)
+			}
+			if tt.transient && transient.RetryAfter != a.RetryAfter {
+				t.Errorf("RetryAfter = %v, want %v", transient.RetryAfter, a.RetryAfter)
+			}
+		})
+	}
+}